@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+// TestAliasedIDs checks that the former ID of a renamed user cannot be
+// registered while it still redirects to them, and can be once the alias
+// expires.
+func TestAliasedIDs(t *testing.T) {
+	// Each case gets its own server, so that one letting the ID through
+	// does not make the rest refuse it for being a user's.
+	renamed := func(t *testing.T) (*testServer, string) {
+		s := newTestServer(t)
+		admin := s.addUser("default", "admin", model.RoleAdmin)
+		s.addUser("default", "old", model.RoleStudent)
+		requireStatus(t, s.do(admin, "PUT", "/user/id", `{"id":"old","new_id":"new"}`), http.StatusOK)
+		return s, admin
+	}
+
+	cases := []struct {
+		name, method, path, contentType, body string
+	}{
+		{"register user", "POST", "/user", "application/json", `{"id":"old","name":"x"}`},
+		{"scim create", "POST", "/scim/v2/Users", "application/scim+json", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"old"}`},
+		{"rpc register", "POST", "/rpc", "application/json", `{"jsonrpc":"2.0","method":"UserApp.Register","params":{"id":"old","name":"x"},"id":1}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, admin := renamed(t)
+			w := s.doRaw(admin, c.method, c.path, c.contentType, c.body)
+			assert.Equal(t, http.StatusConflict, status(t, c.path, w), w.Body.String())
+		})
+	}
+
+	t.Run("import", func(t *testing.T) {
+		s, admin := renamed(t)
+		for _, dryRun := range []string{"true", "false"} {
+			var response struct {
+				Result model.ImportResult `json:"result"`
+			}
+			decode(t, s.doRaw(admin, "POST", "/user/import?dry_run="+dryRun, "text/csv", "id,name\nold,x\n"), http.StatusUnprocessableEntity, &response)
+			require.Len(t, response.Result.Errors, 1)
+			assert.Equal(t, 0, response.Result.Inserted)
+		}
+	})
+
+	t.Run("batch insert", func(t *testing.T) {
+		s, _ := renamed(t)
+		err := s.repos.users.InsertBatch(s.db, []model.User{{ID: "fresh", Name: "x", OrgID: "default"}, {ID: "old", Name: "x", OrgID: "default"}})
+		require.Error(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "failed to insert users:"), err.Error())
+		taken, err := s.repos.users.IDTaken(s.db, "fresh")
+		require.NoError(t, err)
+		assert.False(t, taken, "a refused batch inserted users")
+	})
+
+	s, admin := renamed(t)
+	requireStatus(t, s.do(admin, "GET", "/user?id=old", ""), http.StatusTemporaryRedirect)
+
+	// Once the alias expires, the ID is free again.
+	s.requireStore()
+	s.store.Now = func() time.Time { return time.Now().Add(31 * 24 * time.Hour) }
+	requireStatus(t, s.do(admin, "POST", "/user", `{"id":"old","name":"x"}`), http.StatusOK)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/infra"
+	"repo-api/src/infra/config"
+	"repo-api/src/infra/event"
+	"repo-api/src/infra/persistence"
+)
+
+// runAPIKey implements `apikey -user ID [-org ID] [-name NAME] [-scopes LIST] [-expires DURATION] [-create-user NAME] [-role ROLE] [-signing]`
+// and returns the process exit code. It is how the first key is issued,
+// before any key exists to call POST /apikey with. A key only grants what the
+// user's role allows, so the first administrator is bootstrapped with
+// -role admin.
+func runAPIKey(args []string) int {
+	flags := flag.NewFlagSet("apikey", flag.ContinueOnError)
+	userID := flags.String("user", "", "ID of the user the key is issued to")
+	org := flags.String("org", model.DefaultOrganization, "organization of the user")
+	name := flags.String("name", "bootstrap", "name of the key")
+	scopes := flags.String("scopes", "users:admin", "comma-separated scopes")
+	expires := flags.Duration("expires", 0, "lifetime of the key, such as 720h (default: never expires)")
+	createUser := flags.String("create-user", "", "register the user with this name if it does not exist yet")
+	role := flags.String("role", "", "assign this role to the user before issuing the key")
+	signing := flags.Bool("signing", false, "issue a signing key for signed requests instead of a bearer token")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: apikey -user ID [-org ID] [-name NAME] [-scopes LIST] [-expires DURATION] [-create-user NAME] [-role ROLE] [-signing]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 || *userID == "" || *expires < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	db, err := database.NewDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	// Like import, this command acts with administrator rights, confined to
+	// the organization given with -org.
+	admin := model.Principal{OrgID: *org, Scopes: []string{model.ScopeUsersAdmin}}
+
+	userPersistence := persistence.NewUserPersistence()
+	if *createUser != "" {
+		organizationApp := application.NewOrganizationApp(persistence.NewOrganizationPersistence(), userPersistence)
+		if _, err := organizationApp.Get(db, *org); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get organization %s: %v\n", *org, err)
+			return 1
+		}
+		userApp := application.NewUserApp(userPersistence, event.NewBroker(0), application.UserConfig{})
+		if _, err := userApp.Get(db, admin, *userID); err != nil {
+			if !strings.HasPrefix(err.Error(), "user not found") {
+				fmt.Fprintf(os.Stderr, "Failed to get user %s: %v\n", *userID, err)
+				return 1
+			}
+			if err := userApp.Register(db, admin, model.User{ID: *userID, Name: *createUser}); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to register user %s: %v\n", *userID, err)
+				return 1
+			}
+		}
+	}
+
+	if *role != "" {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			return 1
+		}
+		roleApp := application.NewRoleApp(persistence.NewRolePersistence(), userPersistence, cfg.Roles)
+		if _, err := roleApp.Assign(db, admin, *userID, *role); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to assign role %s to user %s: %v\n", *role, *userID, err)
+			return 1
+		}
+	}
+
+	var expiresAt *time.Time
+	if *expires > 0 {
+		t := time.Now().Add(*expires)
+		expiresAt = &t
+	}
+
+	apiKeyApp := application.NewAPIKeyApp(persistence.NewAPIKeyPersistence(), userPersistence)
+	issue := apiKeyApp.Issue
+	if *signing {
+		issue = apiKeyApp.IssueSigningKey
+	}
+	key, token, err := issue(db, *org, *userID, *name, strings.Split(*scopes, ","), expiresAt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to issue api key: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if key.Signing {
+		encoder.Encode(struct {
+			APIKey model.APIKey `json:"api_key"`
+			Secret string       `json:"secret"`
+		}{key, token})
+		return 0
+	}
+	encoder.Encode(struct {
+		APIKey model.APIKey `json:"api_key"`
+		Token  string       `json:"token"`
+	}{key, token})
+	return 0
+}
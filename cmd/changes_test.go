@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"repo-api/src/domain/model"
+)
+
+// TestChangeFeedSettles checks that the feed holds back changes until they
+// have settled, and everything after the first one that has not, so that a
+// change recorded late under a lower cursor is not skipped.
+func TestChangeFeedSettles(t *testing.T) {
+	s := newTestServer(t)
+	author := s.addUser("default", "author", model.RoleStudent)
+	s.requireStore()
+	now := time.Now()
+	s.store.Now = func() time.Time { return now }
+
+	changes := func(since uint64) model.ChangeFeed {
+		t.Helper()
+		var feed model.ChangeFeed
+		decode(t, s.do(author, "GET", "/changes?author_id=author&since="+strconv.FormatUint(since, 10), ""), http.StatusOK, &feed)
+		return feed
+	}
+	reportIDs := func(feed model.ChangeFeed) []string {
+		IDs := []string{}
+		for _, change := range feed.Changes {
+			IDs = append(IDs, change.ReportID)
+		}
+		return IDs
+	}
+
+	s.addReport("default", "author", "first", model.VisibilityPrivate)
+	feed := changes(0)
+	assert.Empty(t, feed.Changes)
+	assert.Equal(t, uint64(0), feed.NextCursor)
+	assert.False(t, feed.HasMore)
+
+	now = now.Add(6 * time.Second)
+	feed = changes(0)
+	assert.Equal(t, []string{"first"}, reportIDs(feed))
+	cursor := feed.NextCursor
+
+	// second is recorded first, under the lower cursor, but stamped later
+	// than the change to first after it.
+	recorded := now
+	s.addReport("default", "author", "second", model.VisibilityPrivate)
+	now = recorded.Add(-10 * time.Second)
+	requireStatus(t, s.do(author, "PUT", "/report", `{"id":"first","title":"changed"}`), http.StatusOK)
+
+	now = recorded.Add(2 * time.Second)
+	feed = changes(cursor)
+	assert.Empty(t, feed.Changes, "served a change behind one that has not settled")
+	assert.Equal(t, cursor, feed.NextCursor)
+
+	now = recorded.Add(6 * time.Second)
+	feed = changes(cursor)
+	assert.Equal(t, []string{"second", "first"}, reportIDs(feed))
+	assert.Equal(t, "changed", feed.Changes[1].Report.Title)
+}
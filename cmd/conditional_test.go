@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+// getIf sends a GET with the conditional header name set to value.
+func (s *testServer) getIf(token, path, name, value string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(name, value)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestConditionalReport(t *testing.T) {
+	s := newTestServer(t)
+	author := s.addUser("default", "author", model.RoleStudent)
+	s.addReport("default", "author", "r1", model.VisibilityPublic)
+
+	w := s.do(author, "GET", "/report?id=r1", "")
+	requireStatus(t, w, http.StatusOK)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, lastModified)
+
+	requireStatus(t, s.getIf(author, "/report?id=r1", "If-None-Match", etag), http.StatusNotModified)
+	requireStatus(t, s.getIf(author, "/report?id=r1", "If-Modified-Since", lastModified), http.StatusNotModified)
+	requireStatus(t, s.getIf(author, "/report?id=r1", "If-None-Match", `"other"`), http.StatusOK)
+}
+
+// TestConditionalReportList checks that a list is only answered with 304
+// while it is unchanged, including when reports leave it without any
+// report in it being updated.
+func TestConditionalReportList(t *testing.T) {
+	const list = "/report?author_id=author"
+	later := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+
+	for _, c := range []struct {
+		name   string
+		change func(s *testServer, author string)
+	}{
+		{"ejected", func(s *testServer, author string) {
+			requireStatus(t, s.do(author, "DELETE", "/report?id=r2", ""), http.StatusOK)
+		}},
+		{"made private", func(s *testServer, author string) {
+			requireStatus(t, s.do(author, "PUT", "/report", `{"id":"r2","visibility":"private"}`), http.StatusOK)
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestServer(t)
+			author := s.addUser("default", "author", model.RoleStudent)
+			other := s.addUser("default", "other", model.RoleStudent)
+			s.addReport("default", "author", "r1", model.VisibilityPublic)
+			s.addReport("default", "author", "r2", model.VisibilityPublic)
+
+			w := s.do(other, "GET", list, "")
+			requireStatus(t, w, http.StatusOK)
+			etag := w.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+			assert.Empty(t, w.Header().Get("Last-Modified"))
+			requireStatus(t, s.getIf(other, list, "If-None-Match", etag), http.StatusNotModified)
+
+			c.change(s, author)
+
+			w = s.getIf(other, list, "If-None-Match", etag)
+			requireStatus(t, w, http.StatusOK)
+			assert.NotEqual(t, etag, w.Header().Get("ETag"))
+			assert.NotContains(t, w.Body.String(), `"r2"`)
+			requireStatus(t, s.getIf(other, list, "If-Modified-Since", later), http.StatusOK)
+		})
+	}
+}
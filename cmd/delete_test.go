@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+// TestDeleteUserReferences checks that a deleted user leaves behind no
+// aliases or share links that name them.
+func TestDeleteUserReferences(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+	author := s.addUser("default", "author", model.RoleStudent)
+	s.addUser("default", "heir", model.RoleStudent)
+	s.addReport("default", "author", "r1", model.VisibilityPrivate)
+	requireStatus(t, s.do(author, "POST", "/report/link", `{"report_id":"r1"}`), http.StatusOK)
+	requireStatus(t, s.do(admin, "PUT", "/user/id", `{"id":"author","new_id":"renamed"}`), http.StatusOK)
+	requireStatus(t, s.do(admin, "DELETE", "/user?id=renamed&policy=reassign&reassign_to=heir", ""), http.StatusOK)
+
+	requireStatus(t, s.do(admin, "GET", "/user?id=author", ""), http.StatusNotFound)
+	requireStatus(t, s.do(admin, "POST", "/user", `{"id":"author","name":"x"}`), http.StatusOK)
+
+	var links struct {
+		Links []model.ShareLink `json:"links"`
+	}
+	decode(t, s.do(admin, "GET", "/report/link?report_id=r1", ""), http.StatusOK, &links)
+	require.Len(t, links.Links, 1)
+	assert.Equal(t, "heir", links.Links[0].CreatedBy)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/infra"
+	"repo-api/src/infra/event"
+	"repo-api/src/infra/persistence"
+)
+
+// runImport implements `import -kind user|report [-org ID] [-format csv|ndjson] [-dry-run] FILE`
+// and returns the process exit code.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	kind := flags.String("kind", "", "what to import: user or report")
+	format := flags.String("format", "", "input format: csv or ndjson (default: from file extension)")
+	dryRun := flags.Bool("dry-run", false, "validate every row without inserting anything")
+	org := flags.String("org", model.DefaultOrganization, "organization to import into")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: import -kind user|report [-org ID] [-format csv|ndjson] [-dry-run] FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || (*kind != "user" && *kind != "report") {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = application.DetectImportFormat(path, "")
+	}
+	if *format != application.ImportFormatCSV && *format != application.ImportFormatNDJSON {
+		fmt.Fprintln(os.Stderr, "format must be csv or ndjson")
+		return 2
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open %s: %v\n", path, err)
+		return 1
+	}
+	defer file.Close()
+
+	db, err := database.NewDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	organizationApp := application.NewOrganizationApp(persistence.NewOrganizationPersistence(), persistence.NewUserPersistence())
+	if _, err := organizationApp.Get(db, *org); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get organization %s: %v\n", *org, err)
+		return 1
+	}
+
+	// Nobody listens to events in this process; the broker only satisfies the
+	// publisher dependency.
+	importApp := application.NewImportApp(persistence.NewUserPersistence(), persistence.NewReportPersistence(), event.NewBroker(0))
+
+	// Whoever can run this command can reach the database directly, so it
+	// imports with administrator rights.
+	admin := model.Principal{OrgID: *org, Scopes: []string{model.ScopeUsersAdmin}}
+	var result model.ImportResult
+	if *kind == "user" {
+		result, err = importApp.ImportUsers(db, admin, file, *format, *dryRun)
+	} else {
+		result, err = importApp.ImportReports(db, admin, file, *format, *dryRun)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import %s: %v\n", path, err)
+		return 1
+	}
+
+	for _, rowErr := range result.Errors {
+		if rowErr.Field != "" {
+			fmt.Fprintf(os.Stderr, "row %d: %s: %s\n", rowErr.Row, rowErr.Field, rowErr.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "row %d: %s\n", rowErr.Row, rowErr.Message)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,16 +1,44 @@
 package main
 
 import (
+  "crypto/rand"
+  "database/sql"
   "log"
+  "os"
+  "strings"
+  "time"
 	"github.com/gin-gonic/gin"
 	"repo-api/src/infra/persistence"
 	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
 	"repo-api/src/presentation/rest"
+	"repo-api/src/presentation/rpc"
+	"repo-api/src/presentation/scim"
 	"repo-api/src/infra"
+	"repo-api/src/infra/config"
+	"repo-api/src/infra/event"
+	"repo-api/src/infra/nonce"
+	"repo-api/src/infra/ratelimit"
 	_ "github.com/go-sql-driver/mysql"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		os.Exit(runAPIKey(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "org" {
+		os.Exit(runOrganization(os.Args[2:]))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
 	db, err := database.NewDatabase()
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -18,21 +46,235 @@ func main() {
 	defer db.Close()
 
 
-  userPresistence := persistence.NewUserPersistence() 
-  userApp := application.NewUserApp(userPresistence)
+  eventBroker := event.NewBroker(1024)
+  router, webhookApp := newRouter(db, cfg, persistenceRepositories(), eventBroker)
+  go webhookApp.Run(db, eventBroker)
+  router.Run(":8080")
+}
+
+// repositories are where the server keeps its data, MySQL unless a test
+// says otherwise.
+type repositories struct {
+  users         repository.IUserRepository
+  reports       repository.IReportRepository
+  shares        repository.IShareRepository
+  shareLinks    repository.IShareLinkRepository
+  audit         repository.IAuditRepository
+  webhooks      repository.IWebhookRepository
+  apiKeys       repository.IAPIKeyRepository
+  sessions      repository.ISessionRepository
+  identities    repository.IIdentityRepository
+  roles         repository.IRoleRepository
+  organizations repository.IOrganizationRepository
+}
+
+func persistenceRepositories() repositories {
+  return repositories{
+    users:         persistence.NewUserPersistence(),
+    reports:       persistence.NewReportPersistence(),
+    shares:        persistence.NewSharePersistence(),
+    shareLinks:    persistence.NewShareLinkPersistence(),
+    audit:         persistence.NewAuditPersistence(),
+    webhooks:      persistence.NewWebhookPersistence(),
+    apiKeys:       persistence.NewAPIKeyPersistence(),
+    sessions:      persistence.NewSessionPersistence(),
+    identities:    persistence.NewIdentityPersistence(),
+    roles:         persistence.NewRolePersistence(),
+    organizations: persistence.NewOrganizationPersistence(),
+  }
+}
+
+// newRouter wires the applications and handlers over repos and routes the
+// API to them. The webhook app is returned for the caller to run.
+func newRouter(db *sql.DB, cfg config.Config, repos repositories, eventBroker *event.Broker) (*gin.Engine, application.WebhookApp) {
+  eventHandler := rest.NewEventHandler(eventBroker)
+
+  userPresistence := repos.users
+  userApp := application.NewUserApp(userPresistence, eventBroker, application.UserConfig{
+    IDAliasTTL: time.Duration(cfg.User.IDAliasTTLSeconds) * time.Second,
+  })
   userHandler := rest.NewUserHandler(db, userApp)
 
-  reportPresistence := persistence.NewReportPersistence()
-  reportApp := application.NewReportApp(reportPresistence)
+  reportPresistence := repos.reports
+  sharePresistence := repos.shares
+  reportApp := application.NewReportApp(reportPresistence, sharePresistence, userPresistence, eventBroker)
   reportHandler := rest.NewReportHandler(db, reportApp)
+
+  shareApp := application.NewShareApp(sharePresistence, reportPresistence, userPresistence)
+  shareHandler := rest.NewShareHandler(db, shareApp)
+
+  shareLinkPresistence := repos.shareLinks
+  shareLinkApp := application.NewShareLinkApp(shareLinkPresistence, reportPresistence, shareLinkConfig(cfg.ShareLink))
+  shareLinkHandler := rest.NewShareLinkHandler(db, shareLinkApp)
+
+  importApp := application.NewImportApp(userPresistence, reportPresistence, eventBroker)
+  importHandler := rest.NewImportHandler(db, importApp)
+
+  rpcHandler := rpc.NewRPCHandler(db, userApp, reportApp)
+  scimHandler := scim.NewSCIMHandler(db, userApp)
+
+  auditPresistence := repos.audit
+  auditApp := application.NewAuditApp(auditPresistence)
+  auditHandler := rest.NewAuditHandler(db, auditApp)
+
+  webhookPresistence := repos.webhooks
+  webhookApp := application.NewWebhookApp(webhookPresistence, application.DefaultWebhookConfig())
+  webhookHandler := rest.NewWebhookHandler(db, webhookApp)
+
+  apiKeyPresistence := repos.apiKeys
+  apiKeyApp := application.NewAPIKeyApp(apiKeyPresistence, userPresistence)
+  apiKeyHandler := rest.NewAPIKeyHandler(db, apiKeyApp)
+  signatureApp := application.NewSignatureApp(apiKeyPresistence, nonce.NewMemoryStore(), application.SignatureConfig{
+    MaxSkew: time.Duration(cfg.Signature.MaxSkewSeconds) * time.Second,
+  })
+
+  sessionPresistence := repos.sessions
+  sessionSettings := sessionConfig(cfg.Session)
+  sessionApp := application.NewSessionApp(sessionPresistence, userPresistence, sessionSettings)
+  sessionHandler := rest.NewSessionHandler(db, sessionApp)
+
+  identityPresistence := repos.identities
+  oidcApp := application.NewOIDCApp(identityPresistence, userPresistence, sessionApp, oidcConfig(cfg.OIDC, sessionSettings.Secret))
+  oidcHandler := rest.NewOIDCHandler(db, oidcApp, strings.HasPrefix(cfg.OIDC.RedirectURL, "https://"))
+
+  rolePresistence := repos.roles
+  roleApp := application.NewRoleApp(rolePresistence, userPresistence, cfg.Roles)
+  roleHandler := rest.NewRoleHandler(db, roleApp)
+
+  organizationPresistence := repos.organizations
+  organizationApp := application.NewOrganizationApp(organizationPresistence, userPresistence)
+  organizationHandler := rest.NewOrganizationHandler(db, organizationApp)
   
   router := gin.Default()
-  router.POST("/user", userHandler.HandleRegisterUser)
-  router.GET("/user", userHandler.HandleGet)
-  router.PUT("/user", userHandler.HandleUpdate)
-  router.POST("/report", reportHandler.HandleRegisterReport)
-  router.GET("/report", reportHandler.HandleGet)
-  router.PUT("/report", reportHandler.HandleUpdate)
-  router.DELETE("/report", reportHandler.HandleEject)
-  router.Run(":8080")
+  if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+    log.Fatalf("Invalid trusted_proxies: %v", err)
+  }
+  rateLimitStore := ratelimit.NewMemoryStore()
+  router.Use(rest.CORS(cfg.CORS))
+  router.Use(rest.BodyLimit(cfg.BodyLimit))
+  // Routes registered before Authenticate are reachable without a token, so
+  // they are limited per address.
+  public := router.Group("", rest.RateLimit(rateLimitStore, cfg.RateLimit, rest.ByClientIP))
+  public.POST("/login", sessionHandler.HandleLogin)
+  public.POST("/token/refresh", sessionHandler.HandleRefresh)
+  public.GET("/public/report/:token", shareLinkHandler.HandleOpen)
+  if cfg.OIDC.Issuer != "" {
+    public.GET("/oidc/login", oidcHandler.HandleLogin)
+    public.GET("/oidc/callback", oidcHandler.HandleCallback)
+  }
+  router.Use(rest.Authenticate(db, apiKeyApp, sessionApp, signatureApp, roleApp))
+  router.Use(rest.Tenant(db, organizationApp))
+  router.Use(rest.RateLimit(rateLimitStore, cfg.RateLimit, rest.ByUser))
+
+  reportsRead := rest.RequireScope(model.ScopeReportsRead)
+  reportsWrite := rest.RequireScope(model.ScopeReportsWrite)
+  usersRead := rest.RequireScope(model.ScopeUsersRead)
+  usersWrite := rest.RequireScope(model.ScopeUsersWrite)
+  usersAdmin := rest.RequireScope(model.ScopeUsersAdmin)
+
+  router.POST("/user", usersAdmin, userHandler.HandleRegisterUser)
+  router.GET("/user", usersRead, rest.Conditional(cfg.CacheControl["GET /user"]), userHandler.HandleGet)
+  router.PUT("/user", usersWrite, userHandler.HandleUpdate)
+  router.DELETE("/user", usersAdmin, userHandler.HandleDelete)
+  router.PUT("/user/id", usersAdmin, userHandler.HandleRename)
+  router.PUT("/user/status", usersAdmin, userHandler.HandleSetStatus)
+  router.GET("/users", usersRead, userHandler.HandleList)
+  router.GET("/users/:id/stats", reportsRead, reportHandler.HandleUserStats)
+  router.PUT("/user/password", rest.RejectSuspended, sessionHandler.HandleChangePassword)
+  router.GET("/user/role", roleHandler.HandleGet)
+  router.PUT("/user/role", usersAdmin, roleHandler.HandleAssign)
+  router.GET("/me/permissions", roleHandler.HandleGetPermissions)
+  router.GET("/organization", organizationHandler.HandleGet)
+  router.POST("/user/import", usersAdmin, importHandler.HandleImportUsers)
+  router.POST("/report", reportsWrite, reportHandler.HandleRegisterReport)
+  router.GET("/report", reportsRead, rest.Conditional(cfg.CacheControl["GET /report"]), reportHandler.HandleGet)
+  router.PUT("/report", reportsWrite, reportHandler.HandleUpdate)
+  router.DELETE("/report", reportsWrite, reportHandler.HandleEject)
+  router.POST("/report/import", reportsWrite, importHandler.HandleImportReports)
+  router.POST("/report/share", reportsWrite, shareHandler.HandleGrant)
+  router.GET("/report/share", reportsRead, shareHandler.HandleList)
+  router.DELETE("/report/share", reportsWrite, shareHandler.HandleRevoke)
+  router.GET("/report/shared", reportsRead, shareHandler.HandleSharedWithMe)
+  router.POST("/report/link", reportsWrite, shareLinkHandler.HandleCreate)
+  router.GET("/report/link", reportsRead, shareLinkHandler.HandleList)
+  router.DELETE("/report/link", reportsWrite, shareLinkHandler.HandleRevoke)
+  router.GET("/changes", reportsRead, reportHandler.HandleGetChanges)
+  router.POST("/rpc", rpcHandler.HandleRPC)
+  router.GET("/events", reportsRead, eventHandler.HandleStream)
+  router.POST("/webhook", usersAdmin, webhookHandler.HandleRegisterWebhook)
+  router.GET("/webhook", usersAdmin, webhookHandler.HandleGet)
+  router.DELETE("/webhook", usersAdmin, webhookHandler.HandleDelete)
+  router.GET("/webhook/deliveries", usersAdmin, webhookHandler.HandleGetDeliveries)
+  router.POST("/webhook/redeliver", usersAdmin, webhookHandler.HandleRedeliver)
+  router.POST("/apikey", rest.RejectSuspended, apiKeyHandler.HandleIssue)
+  router.GET("/apikey", apiKeyHandler.HandleList)
+  router.DELETE("/apikey", apiKeyHandler.HandleRevoke)
+  router.POST("/logout", sessionHandler.HandleLogout)
+  router.GET("/audit", usersAdmin, auditHandler.HandleList)
+  scimUsers := router.Group("/scim/v2/Users", usersAdmin)
+  scimUsers.POST("", scimHandler.HandleCreate)
+  scimUsers.GET("", scimHandler.HandleList)
+  scimUsers.GET("/:id", scimHandler.HandleGet)
+  scimUsers.PUT("/:id", scimHandler.HandleReplace)
+  scimUsers.PATCH("/:id", scimHandler.HandlePatch)
+  scimUsers.DELETE("/:id", scimHandler.HandleDelete)
+  return router, webhookApp
+}
+
+// sessionConfig converts the session settings, generating a signing secret if
+// the config has none.
+func sessionConfig(cfg config.SessionConfig) application.SessionConfig {
+	secret := []byte(cfg.JWTSecret)
+	if len(secret) == 0 {
+		log.Println("No session.jwt_secret configured; using a random one, so sessions end when the server restarts")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate JWT secret: %v", err)
+		}
+	}
+	return application.SessionConfig{
+		Secret:           secret,
+		AccessTokenTTL:   time.Duration(cfg.AccessTokenTTLSeconds) * time.Second,
+		RefreshTokenTTL:  time.Duration(cfg.RefreshTokenTTLSeconds) * time.Second,
+		MaxLoginAttempts: cfg.MaxLoginAttempts,
+		Lockout:          time.Duration(cfg.LockoutSeconds) * time.Second,
+	}
+}
+
+// shareLinkConfig converts the share link settings, generating a signing
+// secret if the config has none.
+func shareLinkConfig(cfg config.ShareLinkConfig) application.ShareLinkConfig {
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		log.Println("No share_link.secret configured; using a random one, so share links stop working when the server restarts")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate share link secret: %v", err)
+		}
+	}
+	return application.ShareLinkConfig{
+		Secret:     secret,
+		DefaultTTL: time.Duration(cfg.DefaultTTLSeconds) * time.Second,
+		MaxTTL:     time.Duration(cfg.MaxTTLSeconds) * time.Second,
+	}
+}
+
+// oidcConfig converts the OpenID Connect settings. The login state is signed
+// with the same secret as the access tokens.
+func oidcConfig(cfg config.OIDCConfig, secret []byte) application.OIDCConfig {
+	return application.OIDCConfig{
+		Issuer:                cfg.Issuer,
+		ClientID:              cfg.ClientID,
+		ClientSecret:          cfg.ClientSecret,
+		RedirectURL:           cfg.RedirectURL,
+		Scopes:                cfg.Scopes,
+		AuthorizationEndpoint: cfg.AuthorizationEndpoint,
+		TokenEndpoint:         cfg.TokenEndpoint,
+		JWKSURI:               cfg.JWKSURI,
+		OrgID:                 cfg.Organization,
+		AutoProvision:         cfg.AutoProvision,
+		StateSecret:           secret,
+		LoginTTL:              time.Duration(cfg.LoginTTLSeconds) * time.Second,
+		JWKSCacheTTL:          time.Duration(cfg.JWKSCacheTTLSeconds) * time.Second,
+	}
 }
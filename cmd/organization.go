@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/infra"
+	"repo-api/src/infra/persistence"
+)
+
+// runOrganization implements `org -id ID -name NAME` and returns the process
+// exit code. Organizations are only created here: no API caller belongs to
+// more than one organization, so none may create another.
+func runOrganization(args []string) int {
+	flags := flag.NewFlagSet("org", flag.ContinueOnError)
+	id := flags.String("id", "", "ID of the organization")
+	name := flags.String("name", "", "name of the organization")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: org -id ID -name NAME")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 || *id == "" || *name == "" {
+		flags.Usage()
+		return 2
+	}
+
+	db, err := database.NewDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	organizationApp := application.NewOrganizationApp(persistence.NewOrganizationPersistence(), persistence.NewUserPersistence())
+	organization, err := organizationApp.Create(db, *id, *name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create organization %s: %v\n", *id, err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(struct {
+		Organization model.Organization `json:"organization"`
+	}{organization})
+	return 0
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+// policyCallers are who each report route is called as: the author of the
+// reports, another student, a teacher, who has reports:read_all, and an
+// administrator.
+var policyCallers = []string{"author", "other", "teacher", "admin"}
+
+// The callers each policy lets through for the private report.
+var (
+	canRead   = []string{"author", "teacher", "admin"}
+	canModify = []string{"author", "admin"}
+	canActAs  = []string{"author", "admin"}
+)
+
+// policyFixture has a private and a public report of author. The private
+// one is shared with reader and has a link created by its author.
+type policyFixture struct {
+	*testServer
+	tokens map[string]string
+}
+
+func newPolicyFixture(t *testing.T) policyFixture {
+	s := newTestServer(t)
+	f := policyFixture{testServer: s, tokens: map[string]string{
+		"author":  s.addUser("default", "author", model.RoleStudent),
+		"other":   s.addUser("default", "other", model.RoleStudent),
+		"teacher": s.addUser("default", "teacher", model.RoleTeacher),
+		"admin":   s.addUser("default", "admin", model.RoleAdmin),
+	}}
+	s.addUser("default", "reader", model.RoleStudent)
+	s.addReport("default", "author", "private", model.VisibilityPrivate)
+	s.addReport("default", "author", "public", model.VisibilityPublic)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, s.repos.shares.Upsert(s.db, model.ReportShare{ReportID: "private", UserID: "reader", Level: model.ShareRead, GrantedBy: "author", CreatedAt: now}))
+	require.NoError(t, s.repos.shareLinks.Insert(s.db, model.ShareLink{ID: "link", ReportID: "private", OrgID: "default", CreatedBy: "author", ExpiresAt: now.Add(time.Hour), CreatedAt: now}))
+	return f
+}
+
+// snapshot is everything the routes could change about author's reports.
+func (f policyFixture) snapshot() string {
+	f.t.Helper()
+	reports, err := f.repos.reports.GetByAuthorID(f.db, "default", "author")
+	require.NoError(f.t, err)
+	shares, err := f.repos.shares.GetByReportID(f.db, "private")
+	require.NoError(f.t, err)
+	links, err := f.repos.shareLinks.GetByReportID(f.db, "private")
+	require.NoError(f.t, err)
+	snapshot, err := json.Marshal([]interface{}{reports, shares, links})
+	require.NoError(f.t, err)
+	return string(snapshot)
+}
+
+// status is the status of a response, or for JSON-RPC the status of the
+// error it carries.
+func status(t *testing.T, path string, w *httptest.ResponseRecorder) int {
+	t.Helper()
+	if path != "/rpc" || w.Code != http.StatusOK {
+		return w.Code
+	}
+	var response struct {
+		Error *struct {
+			Data struct {
+				Status int `json:"status"`
+			} `json:"data"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	if response.Error == nil {
+		return http.StatusOK
+	}
+	return response.Error.Data.Status
+}
+
+// TestReportPolicy calls every route that reads, changes or acts on behalf
+// of the author of a report as each caller, and expects the callers the
+// route's policy allows to succeed and the rest to be refused without
+// anything changing.
+func TestReportPolicy(t *testing.T) {
+	const newReport = `"author_id":"author","title":"new","count":800,"style":"essay","language":"ja"`
+	cases := []struct {
+		name, method, path, contentType, body string
+		allowed                               []string
+		// denied is the status of the callers who are refused.
+		denied int
+	}{
+		// CanReadReport
+		{"get private report", "GET", "/report?id=private", "", "", canRead, http.StatusForbidden},
+		{"get public report", "GET", "/report?id=public", "", "", policyCallers, 0},
+		{"rpc get private report", "POST", "/rpc", "", `{"jsonrpc":"2.0","method":"ReportApp.Get","params":{"id":"private"},"id":1}`, canRead, http.StatusForbidden},
+
+		// CanModifyReport
+		{"update private report", "PUT", "/report", "", `{"id":"private","title":"changed"}`, canModify, http.StatusForbidden},
+		{"update public report", "PUT", "/report", "", `{"id":"public","title":"changed"}`, canModify, http.StatusForbidden},
+		{"make report public", "PUT", "/report", "", `{"id":"private","visibility":"public"}`, canModify, http.StatusForbidden},
+		{"rpc update report", "POST", "/rpc", "", `{"jsonrpc":"2.0","method":"ReportApp.Update","params":{"id":"private","title":"changed"},"id":1}`, canModify, http.StatusForbidden},
+		{"eject report", "DELETE", "/report?id=private", "", "", canModify, http.StatusForbidden},
+		{"eject public report", "DELETE", "/report?id=public", "", "", canModify, http.StatusForbidden},
+		{"rpc eject report", "POST", "/rpc", "", `{"jsonrpc":"2.0","method":"ReportApp.Eject","params":{"id":"private"},"id":1}`, canModify, http.StatusForbidden},
+		{"grant share", "POST", "/report/share", "", `{"report_id":"private","user_id":"reader","level":"edit"}`, canModify, http.StatusForbidden},
+		{"list shares", "GET", "/report/share?report_id=private", "", "", canModify, http.StatusForbidden},
+		{"revoke share", "DELETE", "/report/share?report_id=private&user_id=reader", "", "", canModify, http.StatusForbidden},
+		{"create link", "POST", "/report/link", "", `{"report_id":"private"}`, canModify, http.StatusForbidden},
+		{"list links", "GET", "/report/link?report_id=private", "", "", canModify, http.StatusForbidden},
+		{"revoke link", "DELETE", "/report/link?id=link", "", "", canModify, http.StatusForbidden},
+
+		// CanActAsAuthor
+		{"register report", "POST", "/report", "", `{` + newReport + `}`, canActAs, http.StatusForbidden},
+		{"rpc register report", "POST", "/rpc", "", `{"jsonrpc":"2.0","method":"ReportApp.Register","params":{` + newReport + `},"id":1}`, canActAs, http.StatusForbidden},
+		{"import report", "POST", "/report/import", "text/csv", "id,author_id,title,count,style,language\nnew,author,new,800,essay,ja\n", canActAs, http.StatusUnprocessableEntity},
+		{"changes", "GET", "/changes?author_id=author", "", "", canActAs, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		for _, caller := range policyCallers {
+			t.Run(c.name+" as "+caller, func(t *testing.T) {
+				f := newPolicyFixture(t)
+				contentType := c.contentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				before := f.snapshot()
+				w := f.doRaw(f.tokens[caller], c.method, c.path, contentType, c.body)
+				if slices.Contains(c.allowed, caller) {
+					assert.Equal(t, http.StatusOK, status(t, c.path, w), w.Body.String())
+					return
+				}
+				assert.Equal(t, c.denied, status(t, c.path, w), w.Body.String())
+				assert.Equal(t, before, f.snapshot(), "refused request changed reports")
+			})
+		}
+	}
+}
+
+// TestReportPolicyListings checks that listings leave out the private report
+// for callers CanReadReport refuses.
+func TestReportPolicyListings(t *testing.T) {
+	f := newPolicyFixture(t)
+	for _, caller := range policyCallers {
+		t.Run(caller, func(t *testing.T) {
+			var response struct {
+				Reports []model.Report `json:"reports"`
+			}
+			decode(t, f.do(f.tokens[caller], "GET", "/report?author_id=author", ""), http.StatusOK, &response)
+			var IDs []string
+			for _, report := range response.Reports {
+				IDs = append(IDs, report.ID)
+			}
+			if slices.Contains(canRead, caller) {
+				assert.ElementsMatch(t, []string{"private", "public"}, IDs)
+			} else {
+				assert.ElementsMatch(t, []string{"public"}, IDs)
+			}
+
+			var stats model.ReportStats
+			decode(t, f.do(f.tokens[caller], "GET", "/users/author/stats", ""), http.StatusOK, &stats)
+			if slices.Contains(canRead, caller) {
+				assert.Equal(t, 2, stats.Reports)
+			} else {
+				assert.Equal(t, 1, stats.Reports)
+			}
+		})
+	}
+
+	// A share lets its user read the report, but not change it.
+	reader := f.addUser("default", "reader2", model.RoleStudent)
+	requireStatus(t, f.do(f.tokens["author"], "POST", "/report/share", `{"report_id":"private","user_id":"reader2","level":"read"}`), http.StatusOK)
+	requireStatus(t, f.do(reader, "GET", "/report?id=private", ""), http.StatusOK)
+	requireStatus(t, f.do(reader, "PUT", "/report", `{"id":"private","title":"changed"}`), http.StatusForbidden)
+	requireStatus(t, f.do(reader, "GET", "/report/share?report_id=private", ""), http.StatusForbidden)
+}
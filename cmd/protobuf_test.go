@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/pb"
+)
+
+// doProto sends message, if any, and asks for a protobuf response.
+func (s *testServer) doProto(token, method, path string, message proto.Message) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body io.Reader
+	if message != nil {
+		data, err := proto.Marshal(message)
+		require.NoError(s.t, err)
+		body = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, body)
+	if message != nil {
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	}
+	req.Header.Set("Accept", "application/x-protobuf")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeProto(t *testing.T, w *httptest.ResponseRecorder, status int, message proto.Message) {
+	t.Helper()
+	require.Equal(t, status, w.Code, w.Body.String())
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), message))
+}
+
+func TestProtobufUserProfile(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+
+	registered := &pb.User{
+		Id:              "sato",
+		Name:            proto.String("佐藤"),
+		Email:           proto.String("sato@example.com"),
+		DisplayName:     proto.String("さとう"),
+		Affiliation:     proto.String("1年A組"),
+		TimeZone:        proto.String("Asia/Tokyo"),
+		DefaultStyle:    proto.String("essay"),
+		DefaultLanguage: proto.String("ja"),
+		DefaultCount:    proto.Int32(800),
+	}
+	requireStatus(t, s.doProto(admin, "POST", "/user", registered), http.StatusOK)
+
+	// Only the fields sent are changed.
+	update := &pb.User{Id: "sato", Email: proto.String("taro@example.com"), DefaultCount: proto.Int32(0)}
+	requireStatus(t, s.doProto(admin, "PUT", "/user", update), http.StatusOK)
+
+	var response pb.UserResponse
+	decodeProto(t, s.doProto(admin, "GET", "/user?id=sato", nil), http.StatusOK, &response)
+	want := proto.Clone(registered).(*pb.User)
+	want.Status = proto.String(model.UserActive)
+	want.Email = proto.String("taro@example.com")
+	want.DefaultCount = proto.Int32(0)
+	assert.True(t, proto.Equal(want, response.GetUser()), "got %v", response.GetUser())
+
+	// Validation errors are the same as over JSON.
+	var failure pb.Error
+	decodeProto(t, s.doProto(admin, "PUT", "/user", &pb.User{Id: "sato", Email: proto.String("not an address")}), http.StatusBadRequest, &failure)
+	assert.Equal(t, "Email must be a valid email address", failure.GetError())
+	decodeProto(t, s.doProto(admin, "PUT", "/user", &pb.User{Id: "sato", Name: proto.String("")}), http.StatusBadRequest, &failure)
+	assert.Equal(t, "Name must not be empty", failure.GetError())
+}
+
+// TestProtobufErrors checks that errors follow the Accept header.
+func TestProtobufErrors(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+	student := s.addUser("default", "student", model.RoleStudent)
+
+	cases := []struct {
+		token, method, path, body string
+		status                    int
+		error                     string
+	}{
+		{student, "DELETE", "/apikey", "", http.StatusBadRequest, "ID is required"},
+		{student, "GET", "/apikey?user_id=other", "", http.StatusForbidden, "Cannot list API keys of another user"},
+		{student, "POST", "/logout", "", http.StatusBadRequest, "API keys cannot log out; revoke the key instead"},
+		{student, "PUT", "/user/password", `{"current_password":"x"}`, http.StatusBadRequest, "NewPassword is required"},
+		{admin, "DELETE", "/webhook", "", http.StatusBadRequest, "ID is required"},
+		{admin, "GET", "/webhook/deliveries", "", http.StatusBadRequest, "WebhookID is required"},
+		{admin, "GET", "/webhook?id=missing", "", http.StatusNotFound, "Webhook not found"},
+	}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/x-protobuf")
+			req.Header.Set("Authorization", "Bearer "+c.token)
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			var failure pb.Error
+			decodeProto(t, w, c.status, &failure)
+			assert.Equal(t, c.error, failure.GetError())
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			Status int `json:"status"`
+		} `json:"data"`
+	} `json:"error"`
+}
+
+// call calls method over JSON-RPC with params.
+func (s *testServer) call(token, method, params string) rpcResponse {
+	s.t.Helper()
+	var response rpcResponse
+	decode(s.t, s.do(token, "POST", "/rpc", `{"jsonrpc":"2.0","method":"`+method+`","params":`+params+`,"id":1}`), http.StatusOK, &response)
+	return response
+}
+
+func TestRPCUserProfile(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+
+	response := s.call(admin, "UserApp.Register", `{"id":"sato","name":"佐藤","email":"sato@example.com","time_zone":"Asia/Tokyo","default_count":800}`)
+	require.Nil(t, response.Error)
+	response = s.call(admin, "UserApp.Update", `{"id":"sato","affiliation":"1年A組"}`)
+	require.Nil(t, response.Error)
+
+	var user model.User
+	response = s.call(admin, "UserApp.Get", `{"id":"sato"}`)
+	require.Nil(t, response.Error)
+	require.NoError(t, json.Unmarshal(response.Result, &user))
+	assert.Equal(t, "佐藤", user.Name)
+	assert.Equal(t, "sato@example.com", user.Email)
+	assert.Equal(t, "Asia/Tokyo", user.TimeZone)
+	assert.Equal(t, 800, user.DefaultCount)
+	assert.Equal(t, "1年A組", user.Affiliation)
+}
+
+// TestRPCUserErrors checks that invalid users are answered as over REST.
+func TestRPCUserErrors(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+	s.addUser("default", "sato", model.RoleStudent)
+
+	cases := []struct {
+		name, restMethod, rpcMethod, params string
+	}{
+		{"invalid email", "PUT", "UserApp.Update", `{"id":"sato","email":"not an address"}`},
+		{"invalid time zone", "PUT", "UserApp.Update", `{"id":"sato","time_zone":"Mars/Olympus"}`},
+		{"invalid default count", "PUT", "UserApp.Update", `{"id":"sato","default_count":-1}`},
+		{"name required", "PUT", "UserApp.Update", `{"id":"sato","name":""}`},
+		{"register invalid email", "POST", "UserApp.Register", `{"id":"suzuki","name":"鈴木","email":"not an address"}`},
+		{"register invalid status", "POST", "UserApp.Register", `{"id":"suzuki","name":"鈴木","status":"asleep"}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var rest struct {
+				Error string `json:"error"`
+			}
+			decode(t, s.do(admin, c.restMethod, "/user", c.params), http.StatusBadRequest, &rest)
+
+			response := s.call(admin, c.rpcMethod, c.params)
+			require.NotNil(t, response.Error)
+			assert.Equal(t, -32602, response.Error.Code)
+			assert.Equal(t, http.StatusBadRequest, response.Error.Data.Status)
+			assert.Equal(t, rest.Error, response.Error.Message)
+		})
+	}
+}
+
+// TestRPCInactiveAuthor checks that reports of suspended and deactivated
+// authors are refused with the same status as over REST.
+func TestRPCInactiveAuthor(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+	for _, status := range []string{model.UserSuspended, model.UserDeactivated} {
+		t.Run(status, func(t *testing.T) {
+			s.addUser("default", status, model.RoleStudent)
+			requireStatus(t, s.do(admin, "PUT", "/user/status", `{"id":"`+status+`","status":"`+status+`"}`), http.StatusOK)
+			report := `{"author_id":"` + status + `","title":"t","count":800,"style":"essay","language":"ja"}`
+
+			w := s.do(admin, "POST", "/report", report)
+			requireStatus(t, w, http.StatusConflict)
+			response := s.call(admin, "ReportApp.Register", report)
+			require.NotNil(t, response.Error)
+			assert.Equal(t, -32009, response.Error.Code)
+			assert.Equal(t, http.StatusConflict, response.Error.Data.Status)
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+type scimUser struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	UserName    string   `json:"userName"`
+	DisplayName string   `json:"displayName"`
+	Name        struct {
+		Formatted string `json:"formatted"`
+	} `json:"name"`
+	Active *bool `json:"active"`
+	Meta   struct {
+		ResourceType string `json:"resourceType"`
+		Location     string `json:"location"`
+		LastModified string `json:"lastModified"`
+	} `json:"meta"`
+}
+
+type scimList struct {
+	Schemas      []string   `json:"schemas"`
+	TotalResults int        `json:"totalResults"`
+	StartIndex   int        `json:"startIndex"`
+	ItemsPerPage int        `json:"itemsPerPage"`
+	Resources    []scimUser `json:"Resources"`
+}
+
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType"`
+	Detail   string   `json:"detail"`
+}
+
+const (
+	scimUserSchema  = `"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"]`
+	scimPatchSchema = `"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"]`
+)
+
+// scimFixture is an administrator provisioning users over SCIM.
+type scimFixture struct {
+	*testServer
+	admin string
+}
+
+func newSCIMFixture(t *testing.T) scimFixture {
+	s := newTestServer(t)
+	return scimFixture{testServer: s, admin: s.addUser("default", "admin", model.RoleAdmin)}
+}
+
+func (f scimFixture) scim(method, path, body string) *httptest.ResponseRecorder {
+	f.t.Helper()
+	return f.doRaw(f.admin, method, "/scim/v2/Users"+path, "application/scim+json", body)
+}
+
+// create provisions a user and returns the resource.
+func (f scimFixture) create(body string) scimUser {
+	f.t.Helper()
+	var user scimUser
+	decode(f.t, f.scim("POST", "", body), http.StatusCreated, &user)
+	return user
+}
+
+func requireSCIMError(t *testing.T, w *httptest.ResponseRecorder, status int, scimType string) {
+	t.Helper()
+	var response scimError
+	decode(t, w, status, &response)
+	assert.Equal(t, "application/scim+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"urn:ietf:params:scim:api:messages:2.0:Error"}, response.Schemas)
+	assert.Equal(t, strconv.Itoa(status), response.Status)
+	assert.Equal(t, scimType, response.SCIMType)
+}
+
+func (f scimFixture) user(ID string) model.User {
+	f.t.Helper()
+	user, err := f.repos.users.GetByID(f.db, "default", ID)
+	require.NoError(f.t, err)
+	return user
+}
+
+func TestSCIMCreate(t *testing.T) {
+	f := newSCIMFixture(t)
+
+	w := f.scim("POST", "", `{`+scimUserSchema+`,"userName":"sato","displayName":"佐藤 花子","externalId":"hr-1"}`)
+	var created scimUser
+	decode(t, w, http.StatusCreated, &created)
+	assert.Equal(t, "application/scim+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "http://example.com/scim/v2/Users/sato", w.Header().Get("Location"))
+	assert.Equal(t, []string{"urn:ietf:params:scim:schemas:core:2.0:User"}, created.Schemas)
+	assert.Equal(t, "sato", created.ID)
+	assert.Equal(t, "sato", created.UserName)
+	assert.Equal(t, "佐藤 花子", created.DisplayName)
+	assert.Equal(t, "佐藤 花子", created.Name.Formatted)
+	require.NotNil(t, created.Active)
+	assert.True(t, *created.Active)
+	assert.Equal(t, "User", created.Meta.ResourceType)
+	assert.Equal(t, "http://example.com/scim/v2/Users/sato", created.Meta.Location)
+	assert.NotEmpty(t, created.Meta.LastModified)
+	assert.Equal(t, model.UserActive, f.user("sato").Status)
+
+	t.Run("name from family and given names", func(t *testing.T) {
+		user := f.create(`{` + scimUserSchema + `,"userName":"suzuki","name":{"familyName":"鈴木","givenName":"一郎"}}`)
+		assert.Equal(t, "鈴木 一郎", user.DisplayName)
+	})
+	t.Run("name defaults to userName", func(t *testing.T) {
+		user := f.create(`{` + scimUserSchema + `,"userName":"tanaka"}`)
+		assert.Equal(t, "tanaka", user.DisplayName)
+	})
+	t.Run("inactive", func(t *testing.T) {
+		user := f.create(`{` + scimUserSchema + `,"userName":"ito","active":false}`)
+		require.NotNil(t, user.Active)
+		assert.False(t, *user.Active)
+		assert.Equal(t, model.UserDeactivated, f.user("ito").Status)
+		// The user is inserted deactivated rather than deactivated after.
+		w := f.do(f.admin, "GET", "/audit", "")
+		requireStatus(t, w, http.StatusOK)
+		assert.NotContains(t, w.Body.String(), model.AuditUserStatusChanged)
+	})
+	t.Run("without userName", func(t *testing.T) {
+		requireSCIMError(t, f.scim("POST", "", `{`+scimUserSchema+`,"displayName":"x"}`), http.StatusBadRequest, "invalidValue")
+	})
+	t.Run("taken userName", func(t *testing.T) {
+		requireSCIMError(t, f.scim("POST", "", `{`+scimUserSchema+`,"userName":"sato"}`), http.StatusConflict, "uniqueness")
+	})
+	t.Run("invalid JSON", func(t *testing.T) {
+		requireSCIMError(t, f.scim("POST", "", `{"userName":`), http.StatusBadRequest, "invalidSyntax")
+	})
+}
+
+func TestSCIMGet(t *testing.T) {
+	f := newSCIMFixture(t)
+	f.create(`{` + scimUserSchema + `,"userName":"sato","displayName":"佐藤 花子"}`)
+
+	var user scimUser
+	decode(t, f.scim("GET", "/sato", ""), http.StatusOK, &user)
+	assert.Equal(t, "sato", user.UserName)
+	assert.Equal(t, "佐藤 花子", user.DisplayName)
+
+	requireSCIMError(t, f.scim("GET", "/missing", ""), http.StatusNotFound, "")
+}
+
+func TestSCIMList(t *testing.T) {
+	f := newSCIMFixture(t)
+	f.create(`{` + scimUserSchema + `,"userName":"sato","displayName":"Sato Hanako"}`)
+	f.create(`{` + scimUserSchema + `,"userName":"suzuki","displayName":"Suzuki Ichiro","active":false}`)
+	f.create(`{` + scimUserSchema + `,"userName":"back\\slash","displayName":"say \"hi\""}`)
+
+	list := func(t *testing.T, query string) scimList {
+		t.Helper()
+		var response scimList
+		decode(t, f.scim("GET", query, ""), http.StatusOK, &response)
+		assert.Equal(t, []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"}, response.Schemas)
+		return response
+	}
+	userNames := func(response scimList) []string {
+		names := []string{}
+		for _, user := range response.Resources {
+			names = append(names, user.UserName)
+		}
+		return names
+	}
+
+	all := list(t, "")
+	assert.Equal(t, 4, all.TotalResults)
+	assert.Equal(t, 1, all.StartIndex)
+	assert.Equal(t, 4, all.ItemsPerPage)
+
+	for _, c := range []struct {
+		filter string
+		want   []string
+	}{
+		{`userName eq "sato"`, []string{"sato"}},
+		{`USERNAME EQ "SATO"`, []string{"sato"}},
+		{`id eq "suzuki"`, []string{"suzuki"}},
+		{`userName sw "s" and active eq true`, []string{"sato"}},
+		{`active eq false`, []string{"suzuki"}},
+		{`active ne true`, []string{"suzuki"}},
+		{`displayName co "hanako"`, []string{"sato"}},
+		{`name.formatted ew "ichiro"`, []string{"suzuki"}},
+		{`userName ne "admin" and userName ne "sato"`, []string{"back\\slash", "suzuki"}},
+		{`userName eq "back\\slash"`, []string{"back\\slash"}},
+		{`userName ew "\\slash"`, []string{"back\\slash"}},
+		{`displayName eq "say \"hi\""`, []string{"back\\slash"}},
+		{`userName eq "nobody"`, []string{}},
+	} {
+		t.Run(c.filter, func(t *testing.T) {
+			response := list(t, "?filter="+url.QueryEscape(c.filter))
+			assert.ElementsMatch(t, c.want, userNames(response))
+			assert.Equal(t, len(c.want), response.TotalResults)
+		})
+	}
+
+	t.Run("page", func(t *testing.T) {
+		page := list(t, "?startIndex=2&count=2")
+		assert.Equal(t, 4, page.TotalResults)
+		assert.Equal(t, 2, page.StartIndex)
+		assert.Equal(t, 2, page.ItemsPerPage)
+		assert.Equal(t, userNames(all)[1:3], userNames(page))
+
+		page = list(t, "?startIndex=0&count=-1")
+		assert.Equal(t, 1, page.StartIndex)
+		assert.Empty(t, page.Resources)
+	})
+
+	for _, filter := range []string{
+		`userName gt "a"`,
+		`userName eq "a`,
+		`userName eq "a\"`,
+		`title eq "a"`,
+		`userName eq sato`,
+		`active eq "true"`,
+		`active co true`,
+		`userName eq "a" or userName eq "b"`,
+		`userName eq "a" and`,
+		`userName`,
+	} {
+		t.Run("invalid "+filter, func(t *testing.T) {
+			requireSCIMError(t, f.scim("GET", "?filter="+url.QueryEscape(filter), ""), http.StatusBadRequest, "invalidFilter")
+		})
+	}
+}
+
+func TestSCIMReplace(t *testing.T) {
+	f := newSCIMFixture(t)
+	f.create(`{` + scimUserSchema + `,"userName":"sato","displayName":"佐藤 花子"}`)
+
+	var user scimUser
+	decode(t, f.scim("PUT", "/sato", `{`+scimUserSchema+`,"userName":"sato","displayName":"佐藤 華子","active":false}`), http.StatusOK, &user)
+	assert.Equal(t, "佐藤 華子", user.DisplayName)
+	assert.False(t, *user.Active)
+	assert.Equal(t, model.UserDeactivated, f.user("sato").Status)
+
+	// Without active the status is kept.
+	decode(t, f.scim("PUT", "/sato", `{`+scimUserSchema+`,"name":{"formatted":"佐藤 花子"}}`), http.StatusOK, &user)
+	assert.Equal(t, "佐藤 花子", user.DisplayName)
+	assert.False(t, *user.Active)
+
+	decode(t, f.scim("PUT", "/sato", `{`+scimUserSchema+`,"displayName":"佐藤 花子","active":true}`), http.StatusOK, &user)
+	assert.True(t, *user.Active)
+	assert.Equal(t, model.UserActive, f.user("sato").Status)
+
+	requireSCIMError(t, f.scim("PUT", "/sato", `{`+scimUserSchema+`,"userName":"other","displayName":"x"}`), http.StatusBadRequest, "mutability")
+	requireSCIMError(t, f.scim("PUT", "/sato", `{`+scimUserSchema+`,"userName":"sato"}`), http.StatusBadRequest, "invalidValue")
+	requireSCIMError(t, f.scim("PUT", "/missing", `{`+scimUserSchema+`,"displayName":"x"}`), http.StatusNotFound, "")
+}
+
+func TestSCIMPatch(t *testing.T) {
+	f := newSCIMFixture(t)
+	f.create(`{` + scimUserSchema + `,"userName":"sato","displayName":"佐藤 花子"}`)
+	patch := func(operations string) *httptest.ResponseRecorder {
+		return f.scim("PATCH", "/sato", `{`+scimPatchSchema+`,"Operations":`+operations+`}`)
+	}
+
+	var user scimUser
+	decode(t, patch(`[{"op":"replace","path":"active","value":"False"}]`), http.StatusOK, &user)
+	assert.False(t, *user.Active)
+	assert.Equal(t, model.UserDeactivated, f.user("sato").Status)
+
+	decode(t, patch(`[{"op":"Replace","value":{"active":true,"displayName":"佐藤 華子"}}]`), http.StatusOK, &user)
+	assert.True(t, *user.Active)
+	assert.Equal(t, "佐藤 華子", user.DisplayName)
+
+	decode(t, patch(`[{"op":"add","path":"name","value":{"familyName":"佐藤","givenName":"花子"}},{"op":"replace","path":"userName","value":"sato"}]`), http.StatusOK, &user)
+	assert.Equal(t, "佐藤 花子", user.DisplayName)
+
+	t.Run("suspension is kept", func(t *testing.T) {
+		requireStatus(t, f.do(f.admin, "PUT", "/user/status", `{"id":"sato","status":"suspended"}`), http.StatusOK)
+		decode(t, patch(`[{"op":"replace","path":"active","value":true}]`), http.StatusOK, &user)
+		assert.True(t, *user.Active)
+		assert.Equal(t, model.UserSuspended, f.user("sato").Status)
+	})
+
+	for _, c := range []struct {
+		name, operations, scimType string
+	}{
+		{"remove", `[{"op":"remove","path":"displayName"}]`, "mutability"},
+		{"unknown op", `[{"op":"move","path":"displayName"}]`, "invalidSyntax"},
+		{"unknown path", `[{"op":"replace","path":"emails","value":[]}]`, "invalidPath"},
+		{"non-boolean active", `[{"op":"replace","path":"active","value":"maybe"}]`, "invalidValue"},
+		{"empty name", `[{"op":"replace","path":"displayName","value":""}]`, "invalidValue"},
+		{"other userName", `[{"op":"replace","path":"userName","value":"other"}]`, "mutability"},
+		{"value not an object", `[{"op":"replace","value":"x"}]`, "invalidValue"},
+		{"no operations", `[]`, "invalidValue"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			requireSCIMError(t, patch(c.operations), http.StatusBadRequest, c.scimType)
+		})
+	}
+	// A failed operation changes nothing, even after one that succeeded.
+	requireSCIMError(t, patch(`[{"op":"replace","path":"displayName","value":"x"},{"op":"remove","path":"active"}]`), http.StatusBadRequest, "mutability")
+	assert.Equal(t, "佐藤 花子", f.user("sato").Name)
+
+	requireSCIMError(t, f.scim("PATCH", "/missing", `{`+scimPatchSchema+`,"Operations":[{"op":"replace","path":"active","value":false}]}`), http.StatusNotFound, "")
+}
+
+func TestSCIMDelete(t *testing.T) {
+	f := newSCIMFixture(t)
+	f.create(`{` + scimUserSchema + `,"userName":"sato"}`)
+	f.create(`{` + scimUserSchema + `,"userName":"suzuki"}`)
+	f.addReport("default", "suzuki", "suzuki-report", model.VisibilityPrivate)
+
+	w := f.scim("DELETE", "/sato", "")
+	requireStatus(t, w, http.StatusNoContent)
+	assert.Empty(t, w.Body.String())
+	requireSCIMError(t, f.scim("GET", "/sato", ""), http.StatusNotFound, "")
+	requireSCIMError(t, f.scim("DELETE", "/sato", ""), http.StatusNotFound, "")
+
+	requireSCIMError(t, f.scim("DELETE", "/suzuki", ""), http.StatusConflict, "")
+	assert.Equal(t, model.UserActive, f.user("suzuki").Status)
+
+	requireSCIMError(t, f.scim("DELETE", "/admin", ""), http.StatusBadRequest, "mutability")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/infra/config"
+	"repo-api/src/infra/dbtest"
+	"repo-api/src/infra/event"
+	"repo-api/src/infra/memory"
+)
+
+// testServer is the full router over MySQL when dbtest.DSNVariable is set,
+// and otherwise over in-memory repositories, which are served without a
+// database as the applications only pass it on to the repositories.
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	db     *sql.DB
+	// store is nil over MySQL.
+	store *memory.Store
+	repos repositories
+	cfg   config.Config
+}
+
+func memoryRepositories(store *memory.Store) repositories {
+	return repositories{
+		users:         memory.NewUserRepository(store),
+		reports:       memory.NewReportRepository(store),
+		shares:        memory.NewShareRepository(store),
+		shareLinks:    memory.NewShareLinkRepository(store),
+		audit:         memory.NewAuditRepository(store),
+		webhooks:      memory.NewWebhookRepository(store),
+		apiKeys:       memory.NewAPIKeyRepository(store),
+		sessions:      memory.NewSessionRepository(store),
+		identities:    memory.NewIdentityRepository(store),
+		roles:         memory.NewRoleRepository(store),
+		organizations: memory.NewOrganizationRepository(store),
+	}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	return newTestServerWith(t, config.Default())
+}
+
+func newTestServerWith(t *testing.T, cfg config.Config) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg.Session.JWTSecret = "test secret"
+	cfg.ShareLink.Secret = "test secret"
+	// Tests make more requests per user than clients are allowed to.
+	cfg.RateLimit.Default.Limit = 1 << 20
+	s := &testServer{t: t, cfg: cfg}
+	if dbtest.Enabled() {
+		s.db = dbtest.Open(t, "repoapi_cmd")
+		s.repos = persistenceRepositories()
+	} else {
+		s.store = memory.NewStore()
+		s.repos = memoryRepositories(s.store)
+	}
+	s.router, _ = newRouter(s.db, cfg, s.repos, event.NewBroker(16))
+	return s
+}
+
+// requireStore skips tests that set the clock of the in-memory store.
+func (s *testServer) requireStore() {
+	s.t.Helper()
+	if s.store == nil {
+		s.t.Skip("sets the clock of the in-memory store")
+	}
+}
+
+// addOrganization creates organization ID.
+func (s *testServer) addOrganization(ID string) {
+	s.t.Helper()
+	require.NoError(s.t, s.repos.organizations.Insert(s.db, model.Organization{ID: ID, Name: ID}))
+}
+
+// addUser creates user ID of organization OrgID with role and returns the
+// token of a users:admin API key, which Authorize narrows to the scopes of
+// the role.
+func (s *testServer) addUser(OrgID, ID, role string) string {
+	s.t.Helper()
+	require.NoError(s.t, s.repos.users.Insert(s.db, model.User{ID: ID, Name: ID, OrgID: OrgID}))
+	require.NoError(s.t, s.repos.roles.UpdateRole(s.db, ID, role))
+	keys := application.NewAPIKeyApp(s.repos.apiKeys, s.repos.users)
+	_, token, err := keys.Issue(s.db, OrgID, ID, "test", []string{model.ScopeUsersAdmin}, nil)
+	require.NoError(s.t, err)
+	return token
+}
+
+// addReport creates a report of AuthorID and returns its ID.
+func (s *testServer) addReport(OrgID, AuthorID, ID, visibility string) string {
+	s.t.Helper()
+	require.NoError(s.t, s.repos.reports.Insert(s.db, ID, AuthorID, OrgID, 800, ID, "essay", "ja", visibility))
+	return ID
+}
+
+// do sends a request with a JSON body, if any, as the holder of token.
+func (s *testServer) do(token, method, path, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.doRaw(token, method, path, "application/json", body)
+}
+
+// doRaw sends a request with a body of contentType, if any, as the holder
+// of token, or anonymously if token is empty.
+func (s *testServer) doRaw(token, method, path, contentType, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals the body of a response that must have status.
+func decode(t *testing.T, w *httptest.ResponseRecorder, status int, v interface{}) {
+	t.Helper()
+	require.Equal(t, status, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), v), w.Body.String())
+}
+
+func requireStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	require.Equal(t, status, w.Code, w.Body.String())
+}
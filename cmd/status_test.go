@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+// TestDeactivatedReportCount checks that user report counts leave out the
+// public reports of deactivated authors for callers who cannot read them.
+func TestDeactivatedReportCount(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+	student := s.addUser("default", "student", model.RoleStudent)
+	s.addUser("default", "author", model.RoleStudent)
+	s.addReport("default", "author", "r1", model.VisibilityPublic)
+	s.addReport("default", "author", "r2", model.VisibilityPublic)
+	requireStatus(t, s.do(admin, "PUT", "/user/status", `{"id":"author","status":"deactivated"}`), http.StatusOK)
+	requireStatus(t, s.do(student, "GET", "/report?id=r1", ""), http.StatusForbidden)
+
+	count := func(token, path string) int {
+		t.Helper()
+		var page model.UserPage
+		decode(t, s.do(token, "GET", path, ""), http.StatusOK, &page)
+		for _, user := range page.Users {
+			if user.ID == "author" {
+				require.NotNil(t, user.ReportCount)
+				return *user.ReportCount
+			}
+		}
+		t.Fatalf("author missing from %s", path)
+		return 0
+	}
+	for _, path := range []string{"/users?with_report_count=true", "/users?with_report_count=true&sort=-report_count"} {
+		assert.Equal(t, 0, count(student, path), path)
+		assert.Equal(t, 2, count(admin, path), path)
+	}
+}
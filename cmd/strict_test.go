@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+// TestRequestFields checks that fields a client may not set are refused as
+// unknown, over REST and JSON-RPC, and change nothing.
+func TestRequestFields(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.addUser("default", "admin", model.RoleAdmin)
+	s.addUser("default", "author", model.RoleStudent)
+	s.addUser("default", "other", model.RoleStudent)
+	s.addReport("default", "author", "r1", model.VisibilityPublic)
+
+	cases := []struct {
+		name, method, path, rpcMethod, body, field string
+	}{
+		{"register report with id", "POST", "/report", "ReportApp.Register", `{"id":"chosen","author_id":"author","title":"t","count":800,"style":"essay","language":"ja"}`, "id"},
+		{"update report author", "PUT", "/report", "ReportApp.Update", `{"id":"r1","author_id":"other"}`, "author_id"},
+		{"register user with report count", "POST", "/user", "UserApp.Register", `{"id":"sato","name":"佐藤","report_count":3}`, "report_count"},
+		{"update user org", "PUT", "/user", "UserApp.Update", `{"id":"author","org_id":"other"}`, "org_id"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var rest struct {
+				Error string `json:"error"`
+			}
+			decode(t, s.do(admin, c.method, c.path, c.body), http.StatusBadRequest, &rest)
+			assert.Contains(t, rest.Error, `unknown field "`+c.field+`"`)
+
+			response := s.call(admin, c.rpcMethod, c.body)
+			require.NotNil(t, response.Error)
+			assert.Equal(t, -32602, response.Error.Code)
+			assert.Contains(t, response.Error.Message, `unknown field "`+c.field+`"`)
+		})
+	}
+
+	report, err := s.repos.reports.GetByID(s.db, "default", "r1")
+	require.NoError(t, err)
+	assert.Equal(t, "author", report.AuthorID)
+	taken, err := s.repos.users.IDTaken(s.db, "sato")
+	require.NoError(t, err)
+	assert.False(t, taken)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+)
+
+// tenantFixture is organization a, whose users probe organization b.
+type tenantFixture struct {
+	*testServer
+	aAdmin, aTeacher, aStudent string
+	bStudent                   string
+	bLink, bWebhook            string
+}
+
+func newTenantFixture(t *testing.T) tenantFixture {
+	s := newTestServer(t)
+	s.addOrganization("a")
+	s.addOrganization("b")
+	f := tenantFixture{
+		testServer: s,
+		aAdmin:     s.addUser("a", "a-admin", model.RoleAdmin),
+		aTeacher:   s.addUser("a", "a-teacher", model.RoleTeacher),
+		aStudent:   s.addUser("a", "a-student", model.RoleStudent),
+	}
+	bAdmin := s.addUser("b", "b-admin", model.RoleAdmin)
+	f.bStudent = s.addUser("b", "b-student", model.RoleStudent)
+	s.addReport("a", "a-student", "a-report", model.VisibilityPublic)
+	s.addReport("b", "b-student", "b-report", model.VisibilityPublic)
+
+	var link struct {
+		Link model.ShareLink `json:"link"`
+	}
+	decode(t, s.do(f.bStudent, "POST", "/report/link", `{"report_id":"b-report"}`), http.StatusOK, &link)
+	f.bLink = link.Link.ID
+	var webhook struct {
+		Webhook model.Webhook `json:"webhook"`
+	}
+	decode(t, s.do(bAdmin, "POST", "/webhook", `{"url":"https://hooks.example.com/b","events":["report.created"]}`), http.StatusOK, &webhook)
+	f.bWebhook = webhook.Webhook.ID
+	require.NotEmpty(t, f.bLink)
+	require.NotEmpty(t, f.bWebhook)
+	return f
+}
+
+// TestCrossTenantLookups requests every route that takes an ID with the ID
+// of something in another organization, and expects exactly the response
+// to an ID that does not exist.
+func TestCrossTenantLookups(t *testing.T) {
+	f := newTenantFixture(t)
+	cases := []struct {
+		name, method, path, body string
+		// id replaces {id} in path and body.
+		id string
+	}{
+		{"get user", "GET", "/user?id={id}", "", "b-student"},
+		{"update user", "PUT", "/user", `{"id":"{id}","name":"x"}`, "b-student"},
+		{"delete user", "DELETE", "/user?id={id}", "", "b-student"},
+		{"rename user", "PUT", "/user/id", `{"id":"{id}","new_id":"renamed"}`, "b-student"},
+		{"set user status", "PUT", "/user/status", `{"id":"{id}","status":"suspended"}`, "b-student"},
+		{"user stats", "GET", "/users/{id}/stats", "", "b-student"},
+		{"get role", "GET", "/user/role?user_id={id}", "", "b-student"},
+		{"assign role", "PUT", "/user/role", `{"user_id":"{id}","role":"teacher"}`, "b-student"},
+		{"list api keys", "GET", "/apikey?user_id={id}", "", "b-student"},
+		{"issue api key", "POST", "/apikey", `{"user_id":"{id}","name":"x","scopes":["reports:read"]}`, "b-student"},
+		{"get report", "GET", "/report?id={id}", "", "b-report"},
+		{"get reports of author", "GET", "/report?author_id={id}", "", "b-student"},
+		{"register report", "POST", "/report", `{"author_id":"{id}","title":"x","count":1,"style":"essay","language":"ja"}`, "b-student"},
+		{"update report", "PUT", "/report", `{"id":"{id}","title":"x"}`, "b-report"},
+		{"eject report", "DELETE", "/report?id={id}", "", "b-report"},
+		{"changes", "GET", "/changes?author_id={id}", "", "b-student"},
+		{"grant share", "POST", "/report/share", `{"report_id":"{id}","user_id":"a-student","level":"read"}`, "b-report"},
+		{"list shares", "GET", "/report/share?report_id={id}", "", "b-report"},
+		{"revoke share", "DELETE", "/report/share?report_id={id}&user_id=a-student", "", "b-report"},
+		{"create link", "POST", "/report/link", `{"report_id":"{id}"}`, "b-report"},
+		{"list links", "GET", "/report/link?report_id={id}", "", "b-report"},
+		{"revoke link", "DELETE", "/report/link?id={id}", "", f.bLink},
+		{"get webhook", "GET", "/webhook?id={id}", "", f.bWebhook},
+		{"delete webhook", "DELETE", "/webhook?id={id}", "", f.bWebhook},
+		{"webhook deliveries", "GET", "/webhook/deliveries?webhook_id={id}", "", f.bWebhook},
+		{"scim get", "GET", "/scim/v2/Users/{id}", "", "b-student"},
+		{"scim replace", "PUT", "/scim/v2/Users/{id}", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"{id}","active":false}`, "b-student"},
+		{"scim patch", "PATCH", "/scim/v2/Users/{id}", `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`, "b-student"},
+		{"scim delete", "DELETE", "/scim/v2/Users/{id}", "", "b-student"},
+		{"rpc get user", "POST", "/rpc", `{"jsonrpc":"2.0","method":"UserApp.Get","params":{"id":"{id}"},"id":1}`, "b-student"},
+		{"rpc get report", "POST", "/rpc", `{"jsonrpc":"2.0","method":"ReportApp.Get","params":{"id":"{id}"},"id":1}`, "b-report"},
+	}
+	callers := map[string]string{"admin": f.aAdmin, "teacher": f.aTeacher, "student": f.aStudent}
+	for _, c := range cases {
+		for role, token := range callers {
+			t.Run(c.name+" as "+role, func(t *testing.T) {
+				request := func(id string) (int, string) {
+					w := f.do(token, c.method, strings.ReplaceAll(c.path, "{id}", id), strings.ReplaceAll(c.body, "{id}", id))
+					return w.Code, strings.ReplaceAll(w.Body.String(), id, "ID")
+				}
+				missingCode, missingBody := request("missing-" + c.id)
+				code, body := request(c.id)
+				assert.Equal(t, missingCode, code, body)
+				assert.Equal(t, missingBody, body)
+				assert.NotContains(t, body, "b-")
+			})
+		}
+	}
+
+	// Nothing of organization b was changed meanwhile.
+	w := f.do(f.bStudent, "GET", "/report?id=b-report", "")
+	requireStatus(t, w, http.StatusOK)
+	assert.Contains(t, w.Body.String(), `"title":"b-report"`)
+	user, err := f.repos.users.GetByID(f.db, "b", "b-student")
+	require.NoError(t, err)
+	assert.Equal(t, model.UserActive, user.Status)
+	assert.Equal(t, "b-student", user.Name)
+	role, err := f.repos.roles.GetRole(f.db, "b-student")
+	require.NoError(t, err)
+	assert.Equal(t, model.RoleStudent, role)
+	link, err := f.repos.shareLinks.GetByID(f.db, "b", f.bLink)
+	require.NoError(t, err)
+	assert.Nil(t, link.RevokedAt)
+	_, err = f.repos.webhooks.GetByID(f.db, "b", f.bWebhook)
+	assert.NoError(t, err)
+}
+
+// TestCrossTenantListings checks that listings leave out other
+// organizations.
+func TestCrossTenantListings(t *testing.T) {
+	f := newTenantFixture(t)
+	for _, path := range []string{
+		"/users",
+		"/users?with_report_count=true",
+		"/report/shared",
+		"/webhook",
+		"/audit",
+		"/scim/v2/Users",
+		`/scim/v2/Users?filter=userName%20eq%20%22b-student%22`,
+		"/organization",
+	} {
+		w := f.do(f.aAdmin, "GET", path, "")
+		requireStatus(t, w, http.StatusOK)
+		assert.NotContains(t, w.Body.String(), "b-", path)
+	}
+}
+
+// TestTakenIDs checks that an ID in use by another organization is refused
+// just like one in use by the caller's.
+func TestTakenIDs(t *testing.T) {
+	f := newTenantFixture(t)
+	cases := []struct {
+		name, method, path, body string
+	}{
+		{"register user", "POST", "/user", `{"id":"{id}","name":"x"}`},
+		{"rename user", "PUT", "/user/id", `{"id":"a-teacher","new_id":"{id}"}`},
+		{"scim create", "POST", "/scim/v2/Users", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"{id}"}`},
+		{"rpc register", "POST", "/rpc", `{"jsonrpc":"2.0","method":"UserApp.Register","params":{"id":"{id}","name":"x"},"id":1}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			own := f.do(f.aAdmin, c.method, c.path, strings.ReplaceAll(c.body, "{id}", "a-student"))
+			other := f.do(f.aAdmin, c.method, c.path, strings.ReplaceAll(c.body, "{id}", "b-student"))
+			assert.Equal(t, own.Code, other.Code)
+			assert.Equal(t, strings.ReplaceAll(own.Body.String(), "a-student", "ID"), strings.ReplaceAll(other.Body.String(), "b-student", "ID"))
+			if c.path == "/rpc" {
+				assert.Contains(t, other.Body.String(), `"status":409`)
+			} else {
+				assert.Equal(t, http.StatusConflict, other.Code, other.Body.String())
+			}
+		})
+	}
+
+	t.Run("import", func(t *testing.T) {
+		csv := "id,name\na-student,x\nb-student,y\n"
+		var response struct {
+			Result model.ImportResult `json:"result"`
+		}
+		decode(t, f.doRaw(f.aAdmin, "POST", "/user/import?dry_run=true", "text/csv", csv), http.StatusUnprocessableEntity, &response)
+		require.Len(t, response.Result.Errors, 2)
+		assert.Equal(t, response.Result.Errors[0].Message, response.Result.Errors[1].Message)
+	})
+}
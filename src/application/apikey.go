@@ -0,0 +1,237 @@
+package application
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+// APIKeyScopes lists the scopes a key can be issued with. A write scope
+// includes the matching read scope, and users:admin includes every scope.
+var APIKeyScopes = []string{
+	model.ScopeReportsRead,
+	model.ScopeReportsWrite,
+	model.ScopeReportsReadAll,
+	model.ScopeUsersRead,
+	model.ScopeUsersWrite,
+	model.ScopeUsersAdmin,
+}
+
+// apiKeyPrefix marks the keys issued by this API so that leaked keys are easy
+// to recognise, for example by secret scanners.
+const apiKeyPrefix = "rk_"
+
+// signingSecretPrefix does the same for the secrets of signing keys.
+const signingSecretPrefix = "rs_"
+
+// lastUsedResolution limits how often a busy key writes its last use back to
+// the database.
+const lastUsedResolution = time.Minute
+
+// HasScope reports whether scopes grant scope.
+func HasScope(scopes []string, scope string) bool {
+	for _, granted := range scopes {
+		if granted == scope || granted == model.ScopeUsersAdmin {
+			return true
+		}
+		if read, found := strings.CutSuffix(granted, ":write"); found && scope == read+":read" {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeyApp interface {
+	// Issue creates a key for UserID and returns it together with the secret
+	// token, which is not stored and cannot be retrieved again. Keys of
+	// users outside organization OrgID are treated as missing throughout.
+	Issue(DB *sql.DB, OrgID, UserID, Name string, Scopes []string, ExpiresAt *time.Time) (model.APIKey, string, error)
+	// IssueSigningKey is Issue for a signing key, returning its secret
+	// instead of a token. See package signer.
+	IssueSigningKey(DB *sql.DB, OrgID, UserID, Name string, Scopes []string, ExpiresAt *time.Time) (model.APIKey, string, error)
+	List(DB *sql.DB, OrgID, UserID string) ([]model.APIKey, error)
+	Get(DB *sql.DB, OrgID, ID string) (model.APIKey, error)
+	Revoke(DB *sql.DB, OrgID, ID string) (model.APIKey, error)
+	// Authenticate resolves a bearer token to the principal it was issued
+	// to, recording the use of the key.
+	Authenticate(DB *sql.DB, Token string) (model.Principal, error)
+}
+
+func NewAPIKeyApp(kr repository.IAPIKeyRepository, ur repository.IUserRepository) APIKeyApp {
+	return &apiKeyApp{
+		apiKeyRepository: kr,
+		userRepository:   ur,
+	}
+}
+
+type apiKeyApp struct {
+	apiKeyRepository repository.IAPIKeyRepository
+	userRepository   repository.IUserRepository
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *apiKeyApp) Issue(DB *sql.DB, OrgID, UserID, Name string, Scopes []string, ExpiresAt *time.Time) (model.APIKey, string, error) {
+	return a.issue(DB, OrgID, UserID, Name, Scopes, ExpiresAt, false)
+}
+
+func (a *apiKeyApp) IssueSigningKey(DB *sql.DB, OrgID, UserID, Name string, Scopes []string, ExpiresAt *time.Time) (model.APIKey, string, error) {
+	return a.issue(DB, OrgID, UserID, Name, Scopes, ExpiresAt, true)
+}
+
+func (a *apiKeyApp) issue(DB *sql.DB, OrgID, UserID, Name string, Scopes []string, ExpiresAt *time.Time, Signing bool) (model.APIKey, string, error) {
+	if len(Scopes) == 0 {
+		return model.APIKey{}, "", fmt.Errorf("invalid scope")
+	}
+	for _, scope := range Scopes {
+		if !slices.Contains(APIKeyScopes, scope) {
+			return model.APIKey{}, "", fmt.Errorf("invalid scope")
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if ExpiresAt != nil && !ExpiresAt.After(now) {
+		return model.APIKey{}, "", fmt.Errorf("expiry is in the past")
+	}
+
+	if _, err := getTenantUser(DB, a.userRepository, OrgID, UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return model.APIKey{}, "", fmt.Errorf("user not found")
+		}
+		return model.APIKey{}, "", fmt.Errorf("failed to get user by ID: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return model.APIKey{}, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	prefix := apiKeyPrefix
+	if Signing {
+		prefix = signingSecretPrefix
+	}
+	token := prefix + hex.EncodeToString(buf)
+
+	scopes := slices.Clone(Scopes)
+	slices.Sort(scopes)
+
+	key := model.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    UserID,
+		Name:      Name,
+		Prefix:    token[:len(prefix)+8],
+		Hash:      hashToken(token),
+		Signing:   Signing,
+		Scopes:    slices.Compact(scopes),
+		CreatedAt: now,
+	}
+	if Signing {
+		key.Secret = token
+	}
+	if ExpiresAt != nil {
+		expiresAt := ExpiresAt.UTC().Truncate(time.Second)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := a.apiKeyRepository.Insert(DB, key); err != nil {
+		return model.APIKey{}, "", fmt.Errorf("failed to insert api key: %w", err)
+	}
+	return key, token, nil
+}
+
+func (a *apiKeyApp) List(DB *sql.DB, OrgID, UserID string) ([]model.APIKey, error) {
+	if _, err := getTenantUser(DB, a.userRepository, OrgID, UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+
+	keys, err := a.apiKeyRepository.GetByUserID(DB, UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys of user %s: %w", UserID, err)
+	}
+	return keys, nil
+}
+
+func (a *apiKeyApp) Get(DB *sql.DB, OrgID, ID string) (model.APIKey, error) {
+	key, err := a.apiKeyRepository.GetByID(DB, ID)
+	if err != nil {
+		if err.Error() == "api key not found" {
+			return model.APIKey{}, err
+		}
+		return model.APIKey{}, fmt.Errorf("failed to get api key %s: %w", ID, err)
+	}
+	if _, err := getTenantUser(DB, a.userRepository, OrgID, key.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return model.APIKey{}, fmt.Errorf("api key not found")
+		}
+		return model.APIKey{}, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+	return key, nil
+}
+
+// Revoke is idempotent: revoking a revoked key returns it unchanged.
+func (a *apiKeyApp) Revoke(DB *sql.DB, OrgID, ID string) (model.APIKey, error) {
+	key, err := a.Get(DB, OrgID, ID)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := a.apiKeyRepository.Revoke(DB, ID, now); err != nil {
+		return model.APIKey{}, fmt.Errorf("failed to revoke api key %s: %w", ID, err)
+	}
+	key.RevokedAt = &now
+	return key, nil
+}
+
+func (a *apiKeyApp) Authenticate(DB *sql.DB, Token string) (model.Principal, error) {
+	if !strings.HasPrefix(Token, apiKeyPrefix) {
+		return model.Principal{}, fmt.Errorf("invalid api key")
+	}
+	key, err := a.apiKeyRepository.GetByHash(DB, hashToken(Token))
+	if err != nil {
+		if err.Error() == "api key not found" {
+			return model.Principal{}, fmt.Errorf("invalid api key")
+		}
+		return model.Principal{}, fmt.Errorf("failed to look up api key: %w", err)
+	}
+
+	if key.Signing {
+		return model.Principal{}, fmt.Errorf("invalid api key")
+	}
+	return usableKey(DB, a.apiKeyRepository, key)
+}
+
+// usableKey checks that key is neither revoked nor expired, records its use
+// and returns the principal it belongs to.
+func usableKey(DB *sql.DB, kr repository.IAPIKeyRepository, key model.APIKey) (model.Principal, error) {
+	now := time.Now().UTC()
+	if key.RevokedAt != nil {
+		return model.Principal{}, fmt.Errorf("api key revoked")
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return model.Principal{}, fmt.Errorf("api key expired")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := kr.UpdateLastUsed(DB, key.ID, now.Truncate(time.Second)); err != nil {
+			log.Printf("Error recording use of api key %s: %v", key.ID, err)
+		}
+	}
+	return model.Principal{UserID: key.UserID, KeyID: key.ID, Scopes: key.Scopes}, nil
+}
@@ -0,0 +1,36 @@
+package application
+
+import (
+	"database/sql"
+	"fmt"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+// AuditApp shows administrators the audit log of their organization.
+type AuditApp interface {
+	// List pages backwards from Before, an entry ID; 0 starts at the newest.
+	List(DB *sql.DB, Caller model.Principal, Before uint64, Limit int) ([]model.AuditEntry, error)
+}
+
+func NewAuditApp(ar repository.IAuditRepository) AuditApp {
+	return &auditApp{
+		auditRepository: ar,
+	}
+}
+
+type auditApp struct {
+	auditRepository repository.IAuditRepository
+}
+
+func (a *auditApp) List(DB *sql.DB, Caller model.Principal, Before uint64, Limit int) ([]model.AuditEntry, error) {
+	if !isAdmin(Caller) {
+		return nil, fmt.Errorf("forbidden")
+	}
+	entries, err := a.auditRepository.GetByOrgID(DB, Caller.OrgID, Before, Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log of organization %s: %w", Caller.OrgID, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,16 @@
+package application
+
+import "repo-api/src/domain/model"
+
+type EventPublisher interface {
+	Publish(event model.Event)
+}
+
+type EventSubscriber interface {
+	// Subscribe returns the buffered events newer than lastEventID followed
+	// by a channel of live events. gap is true when lastEventID is older than
+	// the buffer, so some events could not be replayed. The channel is
+	// closed if the subscriber falls too far behind; cancel must always be
+	// called once the subscriber is done.
+	Subscribe(lastEventID uint64) (replay []model.Event, gap bool, events <-chan model.Event, cancel func())
+}
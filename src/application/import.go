@@ -0,0 +1,393 @@
+package application
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	importBatchSize = 100
+)
+
+// ImportApp applies the same policy as the single-item services: only
+// administrators import users, and Caller may only import reports under
+// their own name unless they are an administrator. Everything is imported
+// into Caller's organization.
+type ImportApp interface {
+	ImportUsers(DB *sql.DB, Caller model.Principal, r io.Reader, format string, dryRun bool) (model.ImportResult, error)
+	ImportReports(DB *sql.DB, Caller model.Principal, r io.Reader, format string, dryRun bool) (model.ImportResult, error)
+}
+
+func NewImportApp(ur repository.IUserRepository, rr repository.IReportRepository, ep EventPublisher) ImportApp {
+	return &importApp{
+		userRepository:   ur,
+		reportRepository: rr,
+		eventPublisher:   ep,
+	}
+}
+
+type importApp struct {
+	userRepository   repository.IUserRepository
+	reportRepository repository.IReportRepository
+	eventPublisher   EventPublisher
+}
+
+// DetectImportFormat guesses the import format from a file name or a
+// Content-Type header. It returns an empty string when neither is conclusive.
+func DetectImportFormat(filename, contentType string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return ImportFormatCSV
+	case ".ndjson", ".jsonl":
+		return ImportFormatNDJSON
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "text/csv":
+		return ImportFormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return ImportFormatNDJSON
+	}
+	return ""
+}
+
+type importRow struct {
+	line   int
+	values map[string]string
+}
+
+func (i *importApp) ImportUsers(DB *sql.DB, Caller model.Principal, r io.Reader, format string, dryRun bool) (model.ImportResult, error) {
+	result := model.ImportResult{Format: format, DryRun: dryRun, Errors: []model.ImportRowError{}}
+	if !isAdmin(Caller) {
+		return result, fmt.Errorf("forbidden")
+	}
+
+	rows, err := readImportRows(r, format, []string{"id", "name"}, &result)
+	if err != nil {
+		return result, err
+	}
+	result.Total += len(rows)
+
+	var users []model.User
+	var lines []int
+	seen := make(map[string]int)
+	for _, row := range rows {
+		user := model.User{ID: row.values["id"], Name: row.values["name"], OrgID: Caller.OrgID}
+
+		rowErrors := requireImportFields(row, "id", "name")
+		if user.ID != "" {
+			if first, ok := seen[user.ID]; ok {
+				rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "id", Message: fmt.Sprintf("duplicate id, first seen at row %d", first)})
+			} else {
+				seen[user.ID] = row.line
+				// IDs are unique across organizations, and an ID taken in
+				// another organization is reported no differently.
+				taken, err := i.userRepository.IDTaken(DB, user.ID)
+				if err != nil {
+					return result, fmt.Errorf("failed to check user %s: %w", user.ID, err)
+				}
+				if taken {
+					rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "id", Message: "id is not available"})
+				}
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+		users = append(users, user)
+		lines = append(lines, row.line)
+	}
+	result.Valid = len(users)
+
+	if dryRun {
+		sortImportErrors(result.Errors)
+		return result, nil
+	}
+
+	for start := 0; start < len(users); start += importBatchSize {
+		end := min(start+importBatchSize, len(users))
+		if err := i.userRepository.InsertBatch(DB, users[start:end]); err != nil {
+			log.Printf("Error importing users (rows %d-%d): %v", lines[start], lines[end-1], err)
+			result.Errors = append(result.Errors, batchImportErrors(lines[start:end], err)...)
+			continue
+		}
+		result.Inserted += end - start
+	}
+	sortImportErrors(result.Errors)
+	return result, nil
+}
+
+func (i *importApp) ImportReports(DB *sql.DB, Caller model.Principal, r io.Reader, format string, dryRun bool) (model.ImportResult, error) {
+	result := model.ImportResult{Format: format, DryRun: dryRun, Errors: []model.ImportRowError{}}
+
+	rows, err := readImportRows(r, format, []string{"author_id", "count", "title", "style", "language"}, &result)
+	if err != nil {
+		return result, err
+	}
+	result.Total += len(rows)
+
+	var reports []model.Report
+	var lines []int
+	seen := make(map[string]int)
+	// authors caches the status of each author, "" for missing ones.
+	authors := make(map[string]string)
+	for _, row := range rows {
+		report := model.Report{
+			ID:         row.values["id"],
+			AuthorID:   row.values["author_id"],
+			OrgID:      Caller.OrgID,
+			Title:      row.values["title"],
+			Style:      row.values["style"],
+			Language:   row.values["language"],
+			Visibility: row.values["visibility"],
+		}
+		if report.Visibility == "" {
+			report.Visibility = model.VisibilityPrivate
+		}
+
+		rowErrors := requireImportFields(row, "author_id", "count", "title", "style", "language")
+		if raw := row.values["count"]; raw != "" {
+			count, err := strconv.Atoi(raw)
+			if err != nil || count <= 0 {
+				rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "count", Message: "count must be a positive integer"})
+			}
+			report.Count = count
+		}
+
+		if !validVisibility(report.Visibility) {
+			rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "visibility", Message: "visibility must be private or public"})
+		}
+
+		if report.AuthorID != "" && !CanActAsAuthor(Caller, report.AuthorID) {
+			rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "author_id", Message: "cannot import reports of another author"})
+		} else if report.AuthorID != "" {
+			status, checked := authors[report.AuthorID]
+			if !checked {
+				author, err := getTenantUser(DB, i.userRepository, Caller.OrgID, report.AuthorID)
+				if err != nil && err != sql.ErrNoRows {
+					return result, fmt.Errorf("failed to check author %s: %w", report.AuthorID, err)
+				}
+				status = author.Status
+				authors[report.AuthorID] = status
+			}
+			if status == "" {
+				rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "author_id", Message: "author does not exist"})
+			} else if status != model.UserActive {
+				rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "author_id", Message: "author is not active"})
+			}
+		}
+
+		if report.ID != "" {
+			if first, ok := seen[report.ID]; ok {
+				rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "id", Message: fmt.Sprintf("duplicate id, first seen at row %d", first)})
+			} else {
+				seen[report.ID] = row.line
+				taken, err := i.reportRepository.IDTaken(DB, report.ID)
+				if err != nil {
+					return result, fmt.Errorf("failed to check report %s: %w", report.ID, err)
+				}
+				if taken {
+					rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: "id", Message: "id is not available"})
+				}
+			}
+		} else {
+			report.ID = uuid.New().String()
+		}
+
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+		reports = append(reports, report)
+		lines = append(lines, row.line)
+	}
+	result.Valid = len(reports)
+
+	if dryRun {
+		sortImportErrors(result.Errors)
+		return result, nil
+	}
+
+	for start := 0; start < len(reports); start += importBatchSize {
+		end := min(start+importBatchSize, len(reports))
+		if err := i.reportRepository.InsertBatch(DB, reports[start:end]); err != nil {
+			log.Printf("Error importing reports (rows %d-%d): %v", lines[start], lines[end-1], err)
+			result.Errors = append(result.Errors, batchImportErrors(lines[start:end], err)...)
+			continue
+		}
+		result.Inserted += end - start
+		for _, report := range reports[start:end] {
+			i.eventPublisher.Publish(model.Event{Type: model.EventReportCreated, AuthorID: report.AuthorID, OrgID: report.OrgID, Data: report})
+		}
+	}
+	sortImportErrors(result.Errors)
+	return result, nil
+}
+
+func requireImportFields(row importRow, fields ...string) []model.ImportRowError {
+	var rowErrors []model.ImportRowError
+	for _, field := range fields {
+		if row.values[field] == "" {
+			rowErrors = append(rowErrors, model.ImportRowError{Row: row.line, Field: field, Message: field + " is required"})
+		}
+	}
+	return rowErrors
+}
+
+func sortImportErrors(rowErrors []model.ImportRowError) {
+	slices.SortStableFunc(rowErrors, func(a, b model.ImportRowError) int {
+		return a.Row - b.Row
+	})
+}
+
+func batchImportErrors(lines []int, err error) []model.ImportRowError {
+	rowErrors := make([]model.ImportRowError, 0, len(lines))
+	for _, line := range lines {
+		rowErrors = append(rowErrors, model.ImportRowError{Row: line, Message: fmt.Sprintf("batch insert failed: %v", err)})
+	}
+	return rowErrors
+}
+
+// readImportRows decodes every record of the input. Records that cannot be
+// decoded are reported in result and counted towards its total, so callers
+// only see rows that are worth validating.
+func readImportRows(r io.Reader, format string, required []string, result *model.ImportResult) ([]importRow, error) {
+	switch format {
+	case ImportFormatCSV:
+		return readCSVRows(r, required, result)
+	case ImportFormatNDJSON:
+		return readNDJSONRows(r, result)
+	default:
+		return nil, fmt.Errorf("unsupported import format")
+	}
+}
+
+func readCSVRows(r io.Reader, required []string, result *model.ImportResult) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	for _, column := range required {
+		if !slices.Contains(header, column) {
+			return nil, fmt.Errorf("invalid csv header: missing column %q", column)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Total++
+			result.Errors = append(result.Errors, model.ImportRowError{Row: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			result.Total++
+			result.Errors = append(result.Errors, model.ImportRowError{Row: line, Message: fmt.Sprintf("expected %d columns, got %d", len(header), len(record))})
+			continue
+		}
+
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			values[column] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, importRow{line: line, values: values})
+	}
+	return rows, nil
+}
+
+func readNDJSONRows(r io.Reader, result *model.ImportResult) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []importRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(text, &fields); err != nil {
+			result.Total++
+			result.Errors = append(result.Errors, model.ImportRowError{Row: line, Message: "invalid JSON object"})
+			continue
+		}
+
+		values := make(map[string]string, len(fields))
+		valid := true
+		for key, raw := range fields {
+			var s string
+			if err := json.Unmarshal(raw, &s); err == nil {
+				values[key] = strings.TrimSpace(s)
+				continue
+			}
+			var n json.Number
+			if err := json.Unmarshal(raw, &n); err == nil {
+				values[key] = n.String()
+				continue
+			}
+			if string(raw) == "null" {
+				continue
+			}
+			result.Errors = append(result.Errors, model.ImportRowError{Row: line, Field: key, Message: "must be a string or a number"})
+			valid = false
+		}
+		if !valid {
+			result.Total++
+			continue
+		}
+		rows = append(rows, importRow{line: line, values: values})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ndjson: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,222 @@
+package application_test
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+	"repo-api/src/infra/memory"
+)
+
+var importAdmin = model.Principal{UserID: "admin", OrgID: "default", Scopes: []string{model.ScopeUsersAdmin}}
+
+// batchRecorder records the size of every user batch inserted, and fails
+// the batch numbered fail, counting from 1.
+type batchRecorder struct {
+	repository.IUserRepository
+	batches []int
+	fail    int
+}
+
+func (r *batchRecorder) InsertBatch(DB *sql.DB, users []model.User) error {
+	r.batches = append(r.batches, len(users))
+	if len(r.batches) == r.fail {
+		return fmt.Errorf("connection lost")
+	}
+	return r.IUserRepository.InsertBatch(DB, users)
+}
+
+type publishedEvents struct{ events []model.Event }
+
+func (p *publishedEvents) Publish(event model.Event) { p.events = append(p.events, event) }
+
+type importFixture struct {
+	users     *batchRecorder
+	reports   repository.IReportRepository
+	published *publishedEvents
+	app       application.ImportApp
+}
+
+func newImportFixture(t *testing.T) *importFixture {
+	store := memory.NewStore()
+	f := &importFixture{
+		users:     &batchRecorder{IUserRepository: memory.NewUserRepository(store)},
+		reports:   memory.NewReportRepository(store),
+		published: &publishedEvents{},
+	}
+	f.app = application.NewImportApp(f.users, f.reports, f.published)
+	for _, user := range []model.User{
+		{ID: "sato", Name: "佐藤", OrgID: "default"},
+		{ID: "suzuki", Name: "鈴木", OrgID: "default", Status: model.UserSuspended},
+		{ID: "other", Name: "他校", OrgID: "other"},
+	} {
+		require.NoError(t, f.users.Insert(nil, user))
+	}
+	return f
+}
+
+// userCSV is a users file of n valid rows, user-1 on line 2 and so on.
+func userCSV(n int) string {
+	var b strings.Builder
+	b.WriteString("id,name\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "user-%d,佐藤\n", i)
+	}
+	return b.String()
+}
+
+func TestImportUsersValidation(t *testing.T) {
+	file := strings.Join([]string{
+		"id,name",
+		"tanaka,田中",
+		"kato,",
+		"tanaka,田中",
+		"sato,佐藤",
+		"other,他校",
+		"ito,伊藤,extra",
+		`"bad"quote,x`,
+		"yamada,山田",
+	}, "\n")
+	want := []model.ImportRowError{
+		{Row: 3, Field: "name", Message: "name is required"},
+		{Row: 4, Field: "id", Message: "duplicate id, first seen at row 2"},
+		{Row: 5, Field: "id", Message: "id is not available"},
+		{Row: 6, Field: "id", Message: "id is not available"},
+		{Row: 7, Message: "expected 2 columns, got 3"},
+		{Row: 8, Message: `extraneous or missing " in quoted-field`},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		t.Run(strconv.FormatBool(dryRun), func(t *testing.T) {
+			f := newImportFixture(t)
+			result, err := f.app.ImportUsers(nil, importAdmin, strings.NewReader(file), application.ImportFormatCSV, dryRun)
+			require.NoError(t, err)
+			assert.Equal(t, 8, result.Total)
+			assert.Equal(t, 2, result.Valid)
+			assert.Equal(t, want, result.Errors)
+
+			_, err = f.users.GetByID(nil, "default", "yamada")
+			if dryRun {
+				assert.Zero(t, result.Inserted)
+				assert.Empty(t, f.users.batches)
+				assert.Equal(t, sql.ErrNoRows, err)
+			} else {
+				assert.Equal(t, 2, result.Inserted)
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	f := newImportFixture(t)
+	_, err := f.app.ImportUsers(nil, model.Principal{UserID: "sato", OrgID: "default"}, strings.NewReader(file), application.ImportFormatCSV, false)
+	assert.EqualError(t, err, "forbidden")
+	_, err = f.app.ImportUsers(nil, importAdmin, strings.NewReader("id\ntanaka\n"), application.ImportFormatCSV, false)
+	assert.EqualError(t, err, `invalid csv header: missing column "name"`)
+}
+
+func TestImportReportsValidation(t *testing.T) {
+	file := strings.Join([]string{
+		`{"author_id":"sato","count":800,"title":"クリーンアーキテクチャについて","style":"essay","language":"ja"}`,
+		`{"author_id":"sato","count":"many","title":"t","style":"essay","language":"ja"}`,
+		``,
+		`{"author_id":"sato","count":800,"title":"t","style":"essay","language":"ja","visibility":"secret"}`,
+		`{"author_id":"nobody","count":800,"title":"t","style":"essay","language":"ja"}`,
+		`{"author_id":"suzuki","count":800,"title":"t","style":"essay","language":"ja"}`,
+		`{"author_id":"other","count":800,"title":"t","style":"essay","language":"ja"}`,
+		`{"author_id":"sato","count":800,"title":["t"],"style":"essay","language":"ja"}`,
+		`not json`,
+		`{"id":"r1","author_id":"sato","count":800,"title":"t","style":"essay","language":"ja","visibility":"public"}`,
+		`{"id":"r1","author_id":"sato","count":800,"title":"t","style":"essay"}`,
+	}, "\n")
+
+	f := newImportFixture(t)
+	result, err := f.app.ImportReports(nil, importAdmin, strings.NewReader(file), application.ImportFormatNDJSON, false)
+	require.NoError(t, err)
+	assert.Equal(t, 10, result.Total)
+	assert.Equal(t, 2, result.Valid)
+	assert.Equal(t, 2, result.Inserted)
+	assert.Equal(t, []model.ImportRowError{
+		{Row: 2, Field: "count", Message: "count must be a positive integer"},
+		{Row: 4, Field: "visibility", Message: "visibility must be private or public"},
+		{Row: 5, Field: "author_id", Message: "author does not exist"},
+		{Row: 6, Field: "author_id", Message: "author is not active"},
+		{Row: 7, Field: "author_id", Message: "author does not exist"},
+		{Row: 8, Field: "title", Message: "must be a string or a number"},
+		{Row: 9, Message: "invalid JSON object"},
+		{Row: 11, Field: "language", Message: "language is required"},
+		{Row: 11, Field: "id", Message: "duplicate id, first seen at row 10"},
+	}, result.Errors)
+
+	reports, err := f.reports.GetByAuthorID(nil, "default", "sato")
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	require.Len(t, f.published.events, 2)
+	for _, event := range f.published.events {
+		assert.Equal(t, model.EventReportCreated, event.Type)
+	}
+
+	// Authors other than the caller are refused unless the caller is an
+	// administrator.
+	f = newImportFixture(t)
+	student := model.Principal{UserID: "sato", OrgID: "default"}
+	result, err = f.app.ImportReports(nil, student, strings.NewReader(file), application.ImportFormatNDJSON, true)
+	require.NoError(t, err)
+	assert.Contains(t, result.Errors, model.ImportRowError{Row: 5, Field: "author_id", Message: "cannot import reports of another author"})
+	assert.Equal(t, 2, result.Valid)
+	assert.Zero(t, result.Inserted)
+	assert.Empty(t, f.published.events)
+}
+
+// TestImportBatches checks that valid rows are inserted 100 at a time, and
+// that a failed batch is reported on each of its rows without holding back
+// the others.
+func TestImportBatches(t *testing.T) {
+	for _, c := range []struct {
+		rows    int
+		batches []int
+	}{
+		{0, nil},
+		{100, []int{100}},
+		{101, []int{100, 1}},
+		{200, []int{100, 100}},
+	} {
+		t.Run(strconv.Itoa(c.rows), func(t *testing.T) {
+			f := newImportFixture(t)
+			result, err := f.app.ImportUsers(nil, importAdmin, strings.NewReader(userCSV(c.rows)), application.ImportFormatCSV, false)
+			require.NoError(t, err)
+			assert.Equal(t, c.batches, f.users.batches)
+			assert.Equal(t, c.rows, result.Inserted)
+			assert.Empty(t, result.Errors)
+		})
+	}
+
+	// Invalid rows do not take a place in a batch: with line 51 left out the
+	// second batch runs from line 103 to 202.
+	lines := strings.Split(strings.TrimSuffix(userCSV(202), "\n"), "\n")
+	lines[50] = "user-50,"
+	f := newImportFixture(t)
+	f.users.fail = 2
+	result, err := f.app.ImportUsers(nil, importAdmin, strings.NewReader(strings.Join(lines, "\n")), application.ImportFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int{100, 100, 1}, f.users.batches)
+	assert.Equal(t, 202, result.Total)
+	assert.Equal(t, 201, result.Valid)
+	assert.Equal(t, 101, result.Inserted)
+
+	require.Len(t, result.Errors, 101)
+	assert.Equal(t, model.ImportRowError{Row: 51, Field: "name", Message: "name is required"}, result.Errors[0])
+	for i, rowError := range result.Errors[1:] {
+		assert.Equal(t, model.ImportRowError{Row: 103 + i, Message: "batch insert failed: connection lost"}, rowError)
+	}
+	_, err = f.users.GetByID(nil, "default", "user-102")
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = f.users.GetByID(nil, "default", "user-202")
+	assert.NoError(t, err)
+}
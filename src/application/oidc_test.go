@@ -0,0 +1,500 @@
+package application_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/infra/memory"
+)
+
+const (
+	testClientID     = "repo-api"
+	testClientSecret = "client secret"
+	testRedirectURL  = "https://api.example.com/oidc/callback"
+)
+
+// testClock is the time of both the provider and the app, so that tests can
+// move past the key set's cache limits.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testGrant is an authorization code the provider has issued.
+type testGrant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	subject     string
+	keyID       string
+}
+
+// testIdP stands in for an OpenID Connect provider. Its authorization
+// endpoint approves every request at once, for the subject named by
+// login_hint, and redirects back with a code.
+type testIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	clock  *testClock
+
+	mu sync.Mutex
+	// keys are the published keys; signingKey is the one new codes are
+	// signed with.
+	keys       map[string]*rsa.PrivateKey
+	signingKey string
+	rotations  int
+	// forgedKey, if set, signs ID tokens in place of the key they name.
+	forgedKey   *rsa.PrivateKey
+	codes       map[string]testGrant
+	discoveries int
+	jwksFetches int
+	jwksDown    bool
+	// jwksGate, if set, holds JWKS requests until it is closed; each one
+	// is announced on jwksStarted first.
+	jwksGate    chan struct{}
+	jwksStarted chan struct{}
+	// editClaims, if set, changes the claims of the ID tokens issued.
+	editClaims func(claims map[string]interface{})
+}
+
+func newTestIdP(t *testing.T, clock *testClock) *testIdP {
+	idp := &testIdP{t: t, clock: clock, keys: map[string]*rsa.PrivateKey{}, codes: map[string]testGrant{}}
+	idp.rotate(false)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("/authorize", idp.handleAuthorize)
+	mux.HandleFunc("/token", idp.handleToken)
+	mux.HandleFunc("/jwks", idp.handleJWKS)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// rotate signs new codes with a new key and publishes it, along with the
+// previous keys if keepOld.
+func (idp *testIdP) rotate(keepOld bool) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(idp.t, err)
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	if !keepOld {
+		idp.keys = map[string]*rsa.PrivateKey{}
+	}
+	idp.rotations++
+	idp.signingKey = fmt.Sprintf("key-%d", idp.rotations)
+	idp.keys[idp.signingKey] = key
+}
+
+func (idp *testIdP) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	idp.discoveries++
+	idp.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 idp.server.URL,
+		"authorization_endpoint": idp.server.URL + "/authorize",
+		"token_endpoint":         idp.server.URL + "/token",
+		"jwks_uri":               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *testIdP) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != testClientID ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	code := randomCode(idp.t)
+	idp.mu.Lock()
+	idp.codes[code] = testGrant{
+		redirectURI: query.Get("redirect_uri"),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		subject:     query.Get("login_hint"),
+		keyID:       idp.signingKey,
+	}
+	idp.mu.Unlock()
+	http.Redirect(w, r, query.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {query.Get("state")}}.Encode(), http.StatusFound)
+}
+
+func (idp *testIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	// The credentials are form-encoded before they are put in the header.
+	clientID, secret, _ := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	secret, _ = url.QueryUnescape(secret)
+	if r.Method != http.MethodPost || r.ParseForm() != nil || clientID != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	idp.mu.Lock()
+	grant, found := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	key := idp.keys[grant.keyID]
+	if idp.forgedKey != nil {
+		key = idp.forgedKey
+	}
+	idp.mu.Unlock()
+
+	// The code is only good for the client that holds the verifier its
+	// challenge was made from.
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != grant.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := idp.clock.Now()
+	claims := map[string]interface{}{
+		"iss":            idp.server.URL,
+		"sub":            grant.subject,
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.subject + "@example.com",
+		"email_verified": true,
+		"name":           grant.subject,
+	}
+	if idp.editClaims != nil {
+		idp.editClaims(claims)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signIDToken(idp.t, key, grant.keyID, claims)})
+}
+
+func (idp *testIdP) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	idp.jwksFetches++
+	gate, started, down := idp.jwksGate, idp.jwksStarted, idp.jwksDown
+	keys := []map[string]string{}
+	for kid, key := range idp.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	idp.mu.Unlock()
+
+	if gate != nil {
+		started <- struct{}{}
+		<-gate
+	}
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (idp *testIdP) counts() (discoveries, jwksFetches int) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.discoveries, idp.jwksFetches
+}
+
+func randomCode(t *testing.T) string {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func signIDToken(t *testing.T, key *rsa.PrivateKey, keyID string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// oidcFixture is the app logging in to a testIdP, over in-memory
+// repositories.
+type oidcFixture struct {
+	t     *testing.T
+	idp   *testIdP
+	clock *testClock
+	app   application.OIDCApp
+	store *memory.Store
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	clock := &testClock{now: time.Now()}
+	idp := newTestIdP(t, clock)
+	store := memory.NewStore()
+	users := memory.NewUserRepository(store)
+	sessions := application.NewSessionApp(memory.NewSessionRepository(store), users, application.SessionConfig{
+		Secret:          []byte("test secret"),
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+	// Only the issuer is configured; the endpoints are discovered.
+	app := application.NewOIDCApp(memory.NewIdentityRepository(store), users, sessions, application.OIDCConfig{
+		Issuer:        idp.server.URL,
+		ClientID:      testClientID,
+		ClientSecret:  testClientSecret,
+		RedirectURL:   testRedirectURL,
+		Scopes:        []string{"openid", "email"},
+		OrgID:         "default",
+		AutoProvision: true,
+		StateSecret:   []byte("state secret"),
+		LoginTTL:      10 * time.Minute,
+		JWKSCacheTTL:  time.Hour,
+		Now:           clock.Now,
+	})
+	return &oidcFixture{t: t, idp: idp, clock: clock, app: app, store: store}
+}
+
+// authorize begins a login and has the provider approve it for subject. It
+// returns the code and state sent back to the callback and the login state
+// the browser kept.
+func (f *oidcFixture) authorize(subject string) (code, state, loginState string) {
+	f.t.Helper()
+	login, err := f.app.Begin()
+	require.NoError(f.t, err)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(login.URL + "&login_hint=" + url.QueryEscape(subject))
+	require.NoError(f.t, err)
+	resp.Body.Close()
+	require.Equal(f.t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(f.t, err)
+	require.Equal(f.t, testRedirectURL, callback.Scheme+"://"+callback.Host+callback.Path)
+	return callback.Query().Get("code"), callback.Query().Get("state"), login.State
+}
+
+func (f *oidcFixture) login(subject string) (model.TokenPair, error) {
+	f.t.Helper()
+	code, state, loginState := f.authorize(subject)
+	return f.app.Callback(nil, code, state, loginState)
+}
+
+func (f *oidcFixture) requireLogin(subject string) {
+	f.t.Helper()
+	tokens, err := f.login(subject)
+	require.NoError(f.t, err)
+	require.NotEmpty(f.t, tokens.AccessToken)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	f := newOIDCFixture(t)
+
+	login, err := f.app.Begin()
+	require.NoError(t, err)
+	sent, err := url.Parse(login.URL)
+	require.NoError(t, err)
+	assert.Equal(t, f.idp.server.URL+"/authorize", sent.Scheme+"://"+sent.Host+sent.Path)
+	assert.Equal(t, "S256", sent.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, sent.Query().Get("code_challenge"))
+	assert.Empty(t, sent.Query().Get("code_verifier"))
+	assert.Equal(t, "openid email", sent.Query().Get("scope"))
+
+	f.requireLogin("alice")
+	users := memory.NewUserRepository(f.store)
+	user, err := users.GetByID(nil, "default", "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", user.Email)
+
+	// The second login finds the user by the linked subject.
+	f.requireLogin("alice")
+	discoveries, jwksFetches := f.idp.counts()
+	assert.Equal(t, 1, discoveries)
+	assert.Equal(t, 1, jwksFetches)
+
+	t.Run("code is used once", func(t *testing.T) {
+		code, state, loginState := f.authorize("alice")
+		_, err := f.app.Callback(nil, code, state, loginState)
+		require.NoError(t, err)
+		_, err = f.app.Callback(nil, code, state, loginState)
+		assert.EqualError(t, err, "invalid code")
+	})
+	t.Run("state of another login", func(t *testing.T) {
+		code, _, loginState := f.authorize("alice")
+		_, otherState, _ := f.authorize("alice")
+		_, err := f.app.Callback(nil, code, otherState, loginState)
+		assert.EqualError(t, err, "invalid login state")
+	})
+	t.Run("verifier of another login", func(t *testing.T) {
+		code, _, _ := f.authorize("alice")
+		_, otherState, otherLoginState := f.authorize("alice")
+		_, err := f.app.Callback(nil, code, otherState, otherLoginState)
+		assert.EqualError(t, err, "invalid code")
+	})
+	t.Run("expired login", func(t *testing.T) {
+		code, state, loginState := f.authorize("alice")
+		f.clock.Advance(11 * time.Minute)
+		_, err := f.app.Callback(nil, code, state, loginState)
+		assert.EqualError(t, err, "invalid login state")
+	})
+}
+
+func TestOIDCRejectsInvalidIDTokens(t *testing.T) {
+	cases := []struct {
+		name  string
+		edit  func(claims map[string]interface{})
+		error string
+	}{
+		{"other issuer", func(c map[string]interface{}) { c["iss"] = "https://idp.example.com" }, "invalid id token"},
+		{"no subject", func(c map[string]interface{}) { c["sub"] = "" }, "invalid id token"},
+		{"other audience", func(c map[string]interface{}) { c["aud"] = "other-client" }, "invalid id token"},
+		{"several audiences without azp", func(c map[string]interface{}) { c["aud"] = []string{"other-client", testClientID} }, "invalid id token"},
+		{"authorized for another party", func(c map[string]interface{}) {
+			c["aud"] = []string{"other-client", testClientID}
+			c["azp"] = "other-client"
+		}, "invalid id token"},
+		{"expired", func(c map[string]interface{}) { c["exp"] = c["iat"].(int64) - 2*60 }, "id token expired"},
+		{"issued in the future", func(c map[string]interface{}) { c["iat"] = c["iat"].(int64) + 2*60 }, "invalid id token"},
+		{"other nonce", func(c map[string]interface{}) { c["nonce"] = "other nonce" }, "invalid id token"},
+		{"no nonce", func(c map[string]interface{}) { delete(c, "nonce") }, "invalid id token"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := newOIDCFixture(t)
+			f.idp.editClaims = c.edit
+			_, err := f.login("alice")
+			assert.EqualError(t, err, c.error)
+			_, err = memory.NewUserRepository(f.store).GetByID(nil, "default", "alice@example.com")
+			assert.Error(t, err, "user was provisioned")
+		})
+	}
+
+	t.Run("signed with an unpublished key", func(t *testing.T) {
+		f := newOIDCFixture(t)
+		forged, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		f.idp.forgedKey = forged
+		_, err = f.login("alice")
+		assert.EqualError(t, err, "invalid id token")
+	})
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	f := newOIDCFixture(t)
+	f.requireLogin("alice")
+
+	// The provider replaces its key. Within a minute of the last fetch the
+	// unknown key is refused without asking the provider again.
+	f.idp.rotate(false)
+	_, err := f.login("bob")
+	assert.EqualError(t, err, "invalid id token")
+	_, jwksFetches := f.idp.counts()
+	assert.Equal(t, 1, jwksFetches)
+
+	// Later, the unknown key makes the app fetch the key set again.
+	f.clock.Advance(2 * time.Minute)
+	f.requireLogin("bob")
+	_, jwksFetches = f.idp.counts()
+	assert.Equal(t, 2, jwksFetches)
+
+	// Known keys are used while they are cached, and after that as long as
+	// the provider cannot be reached.
+	f.requireLogin("alice")
+	f.clock.Advance(2 * time.Hour)
+	f.idp.mu.Lock()
+	f.idp.jwksDown = true
+	f.idp.mu.Unlock()
+	f.requireLogin("alice")
+	_, jwksFetches = f.idp.counts()
+	assert.Equal(t, 3, jwksFetches)
+}
+
+// TestOIDCKeyRefreshDoesNotBlock checks that a slow key set fetch holds up
+// only the logins that need it, and that they share one fetch.
+func TestOIDCKeyRefreshDoesNotBlock(t *testing.T) {
+	f := newOIDCFixture(t)
+	f.requireLogin("alice")
+
+	// alice's code is signed with the cached key; the rest with a new one.
+	aliceCode, aliceState, aliceLogin := f.authorize("alice")
+	f.idp.rotate(true)
+	type pending struct{ code, state, loginState string }
+	var logins []pending
+	for i := 0; i < 5; i++ {
+		code, state, loginState := f.authorize(fmt.Sprintf("user%d", i))
+		logins = append(logins, pending{code, state, loginState})
+	}
+	f.clock.Advance(2 * time.Minute)
+
+	gate := make(chan struct{})
+	f.idp.mu.Lock()
+	f.idp.jwksGate = gate
+	f.idp.jwksStarted = make(chan struct{}, len(logins))
+	f.idp.mu.Unlock()
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			close(gate)
+		}
+	}
+	t.Cleanup(release)
+
+	errs := make(chan error, len(logins))
+	for _, login := range logins {
+		go func() {
+			_, err := f.app.Callback(nil, login.code, login.state, login.loginState)
+			errs <- err
+		}()
+	}
+	select {
+	case <-f.idp.jwksStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("key set was not fetched")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.app.Callback(nil, aliceCode, aliceState, aliceLogin)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("login with a cached key waited for the key set")
+	}
+
+	release()
+	for range logins {
+		assert.NoError(t, <-errs)
+	}
+	_, jwksFetches := f.idp.counts()
+	assert.Equal(t, 2, jwksFetches)
+}
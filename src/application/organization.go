@@ -0,0 +1,80 @@
+package application
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+// OrganizationApp resolves the tenant of each request. Every other service
+// confines Caller to Caller.OrgID, so the tenant is set once here and
+// nowhere else.
+type OrganizationApp interface {
+	// Resolve sets p.OrgID to the organization of p's user. Requested, when
+	// set, is the organization the client expects to act in and must match.
+	Resolve(DB *sql.DB, p model.Principal, Requested string) (model.Principal, error)
+	Create(DB *sql.DB, ID, Name string) (model.Organization, error)
+	Get(DB *sql.DB, ID string) (model.Organization, error)
+}
+
+func NewOrganizationApp(or repository.IOrganizationRepository, ur repository.IUserRepository) OrganizationApp {
+	return &organizationApp{
+		organizationRepository: or,
+		userRepository:         ur,
+	}
+}
+
+type organizationApp struct {
+	organizationRepository repository.IOrganizationRepository
+	userRepository         repository.IUserRepository
+}
+
+func (o *organizationApp) Resolve(DB *sql.DB, p model.Principal, Requested string) (model.Principal, error) {
+	user, err := o.userRepository.GetCaller(DB, p.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Principal{}, fmt.Errorf("user not found")
+		}
+		return model.Principal{}, fmt.Errorf("failed to get user by ID %s: %w", p.UserID, err)
+	}
+	if Requested != "" && Requested != user.OrgID {
+		return model.Principal{}, fmt.Errorf("organization mismatch")
+	}
+	p.OrgID = user.OrgID
+	return p, nil
+}
+
+func (o *organizationApp) Create(DB *sql.DB, ID, Name string) (model.Organization, error) {
+	if ID == "" || Name == "" {
+		return model.Organization{}, fmt.Errorf("invalid organization")
+	}
+	organization := model.Organization{ID: ID, Name: Name, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	if err := o.organizationRepository.Insert(DB, organization); err != nil {
+		if err.Error() == "organization already exists" {
+			return model.Organization{}, err
+		}
+		return model.Organization{}, fmt.Errorf("failed to create organization %s: %w", ID, err)
+	}
+	return organization, nil
+}
+
+func (o *organizationApp) Get(DB *sql.DB, ID string) (model.Organization, error) {
+	organization, err := o.organizationRepository.GetByID(DB, ID)
+	if err != nil {
+		if err.Error() == "organization not found" {
+			return model.Organization{}, err
+		}
+		return model.Organization{}, fmt.Errorf("failed to get organization %s: %w", ID, err)
+	}
+	return organization, nil
+}
+
+// getTenantUser returns user UserID if it belongs to organization OrgID.
+// Users of other organizations are reported as sql.ErrNoRows, the same as
+// users that do not exist.
+func getTenantUser(DB *sql.DB, ur repository.IUserRepository, OrgID, UserID string) (model.User, error) {
+	return ur.GetByID(DB, OrgID, UserID)
+}
@@ -0,0 +1,75 @@
+package application
+
+import "repo-api/src/domain/model"
+
+// The authorization policy. Services check the caller with these before
+// acting and fail with "forbidden" when the check does not pass. Nothing
+// crosses organizations: what belongs to another organization is reported
+// as not found rather than forbidden.
+
+func isAdmin(p model.Principal) bool {
+	return HasScope(p.Scopes, model.ScopeUsersAdmin)
+}
+
+// InTenant reports whether something of organization OrgID is visible to p
+// at all. Administrators are confined to their organization as well.
+func InTenant(p model.Principal, OrgID string) bool {
+	return p.OrgID == OrgID
+}
+
+// CanReadReport allows the author, administrators, roles with
+// reports:read_all such as teachers, and everyone for public reports.
+func CanReadReport(p model.Principal, report model.Report) bool {
+	if !InTenant(p, report.OrgID) {
+		return false
+	}
+	return report.Visibility == model.VisibilityPublic || CanModifyReport(p, report) || HasScope(p.Scopes, model.ScopeReportsReadAll)
+}
+
+// CanModifyReport allows the author and administrators.
+func CanModifyReport(p model.Principal, report model.Report) bool {
+	return InTenant(p, report.OrgID) && (report.AuthorID == p.UserID || isAdmin(p))
+}
+
+// CanActAsAuthor allows callers to create and follow reports under their own
+// name, and administrators under anyone's.
+func CanActAsAuthor(p model.Principal, AuthorID string) bool {
+	return AuthorID == p.UserID || isAdmin(p)
+}
+
+// CanModifyUser allows users to change their own profile and administrators
+// anyone's.
+func CanModifyUser(p model.Principal, UserID string) bool {
+	return UserID == p.UserID || isAdmin(p)
+}
+
+// ShareAllows reports whether a share at Level grants Want. Each level
+// includes the ones below it; "" is no share at all.
+func ShareAllows(Level, Want string) bool {
+	rank := map[string]int{model.ShareRead: 1, model.ShareComment: 2, model.ShareEdit: 3}
+	return Level != "" && rank[Level] >= rank[Want]
+}
+
+// CanSeeEvent hides events of other organizations, about reports the caller
+// cannot read, and about users from callers who could not GET /user them,
+// as the events carry the profile.
+func CanSeeEvent(p model.Principal, event model.Event) bool {
+	if !InTenant(p, event.OrgID) {
+		return false
+	}
+	switch data := event.Data.(type) {
+	case model.Report:
+		return CanReadReport(p, data)
+	case model.User:
+		return HasScope(p.Scopes, model.ScopeUsersRead)
+	}
+	return true
+}
+
+func validShareLevel(Level string) bool {
+	return Level == model.ShareRead || Level == model.ShareComment || Level == model.ShareEdit
+}
+
+func validVisibility(Visibility string) bool {
+	return Visibility == model.VisibilityPrivate || Visibility == model.VisibilityPublic
+}
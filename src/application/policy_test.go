@@ -0,0 +1,36 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"repo-api/src/domain/model"
+)
+
+func TestCanSeeEvent(t *testing.T) {
+	reader := model.Principal{UserID: "reader", OrgID: "a", Scopes: []string{model.ScopeReportsRead}}
+	userReader := model.Principal{UserID: "reader", OrgID: "a", Scopes: []string{model.ScopeReportsRead, model.ScopeUsersRead}}
+	userWriter := model.Principal{UserID: "reader", OrgID: "a", Scopes: []string{model.ScopeReportsRead, model.ScopeUsersWrite}}
+
+	public := model.Report{ID: "r1", AuthorID: "author", OrgID: "a", Visibility: model.VisibilityPublic}
+	private := model.Report{ID: "r2", AuthorID: "author", OrgID: "a", Visibility: model.VisibilityPrivate}
+	user := model.User{ID: "author", OrgID: "a", Email: "author@example.com"}
+	cases := []struct {
+		name   string
+		caller model.Principal
+		event  model.Event
+		want   bool
+	}{
+		{"public report", reader, model.Event{Type: model.EventReportCreated, OrgID: "a", Data: public}, true},
+		{"private report", reader, model.Event{Type: model.EventReportCreated, OrgID: "a", Data: private}, false},
+		{"other organization", reader, model.Event{Type: model.EventReportCreated, OrgID: "b", Data: public}, false},
+		{"user without users:read", reader, model.Event{Type: model.EventUserUpdated, OrgID: "a", Data: user}, false},
+		{"own user without users:read", model.Principal{UserID: "author", OrgID: "a", Scopes: reader.Scopes}, model.Event{Type: model.EventUserUpdated, OrgID: "a", Data: user}, false},
+		{"user with users:read", userReader, model.Event{Type: model.EventUserUpdated, OrgID: "a", Data: user}, true},
+		{"user with users:write", userWriter, model.Event{Type: model.EventUserUpdated, OrgID: "a", Data: user}, true},
+		{"user of other organization", userReader, model.Event{Type: model.EventUserUpdated, OrgID: "b", Data: user}, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, CanSeeEvent(c.caller, c.event), c.name)
+	}
+}
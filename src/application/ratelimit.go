@@ -0,0 +1,23 @@
+package application
+
+import "time"
+
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets. The in-memory store limits each
+// instance separately; a shared implementation lets several instances
+// enforce one limit.
+type RateLimitStore interface {
+	// Take consumes a token from the bucket named key, which holds up to
+	// limit tokens and refills at limit tokens per window.
+	Take(key string, limit int, window time.Duration, now time.Time) (RateLimitDecision, error)
+}
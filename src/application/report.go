@@ -2,66 +2,200 @@ package application
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 	"repo-api/src/domain/model"
 	"repo-api/src/domain/repository"
 )
 
+// changeSettle is how long a change waits before the feed serves it. Cursors
+// are sequence numbers taken when a change is recorded, not when it commits,
+// so a change committed late could land behind a cursor a client has already
+// moved past. Transactions that record changes are far shorter than this.
+const changeSettle = 5 * time.Second
+
+// ReportApp enforces the report policy for Caller: only the author or an
+// administrator may change a report, and private reports of other authors
+// are refused by ID and left out of listings. Reports shared with Caller are
+// readable, and editable when shared at the edit level.
 type ReportApp interface {
-	Register(DB *sql.DB, report model.Report) error
-	Eject(DB *sql.DB, ID string) error
-	Get(DB *sql.DB, ID, AuthorID, Title, Style, Language string) ([]model.Report, error)
-	Update(DB *sql.DB, ID string, Count int, Title, Style, Language string) error
+	Register(DB *sql.DB, Caller model.Principal, report model.Report) (model.Report, error)
+	Eject(DB *sql.DB, Caller model.Principal, ID string) error
+	Get(DB *sql.DB, Caller model.Principal, ID, AuthorID, Title, Style, Language string) ([]model.Report, error)
+	Update(DB *sql.DB, Caller model.Principal, ID string, Count int, Title, Style, Language, Visibility string) error
+	Changes(DB *sql.DB, Caller model.Principal, AuthorID string, Since uint64, Limit int) (model.ChangeFeed, error)
+	// Stats summarizes the reports of AuthorID that Caller can read, with
+	// the activity of the last Days days.
+	Stats(DB *sql.DB, Caller model.Principal, AuthorID string, Days int) (model.ReportStats, error)
 }
 
-func NewReportApp(rr repository.IReportRepository) ReportApp {
+func NewReportApp(rr repository.IReportRepository, sr repository.IShareRepository, ur repository.IUserRepository, ep EventPublisher) ReportApp {
 	return &reportApp{
 		reportRepository: rr,
+		shareRepository:  sr,
+		userRepository:   ur,
+		eventPublisher:   ep,
 	}
 }
 
 type reportApp struct {
 	reportRepository repository.IReportRepository
+	shareRepository  repository.IShareRepository
+	userRepository   repository.IUserRepository
+	eventPublisher   EventPublisher
+}
+
+// shareLevel returns the level report is shared with Caller at, skipping
+// the lookup when Caller may do everything anyway.
+func (r reportApp) shareLevel(DB *sql.DB, Caller model.Principal, report model.Report) (string, error) {
+	if CanModifyReport(Caller, report) {
+		return "", nil
+	}
+	level, err := r.shareRepository.GetLevel(DB, report.ID, Caller.UserID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get share of report ID %s: %w", report.ID, err)
+	}
+	return level, nil
 }
 
-func (r reportApp) Register(DB *sql.DB, report model.Report) error {
-	err := r.reportRepository.Insert(DB, report.ID, report.AuthorID, report.Count, report.Title, report.Style, report.Language)
+// withAuthorDefaults fills in the style, language and count report omits
+// from the profile of its author.
+func (r reportApp) withAuthorDefaults(DB *sql.DB, OrgID string, report model.Report) (model.Report, error) {
+	if report.Style != "" && report.Language != "" && report.Count != 0 {
+		return report, nil
+	}
+	author, err := getTenantUser(DB, r.userRepository, OrgID, report.AuthorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Report{}, fmt.Errorf("author does not exist")
+		}
+		return model.Report{}, fmt.Errorf("failed to get author by ID %s: %w", report.AuthorID, err)
+	}
+	if report.Style == "" {
+		report.Style = author.DefaultStyle
+	}
+	if report.Language == "" {
+		report.Language = author.DefaultLanguage
+	}
+	if report.Count == 0 {
+		report.Count = author.DefaultCount
+	}
+	return report, nil
+}
+
+// Register returns report as stored, with the author's defaults filled in.
+func (r reportApp) Register(DB *sql.DB, Caller model.Principal, report model.Report) (model.Report, error) {
+	if !CanActAsAuthor(Caller, report.AuthorID) {
+		return model.Report{}, fmt.Errorf("forbidden")
+	}
+	if report.Visibility == "" {
+		report.Visibility = model.VisibilityPrivate
+	}
+	if !validVisibility(report.Visibility) {
+		return model.Report{}, fmt.Errorf("invalid visibility")
+	}
+
+	report, err := r.withAuthorDefaults(DB, Caller.OrgID, report)
+	if err != nil {
+		return model.Report{}, err
+	}
+	if report.Style == "" || report.Language == "" || report.Count == 0 {
+		return model.Report{}, fmt.Errorf("missing fields")
+	}
+
+	report.OrgID = Caller.OrgID
+	err = r.reportRepository.Insert(DB, report.ID, report.AuthorID, report.OrgID, report.Count, report.Title, report.Style, report.Language, report.Visibility)
 	if err != nil {
-	    if err.Error() == "author does not exist" {
-	        return fmt.Errorf("author does not exist")
+	    if err.Error() == "author does not exist" || err.Error() == "author not active" {
+	        return model.Report{}, err
         }
-		return fmt.Errorf("failed to insert report with ID %s: %w", report.ID, err)
+		return model.Report{}, fmt.Errorf("failed to insert report with ID %s: %w", report.ID, err)
 	}
-	return nil
+
+	r.eventPublisher.Publish(model.Event{Type: model.EventReportCreated, AuthorID: report.AuthorID, OrgID: report.OrgID, Data: report})
+	return report, nil
 }
 
-func (r reportApp) Eject(DB *sql.DB, ID string) error {
-	err := r.reportRepository.Eject(DB, ID)
+func (r reportApp) Eject(DB *sql.DB, Caller model.Principal, ID string) error {
+	report, err := r.reportRepository.GetByID(DB, Caller.OrgID, ID)
+	if err != nil {
+		return fmt.Errorf("failed to get report by ID %s: %w", ID, err)
+	}
+	if report.ID == "" || !InTenant(Caller, report.OrgID) {
+		return fmt.Errorf("report not found")
+	}
+	if !CanModifyReport(Caller, report) {
+		return fmt.Errorf("forbidden")
+	}
+
+	err = r.reportRepository.Eject(DB, ID)
 	if err != nil {
 		if err.Error() == "report not found" {
 			return fmt.Errorf("report not found")
 		}
 		return fmt.Errorf("failed to eject report with ID %s: %w", ID, err)
 	}
+
+	r.eventPublisher.Publish(model.Event{Type: model.EventReportEjected, AuthorID: report.AuthorID, OrgID: report.OrgID, Data: report})
 	return nil
 }
 
-func (r reportApp) Get(DB *sql.DB, ID, AuthorID, Title, Style, Language string) ([]model.Report, error) {
+// readable is CanReadReport, except that public reports of deactivated
+// authors are hidden from callers who could read them only for being
+// public. statuses caches the status of each author looked up.
+func (r reportApp) readable(DB *sql.DB, Caller model.Principal, report model.Report, statuses map[string]string) (bool, error) {
+	if !CanReadReport(Caller, report) {
+		return false, nil
+	}
+	if CanModifyReport(Caller, report) || HasScope(Caller.Scopes, model.ScopeReportsReadAll) {
+		return true, nil
+	}
+	status, found := statuses[report.AuthorID]
+	if !found {
+		author, err := r.userRepository.GetByID(DB, report.OrgID, report.AuthorID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("failed to get author by ID %s: %w", report.AuthorID, err)
+		}
+		status = author.Status
+		statuses[report.AuthorID] = status
+	}
+	return status != model.UserDeactivated, nil
+}
+
+func (r reportApp) Get(DB *sql.DB, Caller model.Principal, ID, AuthorID, Title, Style, Language string) ([]model.Report, error) {
 	var reports []model.Report
+	statuses := make(map[string]string)
 
 	if ID != "" {
 		var report model.Report
-		report, err := r.reportRepository.GetByID(DB, ID)
+		report, err := r.reportRepository.GetByID(DB, Caller.OrgID, ID)
 		if err != nil {	
 		    if err.Error() == "author does not exist" {
 	            return nil, fmt.Errorf("author does not exist")
             }
 			return nil, fmt.Errorf("failed to get report by ID %s: %w", ID, err)
 		}
+		if report.ID == "" || !InTenant(Caller, report.OrgID) {
+			return nil, fmt.Errorf("report not found")
+		}
+		readable, err := r.readable(DB, Caller, report, statuses)
+		if err != nil {
+			return nil, err
+		}
+		if !readable {
+			level, err := r.shareLevel(DB, Caller, report)
+			if err != nil {
+				return nil, err
+			}
+			if !ShareAllows(level, model.ShareRead) {
+				return nil, fmt.Errorf("forbidden")
+			}
+		}
 		reports = append(reports, report)
 	} else {
 		if AuthorID != "" {
-			authorIDReports, err := r.reportRepository.GetByAuthorID(DB, AuthorID)
+			authorIDReports, err := r.reportRepository.GetByAuthorID(DB, Caller.OrgID, AuthorID)
 			if err != nil {	
 			    if err.Error() == "author does not exist" {
 	                return nil, fmt.Errorf("author does not exist")
@@ -73,7 +207,7 @@ func (r reportApp) Get(DB *sql.DB, ID, AuthorID, Title, Style, Language string)
 
 		if Title != "" {
 			var titleReports []model.Report
-			titleReports, err := r.reportRepository.GetByTitle(DB, AuthorID, Title)
+			titleReports, err := r.reportRepository.GetByTitle(DB, Caller.OrgID, AuthorID, Title)
 			if err != nil {
                 if err.Error() == "author does not exist" {
 	                return nil, fmt.Errorf("author does not exist")
@@ -85,7 +219,7 @@ func (r reportApp) Get(DB *sql.DB, ID, AuthorID, Title, Style, Language string)
 
 		if Style != "" {
 			var styleReports []model.Report
-			styleReports, err := r.reportRepository.GetByStyle(DB, AuthorID, Style)
+			styleReports, err := r.reportRepository.GetByStyle(DB, Caller.OrgID, AuthorID, Style)
 			if err != nil {
 			    if err.Error() == "author does not exist" {
 	                return nil, fmt.Errorf("author does not exist")
@@ -97,7 +231,7 @@ func (r reportApp) Get(DB *sql.DB, ID, AuthorID, Title, Style, Language string)
 
 		if Language != "" {
 			var languageReports []model.Report
-			languageReports, err := r.reportRepository.GetByLanguage(DB, AuthorID, Language)
+			languageReports, err := r.reportRepository.GetByLanguage(DB, Caller.OrgID, AuthorID, Language)
 			if err != nil {
 			    if err.Error() == "author does not exist" {
 			        return nil, fmt.Errorf("author does not exist")
@@ -106,6 +240,29 @@ func (r reportApp) Get(DB *sql.DB, ID, AuthorID, Title, Style, Language string)
 			}
 			reports = intersectReports(reports, languageReports)
 		}
+
+		var shared map[string]string
+		readable := reports[:0]
+		for _, report := range reports {
+			ok, err := r.readable(DB, Caller, report, statuses)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				if shared == nil {
+					levels, err := r.shareRepository.GetLevelsByUserID(DB, Caller.UserID)
+					if err != nil {
+						return nil, fmt.Errorf("failed to get reports shared with %s: %w", Caller.UserID, err)
+					}
+					shared = levels
+				}
+				if !ShareAllows(shared[report.ID], model.ShareRead) {
+					continue
+				}
+			}
+			readable = append(readable, report)
+		}
+		reports = readable
 	}
 
 	if len(reports) == 0 {
@@ -130,7 +287,28 @@ func intersectReports(a, b []model.Report) []model.Report {
     return result
 }
 
-func (r reportApp) Update(DB *sql.DB, ID string, Count int, Title, Style, Language string) error {
+func (r reportApp) Update(DB *sql.DB, Caller model.Principal, ID string, Count int, Title, Style, Language, Visibility string) error {
+	existing, err := r.reportRepository.GetByID(DB, Caller.OrgID, ID)
+	if err != nil {
+		return fmt.Errorf("failed to get report by ID %s: %w", ID, err)
+	}
+	if existing.ID == "" || !InTenant(Caller, existing.OrgID) {
+		return fmt.Errorf("report not found")
+	}
+	if !CanModifyReport(Caller, existing) {
+		// Editors may change the contents but not who can see them.
+		level, err := r.shareLevel(DB, Caller, existing)
+		if err != nil {
+			return err
+		}
+		if !ShareAllows(level, model.ShareEdit) || (Visibility != "" && Visibility != existing.Visibility) {
+			return fmt.Errorf("forbidden")
+		}
+	}
+	if Visibility != "" && !validVisibility(Visibility) {
+		return fmt.Errorf("invalid visibility")
+	}
+
 	if Count != 0 {
 		if err := r.reportRepository.UpdateCount(DB, ID, Count); err != nil {
 			return fmt.Errorf("failed to update title for report ID %s: %w", ID, err)
@@ -155,7 +333,104 @@ func (r reportApp) Update(DB *sql.DB, ID string, Count int, Title, Style, Langua
 		}
 	}
 
+	if Visibility != "" {
+		if err := r.reportRepository.UpdateVisibility(DB, ID, Visibility); err != nil {
+			return fmt.Errorf("failed to update visibility for report ID %s: %w", ID, err)
+		}
+	}
+
+	report, err := r.reportRepository.GetByID(DB, Caller.OrgID, ID)
+	if err != nil {
+		return fmt.Errorf("failed to get report by ID %s: %w", ID, err)
+	}
+	if report.ID != "" {
+		r.eventPublisher.Publish(model.Event{Type: model.EventReportUpdated, AuthorID: report.AuthorID, OrgID: report.OrgID, Data: report})
+	}
+
 	return nil
 }
 
+// Changes returns the author's report changes after the cursor Since. Within
+// a page only the latest change of each report is kept, and upserts of
+// reports that have since been ejected are left out; their tombstone follows
+// in the same or a later page. The feed is for syncing one's own reports, so
+// only the author and administrators may read it.
+func (r reportApp) Changes(DB *sql.DB, Caller model.Principal, AuthorID string, Since uint64, Limit int) (model.ChangeFeed, error) {
+	if !CanActAsAuthor(Caller, AuthorID) {
+		return model.ChangeFeed{}, fmt.Errorf("forbidden")
+	}
+
+	changes, err := r.reportRepository.GetChanges(DB, Caller.OrgID, AuthorID, Since, Limit+1, changeSettle)
+	if err != nil {
+		return model.ChangeFeed{}, fmt.Errorf("failed to get changes for AuthorID %s: %w", AuthorID, err)
+	}
+
+	feed := model.ChangeFeed{Changes: []model.ReportChange{}, NextCursor: Since}
+	if len(changes) > Limit {
+		changes = changes[:Limit]
+		feed.HasMore = true
+	}
+	if len(changes) > 0 {
+		feed.NextCursor = changes[len(changes)-1].Cursor
+	}
+
+	latest := make(map[string]uint64)
+	for _, change := range changes {
+		latest[change.ReportID] = change.Cursor
+	}
+	for _, change := range changes {
+		if latest[change.ReportID] != change.Cursor {
+			continue
+		}
+		if change.Operation == model.ChangeUpsert && change.Report == nil {
+			continue
+		}
+		if change.Operation == model.ChangeDelete {
+			change.Report = nil
+		}
+		feed.Changes = append(feed.Changes, change)
+	}
+	return feed, nil
+}
+
+// Stats counts only public reports for callers other than the author who
+// cannot read every report, like the report counts of the user listing.
+func (r reportApp) Stats(DB *sql.DB, Caller model.Principal, AuthorID string, Days int) (model.ReportStats, error) {
+	if Days < 1 {
+		return model.ReportStats{}, fmt.Errorf("invalid days")
+	}
+	author, err := getTenantUser(DB, r.userRepository, Caller.OrgID, AuthorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ReportStats{}, fmt.Errorf("user not found")
+		}
+		return model.ReportStats{}, fmt.Errorf("failed to get user by ID %s: %w", AuthorID, err)
+	}
+	publicOnly := Caller.UserID != AuthorID && !isAdmin(Caller) && !HasScope(Caller.Scopes, model.ScopeReportsReadAll)
 
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-Days)
+	stats := model.ReportStats{AuthorID: AuthorID, ByStyle: map[string]int{}, ByLanguage: map[string]int{}}
+	// The public reports of deactivated authors are hidden, as in Get.
+	if !publicOnly || author.Status != model.UserDeactivated {
+		stats, err = r.reportRepository.GetStats(DB, Caller.OrgID, AuthorID, publicOnly, since)
+		if err != nil {
+			return model.ReportStats{}, fmt.Errorf("failed to get stats of author %s: %w", AuthorID, err)
+		}
+	}
+
+	byDate := make(map[string]model.ReportActivity, len(stats.Activity))
+	for _, activity := range stats.Activity {
+		byDate[activity.Date] = activity
+	}
+	stats.Activity = make([]model.ReportActivity, 0, Days)
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		activity, ok := byDate[date]
+		if !ok {
+			activity = model.ReportActivity{Date: date}
+		}
+		stats.Activity = append(stats.Activity, activity)
+	}
+	return stats, nil
+}
@@ -0,0 +1,166 @@
+package application
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+type RoleApp interface {
+	// Authorize looks up the role of an authenticated principal and sets its
+	// effective scopes: everything the role grants for sessions, and only
+	// what both the role and the key grant for API keys. Deactivated users
+	// are refused, and suspended ones limited to the read scopes.
+	Authorize(DB *sql.DB, p model.Principal) (model.Principal, error)
+	Get(DB *sql.DB, Caller model.Principal, UserID string) (model.UserRole, error)
+	Assign(DB *sql.DB, Caller model.Principal, UserID, Role string) (model.UserRole, error)
+	// Permissions spells out every scope p has, including the implied ones,
+	// so clients need not know how scopes imply each other.
+	Permissions(p model.Principal) model.UserRole
+}
+
+// NewRoleApp takes the permission matrix, the scopes granted by each role.
+func NewRoleApp(rr repository.IRoleRepository, ur repository.IUserRepository, matrix map[string][]string) RoleApp {
+	return &roleApp{
+		roleRepository: rr,
+		userRepository: ur,
+		matrix:         matrix,
+	}
+}
+
+type roleApp struct {
+	roleRepository repository.IRoleRepository
+	userRepository repository.IUserRepository
+	matrix         map[string][]string
+}
+
+func (r *roleApp) checkTenant(DB *sql.DB, Caller model.Principal, UserID string) error {
+	if _, err := getTenantUser(DB, r.userRepository, Caller.OrgID, UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to get user by ID %s: %w", UserID, err)
+	}
+	return nil
+}
+
+func (r *roleApp) Authorize(DB *sql.DB, p model.Principal) (model.Principal, error) {
+	role, err := r.roleRepository.GetRole(DB, p.UserID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return model.Principal{}, err
+		}
+		return model.Principal{}, fmt.Errorf("failed to get role of user %s: %w", p.UserID, err)
+	}
+
+	user, err := r.userRepository.GetCaller(DB, p.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Principal{}, fmt.Errorf("user not found")
+		}
+		return model.Principal{}, fmt.Errorf("failed to get user by ID %s: %w", p.UserID, err)
+	}
+	if user.Status == model.UserDeactivated {
+		return model.Principal{}, fmt.Errorf("user deactivated")
+	}
+
+	granted := r.matrix[role]
+	if user.Status == model.UserSuspended {
+		granted = readScopes(granted)
+		p.Suspended = true
+	}
+	p.Role = role
+	if p.KeyID == "" {
+		p.Scopes = slices.Clone(granted)
+		return p, nil
+	}
+
+	// Intersect taking implications into account: a users:admin key of a
+	// student keeps the student's scopes, a reports:read key of a teacher
+	// keeps reports:read.
+	var scopes []string
+	for _, scope := range p.Scopes {
+		if HasScope(granted, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	for _, scope := range granted {
+		if HasScope(p.Scopes, scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	p.Scopes = scopes
+	return p, nil
+}
+
+func (r *roleApp) Get(DB *sql.DB, Caller model.Principal, UserID string) (model.UserRole, error) {
+	if !CanModifyUser(Caller, UserID) {
+		return model.UserRole{}, fmt.Errorf("forbidden")
+	}
+	if err := r.checkTenant(DB, Caller, UserID); err != nil {
+		return model.UserRole{}, err
+	}
+	role, err := r.roleRepository.GetRole(DB, UserID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return model.UserRole{}, err
+		}
+		return model.UserRole{}, fmt.Errorf("failed to get role of user %s: %w", UserID, err)
+	}
+	return model.UserRole{UserID: UserID, Role: role, Permissions: expandScopes(r.matrix[role])}, nil
+}
+
+// Assign refuses administrators changing their own role, so that the last
+// administrator cannot lock everyone out by accident.
+func (r *roleApp) Assign(DB *sql.DB, Caller model.Principal, UserID, Role string) (model.UserRole, error) {
+	if !isAdmin(Caller) {
+		return model.UserRole{}, fmt.Errorf("forbidden")
+	}
+	if _, ok := r.matrix[Role]; !ok {
+		return model.UserRole{}, fmt.Errorf("invalid role")
+	}
+	if UserID == Caller.UserID {
+		return model.UserRole{}, fmt.Errorf("cannot change own role")
+	}
+	if err := r.checkTenant(DB, Caller, UserID); err != nil {
+		return model.UserRole{}, err
+	}
+
+	if err := r.roleRepository.UpdateRole(DB, UserID, Role); err != nil {
+		if err.Error() == "user not found" {
+			return model.UserRole{}, err
+		}
+		return model.UserRole{}, fmt.Errorf("failed to assign role %s to user %s: %w", Role, UserID, err)
+	}
+	return model.UserRole{UserID: UserID, Role: Role, Permissions: expandScopes(r.matrix[Role])}, nil
+}
+
+func (r *roleApp) Permissions(p model.Principal) model.UserRole {
+	return model.UserRole{UserID: p.UserID, Role: p.Role, Permissions: expandScopes(p.Scopes)}
+}
+
+// readScopes keeps what scopes grant of the scopes that change nothing.
+func readScopes(scopes []string) []string {
+	var read []string
+	for _, scope := range []string{model.ScopeReportsRead, model.ScopeReportsReadAll, model.ScopeUsersRead} {
+		if HasScope(scopes, scope) {
+			read = append(read, scope)
+		}
+	}
+	return read
+}
+
+func expandScopes(scopes []string) []string {
+	expanded := []string{}
+	for _, scope := range APIKeyScopes {
+		if HasScope(scopes, scope) {
+			expanded = append(expanded, scope)
+		}
+	}
+	sort.Strings(expanded)
+	return expanded
+}
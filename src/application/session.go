@@ -0,0 +1,355 @@
+package application
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+const (
+	refreshTokenPrefix = "rt_"
+	accessTokenIssuer  = "repoapi"
+	minPasswordLength  = 8
+	// bcrypt ignores everything after the 72nd byte.
+	maxPasswordLength = 72
+)
+
+type SessionConfig struct {
+	// Secret signs the access tokens.
+	Secret           []byte
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+	MaxLoginAttempts int
+	Lockout          time.Duration
+}
+
+// LoginLockedError is returned by Login while an account is locked after too
+// many failed attempts.
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return "account locked"
+}
+
+type SessionApp interface {
+	Login(DB *sql.DB, UserID, Password string) (model.TokenPair, error)
+	// Start begins a session for a user who logged in elsewhere, such as at
+	// an OpenID Connect provider.
+	Start(DB *sql.DB, UserID string) (model.TokenPair, error)
+	// Refresh exchanges a refresh token for a new pair. Each refresh token
+	// works once; presenting a used one again revokes the whole session, as
+	// it means the token was copied.
+	Refresh(DB *sql.DB, RefreshToken string) (model.TokenPair, error)
+	Logout(DB *sql.DB, SessionID string) error
+	// ChangePassword checks Current unless the user has no password yet.
+	ChangePassword(DB *sql.DB, OrgID, UserID, Current, New string) error
+	// SetPassword sets the password without checking the current one, for
+	// administrators.
+	SetPassword(DB *sql.DB, OrgID, UserID, New string) error
+	// Authenticate resolves an access token to the principal it was issued
+	// to. Tokens of logged out sessions are refused even before they expire.
+	Authenticate(DB *sql.DB, Token string) (model.Principal, error)
+}
+
+func NewSessionApp(sr repository.ISessionRepository, ur repository.IUserRepository, config SessionConfig) SessionApp {
+	return &sessionApp{
+		sessionRepository: sr,
+		userRepository:    ur,
+		config:            config,
+	}
+}
+
+type sessionApp struct {
+	sessionRepository repository.ISessionRepository
+	userRepository    repository.IUserRepository
+	config            SessionConfig
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyHash spends as long as a real password check, so that the
+// response time does not tell whether a user has a password.
+func compareDummyHash(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("repoapi-dummy-password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func (s *sessionApp) Login(DB *sql.DB, UserID, Password string) (model.TokenPair, error) {
+	credential, err := s.sessionRepository.GetCredential(DB, UserID)
+	if err != nil {
+		if err.Error() == "credential not found" {
+			compareDummyHash(Password)
+			return model.TokenPair{}, fmt.Errorf("invalid credentials")
+		}
+		return model.TokenPair{}, fmt.Errorf("failed to get credential of user %s: %w", UserID, err)
+	}
+
+	now := time.Now().UTC()
+	if credential.LockedUntil != nil && credential.LockedUntil.After(now) {
+		return model.TokenPair{}, &LoginLockedError{Until: *credential.LockedUntil}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(Password)) != nil {
+		lockUntil := now.Add(s.config.Lockout).Truncate(time.Second)
+		lockedUntil, err := s.sessionRepository.RecordLoginFailure(DB, UserID, s.config.MaxLoginAttempts, now, lockUntil)
+		if err != nil {
+			return model.TokenPair{}, fmt.Errorf("failed to record login failure of user %s: %w", UserID, err)
+		}
+		if lockedUntil != nil {
+			return model.TokenPair{}, &LoginLockedError{Until: *lockedUntil}
+		}
+		return model.TokenPair{}, fmt.Errorf("invalid credentials")
+	}
+
+	if credential.FailedAttempts > 0 || credential.LockedUntil != nil {
+		if err := s.sessionRepository.UpdateLoginFailures(DB, UserID, 0, nil); err != nil {
+			log.Printf("Error resetting login failures of user %s: %v", UserID, err)
+		}
+	}
+	return s.start(DB, UserID, now)
+}
+
+func (s *sessionApp) Start(DB *sql.DB, UserID string) (model.TokenPair, error) {
+	return s.start(DB, UserID, time.Now().UTC())
+}
+
+func (s *sessionApp) start(DB *sql.DB, UserID string, now time.Time) (model.TokenPair, error) {
+	refreshToken, secret, err := s.newRefreshToken(UserID, uuid.New().String(), now)
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+	if err := s.sessionRepository.InsertRefreshToken(DB, refreshToken); err != nil {
+		return model.TokenPair{}, fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	return s.tokenPair(UserID, refreshToken.FamilyID, secret, now)
+}
+
+func (s *sessionApp) Refresh(DB *sql.DB, RefreshToken string) (model.TokenPair, error) {
+	if !strings.HasPrefix(RefreshToken, refreshTokenPrefix) {
+		return model.TokenPair{}, fmt.Errorf("invalid refresh token")
+	}
+	old, err := s.sessionRepository.GetRefreshTokenByHash(DB, hashToken(RefreshToken))
+	if err != nil {
+		if err.Error() == "refresh token not found" {
+			return model.TokenPair{}, fmt.Errorf("invalid refresh token")
+		}
+		return model.TokenPair{}, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if old.RevokedAt != nil {
+		if old.ReplacedBy != "" {
+			return model.TokenPair{}, s.revokeReused(DB, old, now)
+		}
+		return model.TokenPair{}, fmt.Errorf("invalid refresh token")
+	}
+	if !old.ExpiresAt.After(now) {
+		return model.TokenPair{}, fmt.Errorf("invalid refresh token")
+	}
+
+	next, secret, err := s.newRefreshToken(old.UserID, old.FamilyID, now)
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+	if err := s.sessionRepository.RotateRefreshToken(DB, old, next); err != nil {
+		if err.Error() == "refresh token already used" {
+			return model.TokenPair{}, s.revokeReused(DB, old, now)
+		}
+		return model.TokenPair{}, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return s.tokenPair(old.UserID, old.FamilyID, secret, now)
+}
+
+func (s *sessionApp) revokeReused(DB *sql.DB, token model.RefreshToken, now time.Time) error {
+	log.Printf("Refresh token %s of user %s was reused; revoking session %s", token.ID, token.UserID, token.FamilyID)
+	if err := s.sessionRepository.RevokeRefreshFamily(DB, token.FamilyID, now.Truncate(time.Second)); err != nil {
+		return fmt.Errorf("failed to revoke session %s: %w", token.FamilyID, err)
+	}
+	return fmt.Errorf("invalid refresh token")
+}
+
+func (s *sessionApp) Logout(DB *sql.DB, SessionID string) error {
+	if err := s.sessionRepository.RevokeRefreshFamily(DB, SessionID, time.Now().UTC().Truncate(time.Second)); err != nil {
+		return fmt.Errorf("failed to revoke session %s: %w", SessionID, err)
+	}
+	return nil
+}
+
+func (s *sessionApp) ChangePassword(DB *sql.DB, OrgID, UserID, Current, New string) error {
+	credential, err := s.sessionRepository.GetCredential(DB, UserID)
+	if err != nil && err.Error() != "credential not found" {
+		return fmt.Errorf("failed to get credential of user %s: %w", UserID, err)
+	}
+	if err == nil && bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(Current)) != nil {
+		return fmt.Errorf("invalid credentials")
+	}
+	return s.SetPassword(DB, OrgID, UserID, New)
+}
+
+// SetPassword logs the user out everywhere, so a leaked password stops
+// working as soon as it is changed.
+func (s *sessionApp) SetPassword(DB *sql.DB, OrgID, UserID, New string) error {
+	if len(New) < minPasswordLength || len(New) > maxPasswordLength {
+		return fmt.Errorf("invalid password")
+	}
+	if _, err := getTenantUser(DB, s.userRepository, OrgID, UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to get user by ID: %w", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(New), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.sessionRepository.UpsertCredential(DB, UserID, string(hash)); err != nil {
+		return fmt.Errorf("failed to save password of user %s: %w", UserID, err)
+	}
+	if err := s.sessionRepository.RevokeUserRefreshTokens(DB, UserID, time.Now().UTC().Truncate(time.Second)); err != nil {
+		return fmt.Errorf("failed to revoke sessions of user %s: %w", UserID, err)
+	}
+	return nil
+}
+
+func (s *sessionApp) Authenticate(DB *sql.DB, Token string) (model.Principal, error) {
+	now := time.Now().UTC()
+	claims, err := parseAccessToken(s.config.Secret, Token, now)
+	if err != nil {
+		return model.Principal{}, err
+	}
+
+	active, err := s.sessionRepository.IsFamilyActive(DB, claims.SessionID, now)
+	if err != nil {
+		return model.Principal{}, fmt.Errorf("failed to check session %s: %w", claims.SessionID, err)
+	}
+	if !active {
+		return model.Principal{}, fmt.Errorf("session revoked")
+	}
+	// A session may do whatever the user's role allows; RoleApp.Authorize
+	// fills in the scopes.
+	return model.Principal{UserID: claims.Subject, SessionID: claims.SessionID}, nil
+}
+
+func (s *sessionApp) newRefreshToken(UserID, FamilyID string, now time.Time) (model.RefreshToken, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return model.RefreshToken{}, "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	secret := refreshTokenPrefix + hex.EncodeToString(buf)
+	return model.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    UserID,
+		FamilyID:  FamilyID,
+		Hash:      hashToken(secret),
+		ExpiresAt: now.Add(s.config.RefreshTokenTTL).Truncate(time.Second),
+		CreatedAt: now.Truncate(time.Second),
+	}, secret, nil
+}
+
+func (s *sessionApp) tokenPair(UserID, SessionID, refreshToken string, now time.Time) (model.TokenPair, error) {
+	accessToken, err := signAccessToken(s.config.Secret, accessTokenClaims{
+		Issuer:    accessTokenIssuer,
+		Subject:   UserID,
+		SessionID: SessionID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.config.AccessTokenTTL).Unix(),
+		ID:        uuid.New().String(),
+	})
+	if err != nil {
+		return model.TokenPair{}, err
+	}
+	return model.TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.config.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// accessTokenClaims are the registered JWT claims plus the session.
+type accessTokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+var accessTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func signAccessToken(secret []byte, claims accessTokenClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode access token: %w", err)
+	}
+	signingInput := accessTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// parseAccessToken only accepts HS256 tokens signed with secret, so that a
+// token cannot pick a weaker algorithm through its header.
+func parseAccessToken(secret []byte, token string, now time.Time) (accessTokenClaims, error) {
+	var claims accessTokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("invalid token")
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, fmt.Errorf("invalid token")
+	}
+	var h struct {
+		Algorithm string `json:"alg"`
+	}
+	if json.Unmarshal(header, &h) != nil || h.Algorithm != "HS256" {
+		return claims, fmt.Errorf("invalid token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("invalid token")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return claims, fmt.Errorf("invalid token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, fmt.Errorf("invalid token")
+	}
+	if claims.Issuer != accessTokenIssuer || claims.Subject == "" || claims.SessionID == "" {
+		return claims, fmt.Errorf("invalid token")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return claims, fmt.Errorf("token expired")
+	}
+	return claims, nil
+}
@@ -0,0 +1,121 @@
+package application
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+// ShareApp manages who a report is shared with. Only those who may modify a
+// report share it, only with users of the same organization, and users can
+// give up a share they received.
+type ShareApp interface {
+	Grant(DB *sql.DB, Caller model.Principal, ReportID, UserID, Level string) (model.ReportShare, error)
+	Revoke(DB *sql.DB, Caller model.Principal, ReportID, UserID string) error
+	List(DB *sql.DB, Caller model.Principal, ReportID string) ([]model.ReportShare, error)
+	SharedWith(DB *sql.DB, Caller model.Principal) ([]model.SharedReport, error)
+}
+
+func NewShareApp(sr repository.IShareRepository, rr repository.IReportRepository, ur repository.IUserRepository) ShareApp {
+	return &shareApp{
+		shareRepository:  sr,
+		reportRepository: rr,
+		userRepository:   ur,
+	}
+}
+
+type shareApp struct {
+	shareRepository  repository.IShareRepository
+	reportRepository repository.IReportRepository
+	userRepository   repository.IUserRepository
+}
+
+func (s *shareApp) getReport(DB *sql.DB, Caller model.Principal, ReportID string) (model.Report, error) {
+	report, err := s.reportRepository.GetByID(DB, Caller.OrgID, ReportID)
+	if err != nil {
+		return report, fmt.Errorf("failed to get report by ID %s: %w", ReportID, err)
+	}
+	if report.ID == "" || !InTenant(Caller, report.OrgID) {
+		return report, fmt.Errorf("report not found")
+	}
+	return report, nil
+}
+
+func (s *shareApp) Grant(DB *sql.DB, Caller model.Principal, ReportID, UserID, Level string) (model.ReportShare, error) {
+	if !validShareLevel(Level) {
+		return model.ReportShare{}, fmt.Errorf("invalid share level")
+	}
+	report, err := s.getReport(DB, Caller, ReportID)
+	if err != nil {
+		return model.ReportShare{}, err
+	}
+	if !CanModifyReport(Caller, report) {
+		return model.ReportShare{}, fmt.Errorf("forbidden")
+	}
+	if UserID == report.AuthorID {
+		return model.ReportShare{}, fmt.Errorf("cannot share with the author")
+	}
+	if _, err := getTenantUser(DB, s.userRepository, Caller.OrgID, UserID); err != nil {
+		if err == sql.ErrNoRows {
+			return model.ReportShare{}, fmt.Errorf("user not found")
+		}
+		return model.ReportShare{}, fmt.Errorf("failed to get user by ID %s: %w", UserID, err)
+	}
+
+	share := model.ReportShare{
+		ReportID:  ReportID,
+		UserID:    UserID,
+		Level:     Level,
+		GrantedBy: Caller.UserID,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := s.shareRepository.Upsert(DB, share); err != nil {
+		return model.ReportShare{}, fmt.Errorf("failed to share report ID %s with %s: %w", ReportID, UserID, err)
+	}
+	return share, nil
+}
+
+func (s *shareApp) Revoke(DB *sql.DB, Caller model.Principal, ReportID, UserID string) error {
+	report, err := s.getReport(DB, Caller, ReportID)
+	if err != nil {
+		return err
+	}
+	if !CanModifyReport(Caller, report) && UserID != Caller.UserID {
+		return fmt.Errorf("forbidden")
+	}
+
+	if err := s.shareRepository.Delete(DB, ReportID, UserID); err != nil {
+		if err.Error() == "share not found" {
+			return err
+		}
+		return fmt.Errorf("failed to revoke share of report ID %s with %s: %w", ReportID, UserID, err)
+	}
+	return nil
+}
+
+func (s *shareApp) List(DB *sql.DB, Caller model.Principal, ReportID string) ([]model.ReportShare, error) {
+	report, err := s.getReport(DB, Caller, ReportID)
+	if err != nil {
+		return nil, err
+	}
+	if !CanModifyReport(Caller, report) {
+		return nil, fmt.Errorf("forbidden")
+	}
+
+	shares, err := s.shareRepository.GetByReportID(DB, ReportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shares of report ID %s: %w", ReportID, err)
+	}
+	return shares, nil
+}
+
+func (s *shareApp) SharedWith(DB *sql.DB, Caller model.Principal) ([]model.SharedReport, error) {
+	reports, err := s.shareRepository.GetSharedWith(DB, Caller.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports shared with %s: %w", Caller.UserID, err)
+	}
+	return reports, nil
+}
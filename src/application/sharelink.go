@@ -0,0 +1,211 @@
+package application
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+type ShareLinkConfig struct {
+	// Secret signs the link tokens; changing it invalidates every link.
+	Secret     []byte
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// ShareLinkApp mints links that let people without an account read a
+// report. Only those who may modify a report create links for it.
+type ShareLinkApp interface {
+	// Create returns the link together with its token. TTL 0 picks the
+	// default lifetime.
+	Create(DB *sql.DB, Caller model.Principal, ReportID string, TTL time.Duration, SingleUse bool) (model.ShareLink, string, error)
+	List(DB *sql.DB, Caller model.Principal, ReportID string) ([]model.ShareLink, error)
+	// Revoke is idempotent: revoking a revoked link returns it unchanged.
+	Revoke(DB *sql.DB, Caller model.Principal, ID string) (model.ShareLink, error)
+	// Open checks the token and returns the report it links to, using up
+	// single-use links.
+	Open(DB *sql.DB, Token string) (model.Report, error)
+}
+
+func NewShareLinkApp(lr repository.IShareLinkRepository, rr repository.IReportRepository, config ShareLinkConfig) ShareLinkApp {
+	return &shareLinkApp{
+		shareLinkRepository: lr,
+		reportRepository:    rr,
+		config:              config,
+	}
+}
+
+type shareLinkApp struct {
+	shareLinkRepository repository.IShareLinkRepository
+	reportRepository    repository.IReportRepository
+	config              ShareLinkConfig
+}
+
+func (s *shareLinkApp) getReport(DB *sql.DB, Caller model.Principal, ReportID string) (model.Report, error) {
+	report, err := s.reportRepository.GetByID(DB, Caller.OrgID, ReportID)
+	if err != nil {
+		return report, fmt.Errorf("failed to get report by ID %s: %w", ReportID, err)
+	}
+	if report.ID == "" || !InTenant(Caller, report.OrgID) {
+		return report, fmt.Errorf("report not found")
+	}
+	return report, nil
+}
+
+func (s *shareLinkApp) Create(DB *sql.DB, Caller model.Principal, ReportID string, TTL time.Duration, SingleUse bool) (model.ShareLink, string, error) {
+	if TTL == 0 {
+		TTL = s.config.DefaultTTL
+	}
+	if TTL < time.Second || TTL > s.config.MaxTTL {
+		return model.ShareLink{}, "", fmt.Errorf("invalid expiry")
+	}
+	report, err := s.getReport(DB, Caller, ReportID)
+	if err != nil {
+		return model.ShareLink{}, "", err
+	}
+	if !CanModifyReport(Caller, report) {
+		return model.ShareLink{}, "", fmt.Errorf("forbidden")
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	link := model.ShareLink{
+		ID:        uuid.New().String(),
+		ReportID:  report.ID,
+		OrgID:     report.OrgID,
+		CreatedBy: Caller.UserID,
+		SingleUse: SingleUse,
+		ExpiresAt: now.Add(TTL),
+		CreatedAt: now,
+	}
+	if err := s.shareLinkRepository.Insert(DB, link); err != nil {
+		return model.ShareLink{}, "", fmt.Errorf("failed to create share link for report ID %s: %w", ReportID, err)
+	}
+	return link, signShareLink(s.config.Secret, link.ID, link.ExpiresAt), nil
+}
+
+func (s *shareLinkApp) List(DB *sql.DB, Caller model.Principal, ReportID string) ([]model.ShareLink, error) {
+	report, err := s.getReport(DB, Caller, ReportID)
+	if err != nil {
+		return nil, err
+	}
+	if !CanModifyReport(Caller, report) {
+		return nil, fmt.Errorf("forbidden")
+	}
+
+	links, err := s.shareLinkRepository.GetByReportID(DB, ReportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share links of report ID %s: %w", ReportID, err)
+	}
+	return links, nil
+}
+
+func (s *shareLinkApp) Revoke(DB *sql.DB, Caller model.Principal, ID string) (model.ShareLink, error) {
+	link, err := s.shareLinkRepository.GetByID(DB, Caller.OrgID, ID)
+	if err != nil {
+		if err.Error() == "link not found" {
+			return model.ShareLink{}, err
+		}
+		return model.ShareLink{}, fmt.Errorf("failed to get share link %s: %w", ID, err)
+	}
+	if !InTenant(Caller, link.OrgID) {
+		return model.ShareLink{}, fmt.Errorf("link not found")
+	}
+	// Whoever created the link may revoke it even after losing access to
+	// the report.
+	report, err := s.reportRepository.GetByID(DB, link.OrgID, link.ReportID)
+	if err != nil {
+		return model.ShareLink{}, fmt.Errorf("failed to get report by ID %s: %w", link.ReportID, err)
+	}
+	if link.CreatedBy != Caller.UserID && !CanModifyReport(Caller, report) && !isAdmin(Caller) {
+		return model.ShareLink{}, fmt.Errorf("forbidden")
+	}
+	if link.RevokedAt != nil {
+		return link, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := s.shareLinkRepository.Revoke(DB, ID, now); err != nil {
+		return model.ShareLink{}, fmt.Errorf("failed to revoke share link %s: %w", ID, err)
+	}
+	link.RevokedAt = &now
+	return link, nil
+}
+
+func (s *shareLinkApp) Open(DB *sql.DB, Token string) (model.Report, error) {
+	ID, expiresAt, err := parseShareLink(s.config.Secret, Token)
+	if err != nil {
+		return model.Report{}, err
+	}
+	now := time.Now().UTC()
+	if !now.Before(expiresAt) {
+		return model.Report{}, fmt.Errorf("link expired")
+	}
+
+	link, err := s.shareLinkRepository.GetForToken(DB, ID)
+	if err != nil {
+		if err.Error() == "link not found" {
+			return model.Report{}, fmt.Errorf("invalid link")
+		}
+		return model.Report{}, fmt.Errorf("failed to get share link %s: %w", ID, err)
+	}
+	if link.RevokedAt != nil {
+		return model.Report{}, fmt.Errorf("link revoked")
+	}
+	if link.SingleUse && link.UsedAt != nil {
+		return model.Report{}, fmt.Errorf("link already used")
+	}
+
+	report, err := s.reportRepository.GetByID(DB, link.OrgID, link.ReportID)
+	if err != nil {
+		return model.Report{}, fmt.Errorf("failed to get report by ID %s: %w", link.ReportID, err)
+	}
+	if report.ID == "" || report.OrgID != link.OrgID {
+		return model.Report{}, fmt.Errorf("report not found")
+	}
+
+	// Marking the link used only succeeds once, so two concurrent opens of a
+	// single-use link cannot both get the report.
+	if link.SingleUse {
+		if err := s.shareLinkRepository.MarkUsed(DB, ID, now.Truncate(time.Second)); err != nil {
+			if err.Error() == "link already used" {
+				return model.Report{}, err
+			}
+			return model.Report{}, fmt.Errorf("failed to use share link %s: %w", ID, err)
+		}
+	}
+	return report, nil
+}
+
+// signShareLink returns the token of a link: its ID and expiry, followed by
+// an HMAC-SHA256 over both so neither can be altered.
+func signShareLink(secret []byte, ID string, ExpiresAt time.Time) string {
+	payload := ID + "." + strconv.FormatInt(ExpiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func parseShareLink(secret []byte, Token string) (string, time.Time, error) {
+	parts := strings.Split(Token, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, fmt.Errorf("invalid link")
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid link")
+	}
+	expiresAt := time.Unix(expires, 0).UTC()
+	if !hmac.Equal([]byte(signShareLink(secret, parts[0], expiresAt)), []byte(Token)) {
+		return "", time.Time{}, fmt.Errorf("invalid link")
+	}
+	return parts[0], expiresAt, nil
+}
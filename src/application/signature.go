@@ -0,0 +1,113 @@
+package application
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+	"repo-api/src/signer"
+)
+
+// maxNonceLength bounds what a client can make the nonce store hold.
+const maxNonceLength = 128
+
+// NonceStore remembers the nonces of signed requests. Like RateLimitStore,
+// the in-memory store only sees the requests of its own instance.
+type NonceStore interface {
+	// Claim records key until expiry and reports whether it was not
+	// recorded already.
+	Claim(key string, expiry, now time.Time) (bool, error)
+}
+
+type SignatureConfig struct {
+	// MaxSkew is how far the timestamp of a request may be from the
+	// server's clock, in either direction.
+	MaxSkew time.Duration
+}
+
+// SignedRequest is what Authenticate needs of a request signed as described
+// in package signer. The fields named after headers hold them verbatim.
+type SignedRequest struct {
+	KeyID     string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Method    string
+	Path      string
+	RawQuery  string
+	Body      []byte
+}
+
+type SignatureApp interface {
+	// Authenticate verifies a signed request and resolves it to the
+	// principal its key was issued to, as APIKeyApp.Authenticate does for
+	// bearer tokens.
+	Authenticate(DB *sql.DB, Request SignedRequest) (model.Principal, error)
+}
+
+func NewSignatureApp(kr repository.IAPIKeyRepository, ns NonceStore, config SignatureConfig) SignatureApp {
+	return &signatureApp{
+		apiKeyRepository: kr,
+		nonceStore:       ns,
+		config:           config,
+	}
+}
+
+type signatureApp struct {
+	apiKeyRepository repository.IAPIKeyRepository
+	nonceStore       NonceStore
+	config           SignatureConfig
+}
+
+func (s *signatureApp) Authenticate(DB *sql.DB, Request SignedRequest) (model.Principal, error) {
+	if Request.KeyID == "" || Request.Signature == "" || Request.Nonce == "" || len(Request.Nonce) > maxNonceLength {
+		return model.Principal{}, fmt.Errorf("invalid signature")
+	}
+	seconds, err := strconv.ParseInt(Request.Timestamp, 10, 64)
+	if err != nil {
+		return model.Principal{}, fmt.Errorf("invalid signature")
+	}
+	now := time.Now()
+	timestamp := time.Unix(seconds, 0)
+	if timestamp.Before(now.Add(-s.config.MaxSkew)) || timestamp.After(now.Add(s.config.MaxSkew)) {
+		return model.Principal{}, fmt.Errorf("timestamp out of range")
+	}
+
+	key, err := s.apiKeyRepository.GetByID(DB, Request.KeyID)
+	if err != nil {
+		if err.Error() == "api key not found" {
+			return model.Principal{}, fmt.Errorf("invalid api key")
+		}
+		return model.Principal{}, fmt.Errorf("failed to get api key %s: %w", Request.KeyID, err)
+	}
+	if !key.Signing {
+		return model.Principal{}, fmt.Errorf("invalid api key")
+	}
+
+	query, err := signer.CanonicalQuery(Request.RawQuery)
+	if err != nil {
+		return model.Principal{}, fmt.Errorf("invalid signature")
+	}
+	stringToSign := signer.StringToSign(Request.Method, Request.Path, query, signer.HashBody(Request.Body), Request.Timestamp, Request.Nonce)
+	expected := signer.Signature([]byte(key.Secret), stringToSign)
+	if !hmac.Equal([]byte(expected), []byte(Request.Signature)) {
+		return model.Principal{}, fmt.Errorf("invalid signature")
+	}
+
+	// Only a correctly signed request claims its nonce, so that nobody
+	// without the secret can use up nonces. A request is accepted until its
+	// timestamp falls out of the window, which is how long the nonce must be
+	// remembered.
+	fresh, err := s.nonceStore.Claim(key.ID+"|"+Request.Nonce, timestamp.Add(s.config.MaxSkew), now)
+	if err != nil {
+		return model.Principal{}, fmt.Errorf("failed to record nonce: %w", err)
+	}
+	if !fresh {
+		return model.Principal{}, fmt.Errorf("nonce already used")
+	}
+	return usableKey(DB, s.apiKeyRepository, key)
+}
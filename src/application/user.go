@@ -3,36 +3,111 @@ package application
 import (
     "database/sql"
     "fmt"
+    "net/mail"
+    "strings"
+    "time"
     "repo-api/src/domain/repository"
     "repo-api/src/domain/model"
 )
 
+type UserConfig struct {
+    // IDAliasTTL is how long the old ID of a renamed user keeps redirecting.
+    IDAliasTTL time.Duration
+}
+
+// UserApp confines Caller to the users of its organization, and registers
+// new users there.
 type UserApp interface {
-    Register(DB *sql.DB, ID, Name string) error
-    Get(DB *sql.DB, ID string) (model.User, error)
-    Update(DB *sql.DB, ID, Name string) error
+    // Register adds user, with their profile, to the organization of Caller.
+    Register(DB *sql.DB, Caller model.Principal, user model.User) error
+    Get(DB *sql.DB, Caller model.Principal, ID string) (model.User, error)
+    Update(DB *sql.DB, Caller model.Principal, ID, Name string) error
+    // UpdateProfile applies Profile to user ID and returns the result.
+    UpdateProfile(DB *sql.DB, Caller model.Principal, ID string, Profile model.UserProfile) (model.User, error)
+    // List returns every user of the organization, for administrators.
+    List(DB *sql.DB, Caller model.Principal) ([]model.User, error)
+    // Search returns a page of the users of the organization. Report counts
+    // include only the reports Caller can read.
+    Search(DB *sql.DB, Caller model.Principal, Query model.UserQuery) (model.UserPage, error)
+    // SetStatus moves a user to Status, recording Reason in the audit log.
+    // Deactivated users have to be reactivated before they can be suspended,
+    // and administrators cannot change their own status.
+    SetStatus(DB *sql.DB, Caller model.Principal, ID, Status, Reason string) (model.UserStatusChange, error)
+    // Delete removes a user, and deletes or reassigns their reports as
+    // Deletion.Policy says. The block policy refuses while the user has
+    // reports.
+    Delete(DB *sql.DB, Caller model.Principal, ID string, Deletion model.UserDeletion) (model.UserDeletion, error)
+    // Rename changes the ID of a user, and of the author of their reports,
+    // at once. The old ID redirects to the new one for a while.
+    Rename(DB *sql.DB, Caller model.Principal, ID, NewID string) (model.UserRename, error)
+    // MovedTo returns the ID that user ID was renamed to, or "user not
+    // found" once the alias has expired.
+    MovedTo(DB *sql.DB, Caller model.Principal, ID string) (string, error)
 }
 
-func NewUserApp(ur repository.IUserRepository) UserApp {
+func NewUserApp(ur repository.IUserRepository, ep EventPublisher, config UserConfig) UserApp {
     return &userApp{
         userRepository: ur,
+        eventPublisher: ep,
+        config: config,
     }
 }
 
 type userApp struct {
     userRepository repository.IUserRepository
+    eventPublisher EventPublisher
+    config UserConfig
 }
 
-func (u *userApp) Register(DB *sql.DB, ID, Name string) error {
-    err := u.userRepository.Insert(DB, ID, Name)
+func (u *userApp) Register(DB *sql.DB, Caller model.Principal, user model.User) error {
+    if !isAdmin(Caller) {
+        return fmt.Errorf("forbidden")
+    }
+    if err := validateProfile(user); err != nil {
+        return err
+    }
+    // The user is inserted with its status, so that it is never active
+    // when it should not be. Empty means active.
+    if user.Status != "" && !validUserStatus(user.Status) {
+        return fmt.Errorf("invalid status")
+    }
+
+    user.OrgID = Caller.OrgID
+    err := u.userRepository.Insert(DB, user)
     if err != nil {
+        // IDs are unique across organizations; say no more about who
+        // has the ID.
+        if strings.HasSuffix(err.Error(), "already exists") {
+            return fmt.Errorf("user id unavailable")
+        }
         return fmt.Errorf("failed to insert user: %w", err)
     }
     return nil
 }
 
-func (u *userApp) Get(DB *sql.DB, ID string) (model.User, error) {
-    user, err := u.userRepository.GetByID(DB, ID)
+// validateProfile fails with "invalid email", "invalid time zone" or
+// "invalid default count". Empty fields are valid.
+func validateProfile(user model.User) error {
+    if user.Email != "" {
+        address, err := mail.ParseAddress(user.Email)
+        if err != nil || address.Address != user.Email {
+            return fmt.Errorf("invalid email")
+        }
+    }
+    if user.TimeZone != "" {
+        // LoadLocation takes "" and "Local" too, which are not zones.
+        if _, err := time.LoadLocation(user.TimeZone); err != nil || user.TimeZone == "Local" {
+            return fmt.Errorf("invalid time zone")
+        }
+    }
+    if user.DefaultCount < 0 {
+        return fmt.Errorf("invalid default count")
+    }
+    return nil
+}
+
+func (u *userApp) Get(DB *sql.DB, Caller model.Principal, ID string) (model.User, error) {
+    user, err := getTenantUser(DB, u.userRepository, Caller.OrgID, ID)
     if err != nil {
         if err == sql.ErrNoRows {
             return model.User{}, fmt.Errorf("user not found: %w", err)
@@ -42,7 +117,17 @@ func (u *userApp) Get(DB *sql.DB, ID string) (model.User, error) {
     return user, nil
 }
 
-func (u *userApp) Update(DB *sql.DB, ID, Name string) error {
+func (u *userApp) Update(DB *sql.DB, Caller model.Principal, ID, Name string) error {
+    if !CanModifyUser(Caller, ID) {
+        return fmt.Errorf("forbidden")
+    }
+    if _, err := getTenantUser(DB, u.userRepository, Caller.OrgID, ID); err != nil {
+        if err == sql.ErrNoRows {
+            return fmt.Errorf("user not found")
+        }
+        return fmt.Errorf("failed to get user by ID: %w", err)
+    }
+
     err := u.userRepository.UpdateNameByID(DB, ID, Name)
     if err != nil {
         if err.Error() == "user not found" {
@@ -50,5 +135,267 @@ func (u *userApp) Update(DB *sql.DB, ID, Name string) error {
         }
         return fmt.Errorf("failed to update user name by ID: %w", err)
     }
+
+    u.eventPublisher.Publish(model.Event{Type: model.EventUserUpdated, AuthorID: ID, OrgID: Caller.OrgID, Data: model.User{ID: ID, Name: Name}})
     return nil
 }
+
+func (u *userApp) UpdateProfile(DB *sql.DB, Caller model.Principal, ID string, Profile model.UserProfile) (model.User, error) {
+    if !CanModifyUser(Caller, ID) {
+        return model.User{}, fmt.Errorf("forbidden")
+    }
+    user, err := getTenantUser(DB, u.userRepository, Caller.OrgID, ID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return model.User{}, fmt.Errorf("user not found")
+        }
+        return model.User{}, fmt.Errorf("failed to get user by ID: %w", err)
+    }
+
+    for _, field := range []struct {
+        value  *string
+        target *string
+    }{
+        {Profile.Name, &user.Name},
+        {Profile.Email, &user.Email},
+        {Profile.DisplayName, &user.DisplayName},
+        {Profile.Affiliation, &user.Affiliation},
+        {Profile.TimeZone, &user.TimeZone},
+        {Profile.DefaultStyle, &user.DefaultStyle},
+        {Profile.DefaultLanguage, &user.DefaultLanguage},
+    } {
+        if field.value != nil {
+            *field.target = *field.value
+        }
+    }
+    if Profile.DefaultCount != nil {
+        user.DefaultCount = *Profile.DefaultCount
+    }
+    if user.Name == "" {
+        return model.User{}, fmt.Errorf("name required")
+    }
+    if err := validateProfile(user); err != nil {
+        return model.User{}, err
+    }
+
+    if err := u.userRepository.UpdateProfile(DB, user); err != nil {
+        if err.Error() == "user not found" {
+            return model.User{}, err
+        }
+        return model.User{}, fmt.Errorf("failed to update profile of user %s: %w", ID, err)
+    }
+
+    u.eventPublisher.Publish(model.Event{Type: model.EventUserUpdated, AuthorID: ID, OrgID: Caller.OrgID, Data: user})
+    return user, nil
+}
+
+func (u *userApp) List(DB *sql.DB, Caller model.Principal) ([]model.User, error) {
+    if !isAdmin(Caller) {
+        return nil, fmt.Errorf("forbidden")
+    }
+    users, err := u.userRepository.GetByOrgID(DB, Caller.OrgID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get users of organization %s: %w", Caller.OrgID, err)
+    }
+    return users, nil
+}
+
+func (u *userApp) Search(DB *sql.DB, Caller model.Principal, Query model.UserQuery) (model.UserPage, error) {
+    if Query.Match == "" {
+        Query.Match = model.MatchPrefix
+    }
+    if Query.Match != model.MatchPrefix && Query.Match != model.MatchContains {
+        return model.UserPage{}, fmt.Errorf("invalid match")
+    }
+    if Query.Sort == "" {
+        Query.Sort = model.UserSortID
+    }
+    switch Query.Sort {
+    case model.UserSortID, model.UserSortName, model.UserSortUpdatedAt, model.UserSortReportCount:
+    default:
+        return model.UserPage{}, fmt.Errorf("invalid sort")
+    }
+    if Query.Status != "" && !validUserStatus(Query.Status) {
+        return model.UserPage{}, fmt.Errorf("invalid status")
+    }
+    Query.ReportsVisibleTo = ""
+    if !isAdmin(Caller) && !HasScope(Caller.Scopes, model.ScopeReportsReadAll) {
+        Query.ReportsVisibleTo = Caller.UserID
+    }
+
+    users, total, err := u.userRepository.Search(DB, Caller.OrgID, Query)
+    if err != nil {
+        return model.UserPage{}, fmt.Errorf("failed to search users of organization %s: %w", Caller.OrgID, err)
+    }
+    page := model.UserPage{Users: users, Total: total}
+    if next := Query.Offset + len(users); len(users) > 0 && next < total {
+        page.NextOffset = &next
+    }
+    return page, nil
+}
+
+func validUserStatus(Status string) bool {
+    return Status == model.UserActive || Status == model.UserSuspended || Status == model.UserDeactivated
+}
+
+func (u *userApp) SetStatus(DB *sql.DB, Caller model.Principal, ID, Status, Reason string) (model.UserStatusChange, error) {
+    if !isAdmin(Caller) {
+        return model.UserStatusChange{}, fmt.Errorf("forbidden")
+    }
+    if !validUserStatus(Status) {
+        return model.UserStatusChange{}, fmt.Errorf("invalid status")
+    }
+    user, err := getTenantUser(DB, u.userRepository, Caller.OrgID, ID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return model.UserStatusChange{}, fmt.Errorf("user not found")
+        }
+        return model.UserStatusChange{}, fmt.Errorf("failed to get user by ID: %w", err)
+    }
+    change := model.UserStatusChange{UserID: ID, From: user.Status, To: Status, Reason: Reason}
+    if user.Status == Status {
+        return change, nil
+    }
+    if ID == Caller.UserID {
+        return model.UserStatusChange{}, fmt.Errorf("cannot change own status")
+    }
+    if user.Status == model.UserDeactivated && Status == model.UserSuspended {
+        return model.UserStatusChange{}, fmt.Errorf("invalid status transition")
+    }
+
+    audit := model.AuditEntry{
+        OrgID:     Caller.OrgID,
+        ActorID:   Caller.UserID,
+        Action:    model.AuditUserStatusChanged,
+        TargetID:  ID,
+        CreatedAt: time.Now().UTC(),
+    }
+    change, err = u.userRepository.UpdateStatus(DB, change, audit)
+    if err != nil {
+        if err.Error() == "user not found" {
+            return model.UserStatusChange{}, err
+        }
+        return model.UserStatusChange{}, fmt.Errorf("failed to update status of user %s: %w", ID, err)
+    }
+    user.Status = Status
+    u.eventPublisher.Publish(model.Event{Type: model.EventUserUpdated, AuthorID: ID, OrgID: Caller.OrgID, Data: user})
+    return change, nil
+}
+
+func (u *userApp) Delete(DB *sql.DB, Caller model.Principal, ID string, Deletion model.UserDeletion) (model.UserDeletion, error) {
+    if !isAdmin(Caller) {
+        return model.UserDeletion{}, fmt.Errorf("forbidden")
+    }
+    switch Deletion.Policy {
+    case model.DeleteBlock, model.DeleteCascade:
+        Deletion.ReassignTo = ""
+    case model.DeleteReassign:
+    default:
+        return model.UserDeletion{}, fmt.Errorf("invalid policy")
+    }
+    if ID == Caller.UserID {
+        return model.UserDeletion{}, fmt.Errorf("cannot delete self")
+    }
+    if _, err := getTenantUser(DB, u.userRepository, Caller.OrgID, ID); err != nil {
+        if err == sql.ErrNoRows {
+            return model.UserDeletion{}, fmt.Errorf("user not found")
+        }
+        return model.UserDeletion{}, fmt.Errorf("failed to get user by ID: %w", err)
+    }
+    if Deletion.Policy == model.DeleteReassign {
+        if Deletion.ReassignTo == ID {
+            return model.UserDeletion{}, fmt.Errorf("invalid reassign target")
+        }
+        target, err := getTenantUser(DB, u.userRepository, Caller.OrgID, Deletion.ReassignTo)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                return model.UserDeletion{}, fmt.Errorf("reassign target not found")
+            }
+            return model.UserDeletion{}, fmt.Errorf("failed to get user by ID: %w", err)
+        }
+        if target.Status != model.UserActive {
+            return model.UserDeletion{}, fmt.Errorf("invalid reassign target")
+        }
+    }
+
+    audit := model.AuditEntry{
+        OrgID:     Caller.OrgID,
+        ActorID:   Caller.UserID,
+        Action:    model.AuditUserDeleted,
+        TargetID:  ID,
+        CreatedAt: time.Now().UTC(),
+    }
+    reports, err := u.userRepository.Delete(DB, ID, Deletion, audit)
+    if err != nil {
+        switch err.Error() {
+        case "user not found", "user has reports", "reassign target not found":
+            return model.UserDeletion{}, err
+        }
+        return model.UserDeletion{}, fmt.Errorf("failed to delete user %s: %w", ID, err)
+    }
+
+    for _, report := range reports {
+        if Deletion.Policy == model.DeleteCascade {
+            u.eventPublisher.Publish(model.Event{Type: model.EventReportEjected, AuthorID: report.AuthorID, OrgID: report.OrgID, Data: report})
+            continue
+        }
+        report.AuthorID = Deletion.ReassignTo
+        u.eventPublisher.Publish(model.Event{Type: model.EventReportUpdated, AuthorID: report.AuthorID, OrgID: report.OrgID, Data: report})
+    }
+    Deletion.Reports = len(reports)
+    return Deletion, nil
+}
+
+func (u *userApp) Rename(DB *sql.DB, Caller model.Principal, ID, NewID string) (model.UserRename, error) {
+    if !isAdmin(Caller) {
+        return model.UserRename{}, fmt.Errorf("forbidden")
+    }
+    if NewID == "" || NewID == ID {
+        return model.UserRename{}, fmt.Errorf("invalid user id")
+    }
+    user, err := getTenantUser(DB, u.userRepository, Caller.OrgID, ID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return model.UserRename{}, fmt.Errorf("user not found")
+        }
+        return model.UserRename{}, fmt.Errorf("failed to get user by ID: %w", err)
+    }
+
+    now := time.Now().UTC()
+    rename := model.UserRename{OldID: ID, NewID: NewID, AliasExpiresAt: now.Add(u.config.IDAliasTTL)}
+    audit := model.AuditEntry{
+        OrgID:     Caller.OrgID,
+        ActorID:   Caller.UserID,
+        Action:    model.AuditUserRenamed,
+        TargetID:  NewID,
+        CreatedAt: now,
+    }
+    if err := u.userRepository.Rename(DB, rename, audit); err != nil {
+        if err.Error() == "user not found" || err.Error() == "user id taken" {
+            return model.UserRename{}, err
+        }
+        return model.UserRename{}, fmt.Errorf("failed to rename user %s to %s: %w", ID, NewID, err)
+    }
+
+    user.ID = NewID
+    u.eventPublisher.Publish(model.Event{Type: model.EventUserUpdated, AuthorID: NewID, OrgID: Caller.OrgID, Data: user})
+    return rename, nil
+}
+
+func (u *userApp) MovedTo(DB *sql.DB, Caller model.Principal, ID string) (string, error) {
+    NewID, err := u.userRepository.GetAlias(DB, ID, time.Now().UTC())
+    if err != nil {
+        if err.Error() == "alias not found" {
+            return "", fmt.Errorf("user not found")
+        }
+        return "", fmt.Errorf("failed to get alias %s: %w", ID, err)
+    }
+    // The alias is as visible as the user it leads to.
+    if _, err := getTenantUser(DB, u.userRepository, Caller.OrgID, NewID); err != nil {
+        if err == sql.ErrNoRows {
+            return "", fmt.Errorf("user not found")
+        }
+        return "", fmt.Errorf("failed to get user by ID: %w", err)
+    }
+    return NewID, nil
+}
@@ -0,0 +1,475 @@
+package application
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+// WebhookEvents lists the event types a webhook can subscribe to. "*"
+// subscribes to all of them.
+var WebhookEvents = []string{
+	model.EventReportCreated,
+	model.EventReportUpdated,
+	model.EventReportEjected,
+	model.EventUserUpdated,
+}
+
+type WebhookConfig struct {
+	// Timeout bounds each attempt, from connecting to reading the response.
+	Timeout        time.Duration
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// PollInterval is how often Run looks for deliveries whose next attempt
+	// is due. New deliveries are attempted right away.
+	PollInterval time.Duration
+	// Workers bounds how many deliveries are attempted at once. Deliveries
+	// beyond that stay due until a worker is free.
+	Workers int
+	// AllowPrivateAddresses lets webhooks post to loopback, private and
+	// link-local addresses, which are refused otherwise so that webhooks
+	// cannot reach services inside the network. For tests and local
+	// development only.
+	AllowPrivateAddresses bool
+}
+
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Timeout:        10 * time.Second,
+		MaxAttempts:    6,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Minute,
+		PollInterval:   time.Second,
+		Workers:        16,
+	}
+}
+
+// deliveryBatch is how many due deliveries are claimed at a time.
+const deliveryBatch = 100
+
+// WebhookApp manages the webhooks of organization OrgID; webhooks of other
+// organizations are reported as not found.
+type WebhookApp interface {
+	Register(DB *sql.DB, OrgID, URL string, Events []string, Secret string) (model.Webhook, error)
+	Get(DB *sql.DB, OrgID, ID string) (model.Webhook, error)
+	List(DB *sql.DB, OrgID string) ([]model.Webhook, error)
+	Delete(DB *sql.DB, OrgID, ID string) error
+	Deliveries(DB *sql.DB, OrgID, WebhookID string) ([]model.WebhookDelivery, error)
+	Redeliver(DB *sql.DB, OrgID, DeliveryID string) (model.WebhookDelivery, error)
+
+	// Run delivers events from es to the subscribed webhooks of the
+	// organization the event happened in until the process exits. It also
+	// makes the attempts that are due, including those of deliveries an
+	// earlier process left pending.
+	Run(DB *sql.DB, es EventSubscriber)
+}
+
+func NewWebhookApp(wr repository.IWebhookRepository, config WebhookConfig) WebhookApp {
+	return &webhookApp{
+		webhookRepository: wr,
+		config:            config,
+		client:            newWebhookClient(config),
+		wake:              make(chan struct{}, 1),
+		workers:           make(chan struct{}, max(config.Workers, 1)),
+	}
+}
+
+type webhookApp struct {
+	webhookRepository repository.IWebhookRepository
+	config            WebhookConfig
+	client            *http.Client
+	// wake tells the worker that a delivery was created.
+	wake chan struct{}
+	// workers holds a token for each attempt in progress.
+	workers chan struct{}
+}
+
+// newWebhookClient returns the client deliveries are sent with. Unless
+// private addresses are allowed, it refuses to connect to them. Checking the
+// address connected to rather than the URL also covers host names resolving
+// to such addresses, and redirects.
+func newWebhookClient(config WebhookConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateAddresses {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addrPort.Addr()) {
+				return fmt.Errorf("webhook destination %s is not a public address", addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, past the check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: config.Timeout, Transport: transport}
+}
+
+// publicAddr reports whether webhooks may post to addr.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// allowedWebhookHost reports whether a webhook URL may name host. Host names
+// other than localhost are checked when connecting, as they may resolve to
+// other addresses by then.
+func (w *webhookApp) allowedWebhookHost(host string) bool {
+	if w.config.AllowPrivateAddresses {
+		return true
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
+
+// SignWebhookPayload returns the value of the X-Webhook-Signature header: an
+// HMAC-SHA256 over the timestamp and the body, joined by a dot.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhookApp) Register(DB *sql.DB, OrgID, URL string, Events []string, Secret string) (model.Webhook, error) {
+	parsed, err := url.Parse(URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return model.Webhook{}, fmt.Errorf("invalid webhook url")
+	}
+	if !w.allowedWebhookHost(strings.ToLower(parsed.Hostname())) {
+		return model.Webhook{}, fmt.Errorf("webhook url not allowed")
+	}
+	if len(Events) == 0 {
+		return model.Webhook{}, fmt.Errorf("invalid event type")
+	}
+	for _, event := range Events {
+		if event != "*" && !slices.Contains(WebhookEvents, event) {
+			return model.Webhook{}, fmt.Errorf("invalid event type")
+		}
+	}
+
+	if Secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return model.Webhook{}, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		Secret = hex.EncodeToString(buf)
+	}
+
+	webhook := model.Webhook{
+		ID:        uuid.New().String(),
+		OrgID:     OrgID,
+		URL:       URL,
+		Events:    Events,
+		Secret:    Secret,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := w.webhookRepository.Insert(DB, webhook); err != nil {
+		return model.Webhook{}, fmt.Errorf("failed to insert webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (w *webhookApp) Get(DB *sql.DB, OrgID, ID string) (model.Webhook, error) {
+	webhook, err := w.getWebhook(DB, OrgID, ID)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (w *webhookApp) getWebhook(DB *sql.DB, OrgID, ID string) (model.Webhook, error) {
+	webhook, err := w.webhookRepository.GetByID(DB, OrgID, ID)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			return model.Webhook{}, err
+		}
+		return model.Webhook{}, fmt.Errorf("failed to get webhook by ID %s: %w", ID, err)
+	}
+	if webhook.OrgID != OrgID {
+		return model.Webhook{}, fmt.Errorf("webhook not found")
+	}
+	return webhook, nil
+}
+
+func (w *webhookApp) List(DB *sql.DB, OrgID string) ([]model.Webhook, error) {
+	webhooks, err := w.webhookRepository.GetByOrgID(DB, OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (w *webhookApp) Delete(DB *sql.DB, OrgID, ID string) error {
+	if _, err := w.getWebhook(DB, OrgID, ID); err != nil {
+		return err
+	}
+	err := w.webhookRepository.Delete(DB, ID)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			return err
+		}
+		return fmt.Errorf("failed to delete webhook with ID %s: %w", ID, err)
+	}
+	return nil
+}
+
+func (w *webhookApp) Deliveries(DB *sql.DB, OrgID, WebhookID string) ([]model.WebhookDelivery, error) {
+	if _, err := w.getWebhook(DB, OrgID, WebhookID); err != nil {
+		return nil, err
+	}
+	deliveries, err := w.webhookRepository.GetDeliveriesByWebhookID(DB, WebhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries for webhook %s: %w", WebhookID, err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver sends the payload of an earlier delivery again as a new delivery,
+// keeping the original entry in the log untouched.
+func (w *webhookApp) Redeliver(DB *sql.DB, OrgID, DeliveryID string) (model.WebhookDelivery, error) {
+	original, err := w.webhookRepository.GetDeliveryByID(DB, DeliveryID)
+	if err != nil {
+		if err.Error() == "delivery not found" {
+			return model.WebhookDelivery{}, err
+		}
+		return model.WebhookDelivery{}, fmt.Errorf("failed to get delivery %s: %w", DeliveryID, err)
+	}
+	webhook, err := w.getWebhook(DB, OrgID, original.WebhookID)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			// Deliveries of other organizations' webhooks do not exist
+			// as far as the caller is concerned.
+			return model.WebhookDelivery{}, fmt.Errorf("delivery not found")
+		}
+		return model.WebhookDelivery{}, err
+	}
+
+	delivery, err := w.createDelivery(DB, webhook, original.EventID, original.EventType, original.Payload)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	w.notify()
+	return delivery, nil
+}
+
+func (w *webhookApp) Run(DB *sql.DB, es EventSubscriber) {
+	go w.work(DB)
+
+	var lastID uint64
+	for {
+		replay, _, events, cancel := es.Subscribe(lastID)
+		for _, event := range replay {
+			w.dispatch(DB, event)
+			lastID = event.ID
+		}
+		for event := range events {
+			w.dispatch(DB, event)
+			lastID = event.ID
+		}
+		// The broker dropped us for falling behind; resubscribe and pick up
+		// the missed events from its replay buffer.
+		cancel()
+	}
+}
+
+func (w *webhookApp) dispatch(DB *sql.DB, event model.Event) {
+	if !slices.Contains(WebhookEvents, event.Type) {
+		return
+	}
+
+	webhooks, err := w.webhookRepository.GetByOrgID(DB, event.OrgID)
+	if err != nil {
+		log.Printf("Error loading webhooks for event %d: %v", event.ID, err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding event %d: %v", event.ID, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.Events, "*") && !slices.Contains(webhook.Events, event.Type) {
+			continue
+		}
+		if _, err := w.createDelivery(DB, webhook, event.ID, event.Type, string(payload)); err != nil {
+			log.Printf("Error creating delivery of event %d to webhook %s: %v", event.ID, webhook.ID, err)
+		}
+	}
+	w.notify()
+}
+
+func (w *webhookApp) createDelivery(DB *sql.DB, webhook model.Webhook, EventID uint64, EventType, Payload string) (model.WebhookDelivery, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery := model.WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: webhook.ID,
+		EventID:   EventID,
+		EventType: EventType,
+		Payload:   Payload,
+		Status:    model.DeliveryPending,
+		// Due right away.
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := w.webhookRepository.InsertDelivery(DB, delivery); err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("failed to insert delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// notify wakes the worker up to attempt new deliveries.
+func (w *webhookApp) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work attempts the deliveries that are due every PollInterval and whenever
+// deliveries are created. Being persisted, deliveries survive restarts: the
+// next attempt of a delivery is made whenever it is due.
+func (w *webhookApp) work(DB *sql.DB) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		w.attemptDue(DB)
+		select {
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// attemptDue claims only as many deliveries as there are free workers, so
+// that no claim expires waiting for one. While every worker is busy it
+// waits for one to finish.
+func (w *webhookApp) attemptDue(DB *sql.DB) {
+	for {
+		w.workers <- struct{}{}
+		// Only this loop takes workers, so those free now stay free.
+		limit := min(cap(w.workers)-len(w.workers)+1, deliveryBatch)
+
+		// A claim outlasts the attempt, so that the delivery is only
+		// attempted again if this process stopped before recording it.
+		now := time.Now().UTC()
+		due, err := w.webhookRepository.ClaimDueDeliveries(DB, now, now.Add(w.config.Timeout+time.Minute), limit)
+		if err != nil || len(due) == 0 {
+			<-w.workers
+			if err != nil {
+				log.Printf("Error claiming webhook deliveries: %v", err)
+			}
+			return
+		}
+		for i, d := range due {
+			if i > 0 {
+				w.workers <- struct{}{}
+			}
+			go func() {
+				defer func() { <-w.workers }()
+				w.attempt(DB, d.Webhook, d.Delivery)
+			}()
+		}
+		if len(due) < limit {
+			return
+		}
+	}
+}
+
+// attempt posts the payload once and records the outcome. Unless the
+// receiver answered with a 2xx status or the attempts ran out, the next
+// attempt is due after twice the previous wait.
+func (w *webhookApp) attempt(DB *sql.DB, webhook model.Webhook, delivery model.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.ResponseCode, delivery.Error = w.send(webhook, delivery)
+	now := time.Now().UTC()
+	delivery.UpdatedAt = now.Truncate(time.Second)
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case delivery.Error == "":
+		delivery.Status = model.DeliverySucceeded
+	case delivery.Attempts >= w.config.MaxAttempts:
+		delivery.Status = model.DeliveryFailed
+	default:
+		delivery.Status = model.DeliveryRetrying
+		next := now.Add(w.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	if err := w.webhookRepository.UpdateDelivery(DB, delivery); err != nil {
+		log.Printf("Error updating delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (w *webhookApp) backoff(attempts int) time.Duration {
+	backoff := w.config.InitialBackoff
+	for i := 1; i < attempts && backoff < w.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, w.config.MaxBackoff)
+}
+
+func (w *webhookApp) send(webhook model.Webhook, delivery model.WebhookDelivery) (int, string) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "repoapi-webhooks")
+	req.Header.Set("X-Webhook-ID", webhook.ID)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, ""
+}
@@ -0,0 +1,346 @@
+package application_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+	"repo-api/src/infra/event"
+	"repo-api/src/infra/memory"
+)
+
+// testReceiver is a webhook receiver answering with the given statuses in
+// turn, and with 200 once they run out.
+type testReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestReceiver(t *testing.T, statuses ...int) *testReceiver {
+	r := &testReceiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, receivedWebhook{req.Header.Clone(), body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *testReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+type webhookFixture struct {
+	t        *testing.T
+	webhooks repository.IWebhookRepository
+	app      application.WebhookApp
+	broker   *event.Broker
+}
+
+// testWebhookConfig retries quickly, and lets webhooks post to the test
+// receivers on the loopback address.
+func testWebhookConfig() application.WebhookConfig {
+	return application.WebhookConfig{
+		Timeout:               5 * time.Second,
+		MaxAttempts:           3,
+		InitialBackoff:        10 * time.Millisecond,
+		MaxBackoff:            20 * time.Millisecond,
+		PollInterval:          5 * time.Millisecond,
+		Workers:               4,
+		AllowPrivateAddresses: true,
+	}
+}
+
+func newWebhookFixture(t *testing.T, config application.WebhookConfig) *webhookFixture {
+	webhooks := memory.NewWebhookRepository(memory.NewStore())
+	return &webhookFixture{
+		t:        t,
+		webhooks: webhooks,
+		app:      application.NewWebhookApp(webhooks, config),
+		broker:   event.NewBroker(16),
+	}
+}
+
+// run starts delivering, for the rest of the test binary, and returns once
+// events published from then on are delivered.
+func (f *webhookFixture) run() {
+	subscribed := make(chan struct{})
+	go f.app.Run(nil, &notifyingSubscriber{Broker: f.broker, subscribed: subscribed})
+	<-subscribed
+}
+
+// notifyingSubscriber closes subscribed once first subscribed to.
+type notifyingSubscriber struct {
+	*event.Broker
+	subscribed chan struct{}
+	once       sync.Once
+}
+
+func (s *notifyingSubscriber) Subscribe(lastEventID uint64) ([]model.Event, bool, <-chan model.Event, func()) {
+	defer s.once.Do(func() { close(s.subscribed) })
+	return s.Broker.Subscribe(lastEventID)
+}
+
+func (f *webhookFixture) register(URL string) model.Webhook {
+	f.t.Helper()
+	webhook, err := f.app.Register(nil, "default", URL, []string{model.EventReportCreated}, "secret")
+	require.NoError(f.t, err)
+	return webhook
+}
+
+// settled waits for the only delivery of webhook to succeed or fail for
+// good, and returns it.
+func (f *webhookFixture) settled(webhook model.Webhook) model.WebhookDelivery {
+	f.t.Helper()
+	var delivery model.WebhookDelivery
+	require.Eventually(f.t, func() bool {
+		deliveries, err := f.app.Deliveries(nil, "default", webhook.ID)
+		require.NoError(f.t, err)
+		if len(deliveries) != 1 {
+			return false
+		}
+		delivery = deliveries[0]
+		return delivery.Status == model.DeliverySucceeded || delivery.Status == model.DeliveryFailed
+	}, 5*time.Second, 5*time.Millisecond)
+	return delivery
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	receiver := newTestReceiver(t)
+	f := newWebhookFixture(t, testWebhookConfig())
+	webhook := f.register(receiver.URL)
+	f.run()
+	f.broker.Publish(model.Event{ID: 1, Type: model.EventReportCreated, OrgID: "default", Time: time.Now()})
+	// Events of other organizations and other types are not delivered.
+	f.broker.Publish(model.Event{ID: 2, Type: model.EventReportCreated, OrgID: "other", Time: time.Now()})
+	f.broker.Publish(model.Event{ID: 3, Type: model.EventReportUpdated, OrgID: "default", Time: time.Now()})
+
+	delivery := f.settled(webhook)
+	assert.Equal(t, model.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, delivery.ResponseCode)
+	assert.Nil(t, delivery.NextAttemptAt)
+
+	requests := receiver.received()
+	require.Len(t, requests, 1)
+	header := requests[0].header
+	assert.Equal(t, webhook.ID, header.Get("X-Webhook-ID"))
+	assert.Equal(t, delivery.ID, header.Get("X-Webhook-Delivery"))
+	assert.Equal(t, model.EventReportCreated, header.Get("X-Webhook-Event"))
+	timestamp, err := strconv.ParseInt(header.Get("X-Webhook-Timestamp"), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, application.SignWebhookPayload("secret", timestamp, requests[0].body), header.Get("X-Webhook-Signature"))
+	assert.JSONEq(t, delivery.Payload, string(requests[0].body))
+}
+
+func TestWebhookRetries(t *testing.T) {
+	t.Run("until answered", func(t *testing.T) {
+		receiver := newTestReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+		f := newWebhookFixture(t, testWebhookConfig())
+		webhook := f.register(receiver.URL)
+		f.run()
+		f.broker.Publish(model.Event{ID: 1, Type: model.EventReportCreated, OrgID: "default", Time: time.Now()})
+
+		delivery := f.settled(webhook)
+		assert.Equal(t, model.DeliverySucceeded, delivery.Status)
+		assert.Equal(t, 3, delivery.Attempts)
+		assert.Len(t, receiver.received(), 3)
+	})
+
+	t.Run("until the attempts run out", func(t *testing.T) {
+		receiver := newTestReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+		f := newWebhookFixture(t, testWebhookConfig())
+		webhook := f.register(receiver.URL)
+		f.run()
+		f.broker.Publish(model.Event{ID: 1, Type: model.EventReportCreated, OrgID: "default", Time: time.Now()})
+
+		delivery := f.settled(webhook)
+		assert.Equal(t, model.DeliveryFailed, delivery.Status)
+		assert.Equal(t, 3, delivery.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, delivery.ResponseCode)
+		assert.Nil(t, delivery.NextAttemptAt)
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, receiver.received(), 3)
+	})
+
+	t.Run("waiting between attempts", func(t *testing.T) {
+		receiver := newTestReceiver(t, http.StatusInternalServerError)
+		config := testWebhookConfig()
+		config.InitialBackoff = time.Hour
+		config.MaxBackoff = time.Hour
+		f := newWebhookFixture(t, config)
+		webhook := f.register(receiver.URL)
+		f.run()
+		f.broker.Publish(model.Event{ID: 1, Type: model.EventReportCreated, OrgID: "default", Time: time.Now()})
+
+		var delivery model.WebhookDelivery
+		require.Eventually(t, func() bool {
+			deliveries, err := f.app.Deliveries(nil, "default", webhook.ID)
+			require.NoError(t, err)
+			if len(deliveries) != 1 {
+				return false
+			}
+			delivery = deliveries[0]
+			return delivery.Status == model.DeliveryRetrying
+		}, 5*time.Second, 5*time.Millisecond)
+		require.NotNil(t, delivery.NextAttemptAt)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *delivery.NextAttemptAt, time.Minute)
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, receiver.received(), 1)
+	})
+}
+
+// TestWebhookResumesDeliveries checks that deliveries left pending by an
+// earlier process are attempted once they are due.
+func TestWebhookResumesDeliveries(t *testing.T) {
+	receiver := newTestReceiver(t)
+	f := newWebhookFixture(t, testWebhookConfig())
+	webhook := f.register(receiver.URL)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	due, later := now.Add(-time.Minute), now.Add(time.Hour)
+	for _, delivery := range []model.WebhookDelivery{
+		{ID: "pending", Status: model.DeliveryPending, NextAttemptAt: &due},
+		{ID: "retrying", Status: model.DeliveryRetrying, Attempts: 1, ResponseCode: http.StatusBadGateway, NextAttemptAt: &due},
+		{ID: "later", Status: model.DeliveryRetrying, Attempts: 1, ResponseCode: http.StatusBadGateway, NextAttemptAt: &later},
+		{ID: "failed", Status: model.DeliveryFailed, Attempts: 3},
+	} {
+		delivery.WebhookID = webhook.ID
+		delivery.EventType = model.EventReportCreated
+		delivery.Payload = `{"id":1}`
+		delivery.CreatedAt, delivery.UpdatedAt = now, now
+		require.NoError(t, f.webhooks.InsertDelivery(nil, delivery))
+	}
+	f.run()
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 2 }, 5*time.Second, 5*time.Millisecond)
+	var delivered []string
+	for _, request := range receiver.received() {
+		delivered = append(delivered, request.header.Get("X-Webhook-Delivery"))
+	}
+	assert.ElementsMatch(t, []string{"pending", "retrying"}, delivered)
+
+	require.Eventually(t, func() bool {
+		delivery, err := f.webhooks.GetDeliveryByID(nil, "retrying")
+		require.NoError(t, err)
+		return delivery.Status == model.DeliverySucceeded && delivery.Attempts == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, receiver.received(), 2)
+}
+
+// TestWebhookWorkers checks that a backlog of due deliveries is attempted
+// no more than Workers at a time.
+func TestWebhookWorkers(t *testing.T) {
+	var mu sync.Mutex
+	var active, most, delivered int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		active++
+		most = max(most, active)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		delivered++
+		mu.Unlock()
+	}))
+	t.Cleanup(receiver.Close)
+
+	config := testWebhookConfig()
+	config.Workers = 2
+	f := newWebhookFixture(t, config)
+	webhook := f.register(receiver.URL)
+	now := time.Now().UTC().Truncate(time.Second)
+	due := now.Add(-time.Minute)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, f.webhooks.InsertDelivery(nil, model.WebhookDelivery{
+			ID:            "delivery-" + strconv.Itoa(i),
+			WebhookID:     webhook.ID,
+			EventType:     model.EventReportCreated,
+			Payload:       `{"id":1}`,
+			Status:        model.DeliveryPending,
+			NextAttemptAt: &due,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}))
+	}
+	f.run()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return delivered == 10
+	}, 5*time.Second, 5*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, most)
+}
+
+func TestWebhookRejectsPrivateAddresses(t *testing.T) {
+	config := testWebhookConfig()
+	config.AllowPrivateAddresses = false
+	f := newWebhookFixture(t, config)
+
+	for _, URL := range []string{
+		"http://127.0.0.1/hook",
+		"http://127.1.2.3:8080/hook",
+		"http://localhost/hook",
+		"http://api.localhost/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+		"http://10.0.0.1/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fd00::1]/hook",
+		"http://[fe80::1]/hook",
+	} {
+		_, err := f.app.Register(nil, "default", URL, []string{"*"}, "")
+		if assert.Error(t, err, URL) {
+			assert.Equal(t, "webhook url not allowed", err.Error(), URL)
+		}
+	}
+	_, err := f.app.Register(nil, "default", "https://hooks.example.com/hook", []string{model.EventUserUpdated}, "")
+	assert.NoError(t, err)
+
+	// A host name can resolve to a private address, so the address is
+	// checked again when connecting. The webhook is stored directly, as
+	// Register would refuse it.
+	receiver := newTestReceiver(t)
+	webhook := model.Webhook{ID: "loopback", OrgID: "default", URL: receiver.URL, Events: []string{"*"}, Secret: "secret", CreatedAt: time.Now()}
+	require.NoError(t, f.webhooks.Insert(nil, webhook))
+	f.run()
+	f.broker.Publish(model.Event{ID: 1, Type: model.EventReportCreated, OrgID: "default", Time: time.Now()})
+
+	delivery := f.settled(webhook)
+	assert.Equal(t, model.DeliveryFailed, delivery.Status)
+	assert.Contains(t, delivery.Error, "is not a public address")
+	assert.Empty(t, receiver.received())
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Scopes double as the permissions granted by roles.
+const (
+	ScopeReportsRead    = "reports:read"
+	ScopeReportsWrite   = "reports:write"
+	ScopeReportsReadAll = "reports:read_all"
+	ScopeUsersRead      = "users:read"
+	ScopeUsersWrite     = "users:write"
+	ScopeUsersAdmin     = "users:admin"
+)
+
+// APIKey is the stored form of a key. The key itself is only returned once,
+// when it is issued; afterwards it can be recognised by Prefix. A signing key
+// is never sent as a bearer token: its secret signs requests instead, so the
+// secret itself has to be kept rather than a hash of it.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Signing    bool       `json:"signing"`
+	Secret     string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Principal is the authenticated caller of a request. KeyID is set for API
+// keys and SessionID for password logins. Scopes are the effective ones:
+// what both the user's role and, for API keys, the key allow. OrgID is the
+// organization the caller is confined to. Suspended callers keep only the
+// read scopes.
+type Principal struct {
+	UserID    string   `json:"user_id"`
+	OrgID     string   `json:"org_id"`
+	KeyID     string   `json:"key_id,omitempty"`
+	SessionID string   `json:"session_id,omitempty"`
+	Role      string   `json:"role"`
+	Scopes    []string `json:"scopes"`
+	Suspended bool     `json:"suspended,omitempty"`
+}
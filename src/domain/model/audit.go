@@ -0,0 +1,25 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditUserDeleted       = "user.deleted"
+	AuditUserRenamed       = "user.renamed"
+	AuditUserStatusChanged = "user.status_changed"
+)
+
+// AuditEntry records who did what to whom. Detail depends on Action; for
+// user.deleted it is the UserDeletion, for user.renamed the UserRename and
+// for user.status_changed the UserStatusChange.
+type AuditEntry struct {
+	ID        uint64          `json:"id,string"`
+	OrgID     string          `json:"-"`
+	ActorID   string          `json:"actor_id"`
+	Action    string          `json:"action"`
+	TargetID  string          `json:"target_id"`
+	Detail    json.RawMessage `json:"detail,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+)
+
+// ReportChange is one entry of the change feed. Report is set for upserts
+// and nil for tombstones.
+type ReportChange struct {
+	Cursor    uint64    `json:"cursor,string"`
+	Operation string    `json:"op"`
+	ReportID  string    `json:"report_id"`
+	Report    *Report   `json:"report,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type ChangeFeed struct {
+	Changes    []ReportChange `json:"changes"`
+	NextCursor uint64         `json:"next_cursor,string"`
+	HasMore    bool           `json:"has_more"`
+}
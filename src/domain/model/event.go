@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	EventReportCreated = "report.created"
+	EventReportUpdated = "report.updated"
+	EventReportEjected = "report.ejected"
+	EventUserUpdated   = "user.updated"
+
+	// EventStreamReset tells a resuming subscriber that events were lost
+	// because its Last-Event-ID is older than the replay buffer.
+	EventStreamReset = "stream.reset"
+)
+
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	AuthorID string      `json:"author_id,omitempty"`
+	OrgID    string      `json:"-"`
+	Data     interface{} `json:"data,omitempty"`
+	Time     time.Time   `json:"time"`
+}
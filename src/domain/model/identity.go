@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Identity links a user to an account at an external identity provider,
+// which names it by Subject, unique within the provider's Issuer.
+type Identity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
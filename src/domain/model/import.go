@@ -0,0 +1,16 @@
+package model
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportResult struct {
+	Format   string           `json:"format"`
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Inserted int              `json:"inserted"`
+	Errors   []ImportRowError `json:"errors"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// DefaultOrganization is the organization of users created before there
+// were organizations.
+const DefaultOrganization = "default"
+
+// Organization is a tenant, such as a school. Users and reports belong to
+// exactly one organization and are never visible outside it.
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,10 +1,43 @@
 package model
 
+import "time"
+
+const (
+	// VisibilityPrivate reports are only visible to their author and
+	// administrators; VisibilityPublic reports to every user.
+	VisibilityPrivate = "private"
+	VisibilityPublic  = "public"
+)
+
 type Report struct {
 	ID        string `json:"id"`
 	AuthorID  string `json:"author_id"`
+	OrgID     string `json:"-"`
 	Count 	 int    `json:"count"`
 	Title     string `json:"title"`
 	Style     string `json:"style"`
 	Language  string `json:"language"`
+	Visibility string `json:"visibility"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// ReportStats summarizes the reports of an author.
+type ReportStats struct {
+	AuthorID     string         `json:"author_id"`
+	Reports      int            `json:"reports"`
+	TotalCount   int            `json:"total_count"`
+	AverageCount float64        `json:"average_count"`
+	ByStyle      map[string]int `json:"by_style"`
+	ByLanguage   map[string]int `json:"by_language"`
+	// Activity has a day for each of the days asked for, oldest first, days
+	// without reports included.
+	Activity []ReportActivity `json:"activity"`
+}
+
+// ReportActivity counts the reports created on Date, a UTC day such as
+// 2024-04-01, and the sum of their counts.
+type ReportActivity struct {
+	Date       string `json:"date"`
+	Reports    int    `json:"reports"`
+	TotalCount int    `json:"total_count"`
 }
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// Credential is a user's password login state. Users without one can only
+// authenticate with API keys.
+type Credential struct {
+	UserID         string
+	PasswordHash   string
+	FailedAttempts int
+	LockedUntil    *time.Time
+	UpdatedAt      time.Time
+}
+
+// RefreshToken is the stored form of a refresh token. Every login starts a
+// new family; refreshing replaces the token with a new one of the same
+// family, and revoking a session revokes its whole family.
+type RefreshToken struct {
+	ID         string
+	UserID     string
+	FamilyID   string
+	Hash       string
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy string
+	CreatedAt  time.Time
+}
+
+// TokenPair is returned by login and refresh, in the shape of an OAuth 2.0
+// token response.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+const (
+	// Share levels, each including the ones before it. ShareComment allows
+	// reading until reports can be commented on.
+	ShareRead    = "read"
+	ShareComment = "comment"
+	ShareEdit    = "edit"
+)
+
+// ReportShare grants UserID access to a report of another author.
+type ReportShare struct {
+	ReportID  string    `json:"report_id"`
+	UserID    string    `json:"user_id"`
+	Level     string    `json:"level"`
+	GrantedBy string    `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SharedReport is a report shared with the caller and the level it was
+// shared at.
+type SharedReport struct {
+	Report
+	Level string `json:"level"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// ShareLink lets anyone holding its token read a report without an account
+// until it expires, is revoked or, if SingleUse, has been opened once. The
+// token is derived from the link and a server secret and never stored.
+type ShareLink struct {
+	ID        string     `json:"id"`
+	ReportID  string     `json:"report_id"`
+	OrgID     string     `json:"-"`
+	CreatedBy string     `json:"created_by"`
+	SingleUse bool       `json:"single_use"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
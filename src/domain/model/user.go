@@ -1,6 +1,115 @@
 package model
+
+import "time"
+
+// A suspended user can still read but not change anything. A deactivated
+// user can no longer authenticate, but keeps their reports, which are then
+// hidden from those who can read them only for being public.
+const (
+  UserActive      = "active"
+  UserSuspended   = "suspended"
+  UserDeactivated = "deactivated"
+)
+
+// How UserQuery.Search is matched against names.
+const (
+  MatchPrefix   = "prefix"
+  MatchContains = "contains"
+)
+
+// The orders users can be listed in.
+const (
+  UserSortID          = "id"
+  UserSortName        = "name"
+  UserSortUpdatedAt   = "updated_at"
+  UserSortReportCount = "report_count"
+)
+
+// What becomes of the reports of a user who is deleted.
+const (
+  DeleteBlock    = "block"
+  DeleteCascade  = "cascade"
+  DeleteReassign = "reassign"
+)
   
 type User struct {
   ID string `json:"id"`
   Name string `json:"name"`
+  Status string `json:"status"`
+  Email string `json:"email"`
+  DisplayName string `json:"display_name"`
+  Affiliation string `json:"affiliation"`
+  // TimeZone is an IANA name such as Asia/Tokyo.
+  TimeZone string `json:"time_zone"`
+  // The defaults fill in reports registered without a style, language or
+  // count.
+  DefaultStyle string `json:"default_style"`
+  DefaultLanguage string `json:"default_language"`
+  DefaultCount int `json:"default_count"`
+  // ReportCount is only set when a listing asks for it.
+  ReportCount *int `json:"report_count,omitempty"`
+  OrgID string `json:"-"`
+  UpdatedAt time.Time `json:"-"`
+}
+
+// UserProfile is a change to the profile of a user. Nil fields are left as
+// they are.
+type UserProfile struct {
+  Name *string `json:"name"`
+  Email *string `json:"email"`
+  DisplayName *string `json:"display_name"`
+  Affiliation *string `json:"affiliation"`
+  TimeZone *string `json:"time_zone"`
+  DefaultStyle *string `json:"default_style"`
+  DefaultLanguage *string `json:"default_language"`
+  DefaultCount *int `json:"default_count"`
+}
+
+// UserQuery selects a page of the users of an organization. Search matches
+// names regardless of case, width, and hiragana or katakana; an empty Search
+// or Status matches every user.
+type UserQuery struct {
+  Search string
+  Match string
+  Status string
+  Sort string
+  Descending bool
+  Offset int
+  Limit int
+  WithReportCount bool
+  // ReportsVisibleTo, when set, counts only the public reports of authors
+  // who are not deactivated and the reports of this user, for callers who
+  // cannot read every report.
+  ReportsVisibleTo string
+}
+
+type UserPage struct {
+  Users []User `json:"users"`
+  Total int `json:"total"`
+  NextOffset *int `json:"next_offset,omitempty"`
+}
+
+// UserDeletion is how a user is deleted. Reports is the number of reports
+// that were deleted or reassigned.
+type UserDeletion struct {
+  Policy string `json:"policy"`
+  ReassignTo string `json:"reassign_to,omitempty"`
+  Reports int `json:"reports"`
+}
+
+// UserStatusChange is what UserApp.SetStatus did. Reason is for the audit
+// log.
+type UserStatusChange struct {
+  UserID string `json:"user_id"`
+  From string `json:"from"`
+  To string `json:"to"`
+  Reason string `json:"reason,omitempty"`
+}
+
+// UserRename is a change of a user's ID. Until AliasExpiresAt, lookups of
+// OldID are redirected to NewID.
+type UserRename struct {
+  OldID string `json:"old_id"`
+  NewID string `json:"new_id"`
+  AliasExpiresAt time.Time `json:"alias_expires_at"`
 }
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryRetrying  = "retrying"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"-"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID           string `json:"id"`
+	WebhookID    string `json:"webhook_id"`
+	EventID      uint64 `json:"event_id"`
+	EventType    string `json:"event_type"`
+	Payload      string `json:"payload"`
+	Status       string `json:"status"`
+	Attempts     int    `json:"attempts"`
+	ResponseCode int    `json:"response_code"`
+	Error        string `json:"error"`
+	// NextAttemptAt is when the delivery is attempted next, or nil once it
+	// has succeeded or run out of attempts.
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// DueDelivery is a delivery whose next attempt is due, with the webhook it
+// goes to.
+type DueDelivery struct {
+	Webhook  Webhook
+	Delivery WebhookDelivery
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+    "database/sql"
+    "time"
+    "repo-api/src/domain/model"
+)
+
+type IAPIKeyRepository interface {
+    Insert(DB *sql.DB, key model.APIKey) error
+    GetByID(DB *sql.DB, ID string) (model.APIKey, error)
+    GetByHash(DB *sql.DB, Hash string) (model.APIKey, error)
+    GetByUserID(DB *sql.DB, UserID string) ([]model.APIKey, error)
+    Revoke(DB *sql.DB, ID string, RevokedAt time.Time) error
+    UpdateLastUsed(DB *sql.DB, ID string, LastUsedAt time.Time) error
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+    "database/sql"
+    "repo-api/src/domain/model"
+)
+
+type IAuditRepository interface {
+    // GetByOrgID returns the newest entries first, those with an ID below
+    // Before when it is not zero.
+    GetByOrgID(DB *sql.DB, OrgID string, Before uint64, Limit int) ([]model.AuditEntry, error)
+}
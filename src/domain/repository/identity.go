@@ -0,0 +1,11 @@
+package repository
+
+import (
+    "database/sql"
+    "repo-api/src/domain/model"
+)
+
+type IIdentityRepository interface {
+    Insert(DB *sql.DB, identity model.Identity) error
+    GetBySubject(DB *sql.DB, Issuer, Subject string) (model.Identity, error)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import (
+    "database/sql"
+    "repo-api/src/domain/model"
+)
+
+type IOrganizationRepository interface {
+    Insert(DB *sql.DB, organization model.Organization) error
+    GetByID(DB *sql.DB, ID string) (model.Organization, error)
+}
@@ -2,21 +2,35 @@ package repository
 
 import (
     "database/sql"
+    "time"
     "repo-api/src/domain/model"
 )
 
 type IReportRepository interface {
-    Insert(DB *sql.DB, ID, AuthorID string, Count int, Title, Style, Language string) error
+    Insert(DB *sql.DB, ID, AuthorID, OrgID string, Count int, Title, Style, Language, Visibility string) error
+    InsertBatch(DB *sql.DB, reports []model.Report) error
     Eject(DB *sql.DB, ID string) error
     
-    GetByID(DB *sql.DB, ID string) (model.Report, error)
-    GetByAuthorID(DB *sql.DB, AuthorID string) ([]model.Report, error)
-    GetByTitle(DB *sql.DB, AuthorID, Title string) ([]model.Report, error)
-    GetByStyle(DB *sql.DB, AuthorID, Style string) ([]model.Report, error)
-    GetByLanguage(DB *sql.DB, AuthorID, Language string) ([]model.Report, error)
+    // GetByID returns report ID of organization OrgID, or a zero report
+    // when there is none, also when ID belongs to another organization.
+    GetByID(DB *sql.DB, OrgID, ID string) (model.Report, error)
+    // IDTaken reports whether a report of any organization has ID.
+    IDTaken(DB *sql.DB, ID string) (bool, error)
+    GetByAuthorID(DB *sql.DB, OrgID, AuthorID string) ([]model.Report, error)
+    GetByTitle(DB *sql.DB, OrgID, AuthorID, Title string) ([]model.Report, error)
+    GetByStyle(DB *sql.DB, OrgID, AuthorID, Style string) ([]model.Report, error)
+    GetByLanguage(DB *sql.DB, OrgID, AuthorID, Language string) ([]model.Report, error)
     
     UpdateCount(DB *sql.DB, ID string, Count int) error
     UpdateTitle(DB *sql.DB, ID, Title string) error
     UpdateStyle(DB *sql.DB, ID, Style string) error
     UpdateLanguage(DB *sql.DB, ID, Language string) error
+    UpdateVisibility(DB *sql.DB, ID, Visibility string) error
+
+    // GetChanges returns the changes after Since that were recorded at least
+    // Settle ago, stopping at the first more recent one.
+    GetChanges(DB *sql.DB, OrgID, AuthorID string, Since uint64, Limit int, Settle time.Duration) ([]model.ReportChange, error)
+    // GetStats aggregates the reports of AuthorID, only public ones if
+    // PublicOnly. Activity lists the days since Since that have reports.
+    GetStats(DB *sql.DB, OrgID, AuthorID string, PublicOnly bool, Since time.Time) (model.ReportStats, error)
 }
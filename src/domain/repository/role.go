@@ -0,0 +1,10 @@
+package repository
+
+import (
+    "database/sql"
+)
+
+type IRoleRepository interface {
+    GetRole(DB *sql.DB, UserID string) (string, error)
+    UpdateRole(DB *sql.DB, UserID, Role string) error
+}
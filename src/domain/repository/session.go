@@ -0,0 +1,29 @@
+package repository
+
+import (
+    "database/sql"
+    "time"
+    "repo-api/src/domain/model"
+)
+
+type ISessionRepository interface {
+    GetCredential(DB *sql.DB, UserID string) (model.Credential, error)
+    UpsertCredential(DB *sql.DB, UserID, PasswordHash string) error
+    UpdateLoginFailures(DB *sql.DB, UserID string, FailedAttempts int, LockedUntil *time.Time) error
+    // RecordLoginFailure counts a failed login under lock, so that parallel
+    // failures are all counted. The MaxAttempts-th failure in a row locks the
+    // credential until LockUntil; the lock in force, if any, is returned.
+    RecordLoginFailure(DB *sql.DB, UserID string, MaxAttempts int, Now, LockUntil time.Time) (*time.Time, error)
+
+    InsertRefreshToken(DB *sql.DB, token model.RefreshToken) error
+    GetRefreshTokenByHash(DB *sql.DB, Hash string) (model.RefreshToken, error)
+    // RotateRefreshToken revokes old in favour of next in one transaction,
+    // failing with "refresh token already used" if old was revoked
+    // concurrently.
+    RotateRefreshToken(DB *sql.DB, old model.RefreshToken, next model.RefreshToken) error
+    RevokeRefreshFamily(DB *sql.DB, FamilyID string, RevokedAt time.Time) error
+    RevokeUserRefreshTokens(DB *sql.DB, UserID string, RevokedAt time.Time) error
+    // IsFamilyActive reports whether the family still has an unrevoked,
+    // unexpired token, that is whether the session is still logged in.
+    IsFamilyActive(DB *sql.DB, FamilyID string, Now time.Time) (bool, error)
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+    "database/sql"
+    "repo-api/src/domain/model"
+)
+
+type IShareRepository interface {
+    Upsert(DB *sql.DB, share model.ReportShare) error
+    Delete(DB *sql.DB, ReportID, UserID string) error
+
+    GetLevel(DB *sql.DB, ReportID, UserID string) (string, error)
+    GetByReportID(DB *sql.DB, ReportID string) ([]model.ReportShare, error)
+    GetLevelsByUserID(DB *sql.DB, UserID string) (map[string]string, error)
+    GetSharedWith(DB *sql.DB, UserID string) ([]model.SharedReport, error)
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+    "database/sql"
+    "repo-api/src/domain/model"
+    "time"
+)
+
+type IShareLinkRepository interface {
+    Insert(DB *sql.DB, link model.ShareLink) error
+    // GetByID fails with "link not found" unless link ID belongs to
+    // organization OrgID.
+    GetByID(DB *sql.DB, OrgID, ID string) (model.ShareLink, error)
+    // GetForToken returns link ID whatever its organization, for opening a
+    // link: the signed token stands in for a caller.
+    GetForToken(DB *sql.DB, ID string) (model.ShareLink, error)
+    GetByReportID(DB *sql.DB, ReportID string) ([]model.ShareLink, error)
+    Revoke(DB *sql.DB, ID string, RevokedAt time.Time) error
+    // MarkUsed fails with "link already used" unless this call is the
+    // first to use the link.
+    MarkUsed(DB *sql.DB, ID string, UsedAt time.Time) error
+}
@@ -3,10 +3,48 @@ package repository
 import (
     "database/sql"
     "repo-api/src/domain/model"
+    "time"
 )
 
 type IUserRepository interface {
-    Insert(DB *sql.DB, ID, Name string) error
-    GetByID(DB *sql.DB, ID string) (model.User, error)
+    Insert(DB *sql.DB, user model.User) error
+    InsertBatch(DB *sql.DB, users []model.User) error
+    // GetByID returns user ID of organization OrgID, or sql.ErrNoRows, also
+    // when ID belongs to another organization.
+    GetByID(DB *sql.DB, OrgID, ID string) (model.User, error)
+    // GetCaller returns user ID whatever their organization, for finding the
+    // organization of an authenticated caller. Everything done on behalf of
+    // a caller looks users up with GetByID instead.
+    GetCaller(DB *sql.DB, ID string) (model.User, error)
+    // IDTaken reports whether a user of any organization has ID, or had it
+    // and it is still their unexpired alias. User IDs are unique across
+    // organizations. Insert and InsertBatch refuse taken IDs too.
+    IDTaken(DB *sql.DB, ID string) (bool, error)
+    GetByOrgID(DB *sql.DB, OrgID string) ([]model.User, error)
+    // Search returns a page of the users of the organization and how many
+    // users match the query in all.
+    Search(DB *sql.DB, OrgID string, Query model.UserQuery) ([]model.User, int, error)
     UpdateNameByID(DB *sql.DB, ID, Name string) error
+    // UpdateProfile writes the name and profile fields of the user.
+    UpdateProfile(DB *sql.DB, user model.User) error
+    // UpdateStatus sets the status of Change.UserID to Change.To and returns
+    // Change with From read under lock. Audit is recorded, with the change as
+    // its detail, in the same transaction.
+    UpdateStatus(DB *sql.DB, Change model.UserStatusChange, Audit model.AuditEntry) (model.UserStatusChange, error)
+    // Rename changes the ID of the user everywhere it is stored, the authors
+    // of their reports included, and leaves an alias from the old ID that
+    // expires at AliasExpiresAt. It fails with "user id taken" when NewID
+    // belongs to another user or is an unexpired alias of one. Audit is
+    // recorded, with the rename as its detail, in the same transaction.
+    Rename(DB *sql.DB, Rename model.UserRename, Audit model.AuditEntry) error
+    // GetAlias returns the ID a user was renamed to from ID, or "alias not
+    // found" when there is no such alias or it expired before now.
+    GetAlias(DB *sql.DB, ID string, now time.Time) (string, error)
+    // Delete removes the user together with their passwords, sessions, API
+    // keys, linked identities and the shares granted to them. Their reports
+    // are deleted with the cascade policy and handed to ReassignTo with
+    // reassign; otherwise Delete refuses with "user has reports" while the
+    // user has any. Audit is recorded, with the deletion as its detail, in
+    // the same transaction. It returns the reports as they were before.
+    Delete(DB *sql.DB, ID string, Deletion model.UserDeletion, Audit model.AuditEntry) ([]model.Report, error)
 }
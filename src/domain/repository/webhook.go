@@ -0,0 +1,26 @@
+package repository
+
+import (
+    "database/sql"
+    "repo-api/src/domain/model"
+    "time"
+)
+
+type IWebhookRepository interface {
+    Insert(DB *sql.DB, webhook model.Webhook) error
+    Delete(DB *sql.DB, ID string) error
+    // GetByID fails with "webhook not found" unless webhook ID belongs to
+    // organization OrgID.
+    GetByID(DB *sql.DB, OrgID, ID string) (model.Webhook, error)
+    GetByOrgID(DB *sql.DB, OrgID string) ([]model.Webhook, error)
+
+    InsertDelivery(DB *sql.DB, delivery model.WebhookDelivery) error
+    UpdateDelivery(DB *sql.DB, delivery model.WebhookDelivery) error
+    // ClaimDueDeliveries returns up to Limit deliveries whose next attempt
+    // is due at Now, and moves that attempt to Until so that no other
+    // worker makes it meanwhile. Should the worker stop before recording
+    // the attempt, the delivery is due again at Until.
+    ClaimDueDeliveries(DB *sql.DB, Now, Until time.Time, Limit int) ([]model.DueDelivery, error)
+    GetDeliveryByID(DB *sql.DB, ID string) (model.WebhookDelivery, error)
+    GetDeliveriesByWebhookID(DB *sql.DB, WebhookID string) ([]model.WebhookDelivery, error)
+}
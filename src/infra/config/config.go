@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config holds the settings that can be tuned without rebuilding. It is read
+// from the JSON file named by REPOAPI_CONFIG; anything the file leaves out
+// keeps its default.
+type Config struct {
+	// CacheControl maps a route such as "GET /report" to the Cache-Control
+	// header sent with its successful responses.
+	CacheControl map[string]string `json:"cache_control"`
+
+	RateLimit RateLimitConfig `json:"rate_limit"`
+
+	// TrustedProxies are the addresses or CIDR ranges whose X-Forwarded-For
+	// is believed when finding the client's address. None by default.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	BodyLimit BodyLimitConfig `json:"body_limit"`
+
+	CORS CORSConfig `json:"cors"`
+
+	Session SessionConfig `json:"session"`
+
+	ShareLink ShareLinkConfig `json:"share_link"`
+
+	Signature SignatureConfig `json:"signature"`
+
+	OIDC OIDCConfig `json:"oidc"`
+
+	User UserConfig `json:"user"`
+
+	// Roles is the permission matrix: the scopes each role grants. Its keys
+	// are the roles that can be assigned.
+	Roles map[string][]string `json:"roles"`
+}
+
+// SessionConfig governs password logins. Without a JWTSecret a random one is
+// generated at startup, which logs everyone out on every restart and does
+// not work with several instances.
+type SessionConfig struct {
+	JWTSecret              string `json:"jwt_secret"`
+	AccessTokenTTLSeconds  int    `json:"access_token_ttl_seconds"`
+	RefreshTokenTTLSeconds int    `json:"refresh_token_ttl_seconds"`
+	// MaxLoginAttempts failed logins in a row lock the account for
+	// LockoutSeconds.
+	MaxLoginAttempts int `json:"max_login_attempts"`
+	LockoutSeconds   int `json:"lockout_seconds"`
+}
+
+// ShareLinkConfig governs public links to reports. Like the JWT secret, a
+// missing Secret is generated at startup, which breaks every link on restart.
+type ShareLinkConfig struct {
+	Secret            string `json:"secret"`
+	DefaultTTLSeconds int    `json:"default_ttl_seconds"`
+	MaxTTLSeconds     int    `json:"max_ttl_seconds"`
+}
+
+// SignatureConfig governs requests signed with a signing key.
+type SignatureConfig struct {
+	MaxSkewSeconds int `json:"max_skew_seconds"`
+}
+
+// UserConfig governs user accounts. The old ID of a renamed user keeps
+// redirecting to the new one for IDAliasTTLSeconds.
+type UserConfig struct {
+	IDAliasTTLSeconds int `json:"id_alias_ttl_seconds"`
+}
+
+// OIDCConfig configures login with an OpenID Connect provider, which is
+// enabled by setting Issuer. The endpoints are discovered from the issuer
+// unless they are set.
+type OIDCConfig struct {
+	Issuer                string   `json:"issuer"`
+	ClientID              string   `json:"client_id"`
+	ClientSecret          string   `json:"client_secret"`
+	RedirectURL           string   `json:"redirect_url"`
+	Scopes                []string `json:"scopes"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	Organization          string   `json:"organization"`
+	AutoProvision         bool     `json:"auto_provision"`
+	LoginTTLSeconds       int      `json:"login_ttl_seconds"`
+	JWKSCacheTTLSeconds   int      `json:"jwks_cache_ttl_seconds"`
+}
+
+type BodyLimitConfig struct {
+	DefaultBytes int64            `json:"default_bytes"`
+	Routes       map[string]int64 `json:"routes"`
+}
+
+// Limit returns the maximum request body size for route.
+func (b BodyLimitConfig) Limit(route string) int64 {
+	if limit, ok := b.Routes[route]; ok {
+		return limit
+	}
+	return b.DefaultBytes
+}
+
+// CORSConfig is the cross-origin policy for browser clients. With no
+// AllowedOrigins no CORS headers are sent at all; "*" allows any origin.
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAgeSeconds    int      `json:"max_age_seconds"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool          `json:"enabled"`
+	Default RateLimitRule `json:"default"`
+	// Routes overrides Default for individual routes, keyed like
+	// CacheControl.
+	Routes map[string]RateLimitRule `json:"routes"`
+}
+
+// RateLimitRule allows Limit requests per WindowSeconds, refilled gradually,
+// so a client may also burst up to Limit requests at once.
+type RateLimitRule struct {
+	Limit         int `json:"limit"`
+	WindowSeconds int `json:"window_seconds"`
+}
+
+// Rule returns the rule for route, falling back to the default.
+func (r RateLimitConfig) Rule(route string) RateLimitRule {
+	if rule, ok := r.Routes[route]; ok {
+		return rule
+	}
+	return r.Default
+}
+
+func Default() Config {
+	return Config{
+		CacheControl: map[string]string{
+			"GET /report": "private, no-cache",
+			"GET /user":   "private, no-cache",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Default: RateLimitRule{Limit: 120, WindowSeconds: 60},
+			Routes: map[string]RateLimitRule{
+				"GET /report":         {Limit: 60, WindowSeconds: 60},
+				"POST /user/import":   {Limit: 10, WindowSeconds: 60},
+				"POST /report/import": {Limit: 10, WindowSeconds: 60},
+				"POST /login":         {Limit: 10, WindowSeconds: 60},
+			},
+		},
+		BodyLimit: BodyLimitConfig{
+			DefaultBytes: 1 << 20,
+			Routes: map[string]int64{
+				"POST /user/import":   32 << 20,
+				"POST /report/import": 32 << 20,
+			},
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "Accept", "If-None-Match", "If-Modified-Since", "Last-Event-ID", "X-Organization-ID"},
+			ExposedHeaders: []string{"ETag", "Last-Modified", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
+			MaxAgeSeconds:  600,
+		},
+		Roles: map[string][]string{
+			"student": {"reports:write", "users:write"},
+			"teacher": {"reports:write", "reports:read_all", "users:write"},
+			"admin":   {"users:admin"},
+		},
+		Session: SessionConfig{
+			AccessTokenTTLSeconds:  15 * 60,
+			RefreshTokenTTLSeconds: 30 * 24 * 60 * 60,
+			MaxLoginAttempts:       5,
+			LockoutSeconds:         15 * 60,
+		},
+		ShareLink: ShareLinkConfig{
+			DefaultTTLSeconds: 7 * 24 * 60 * 60,
+			MaxTTLSeconds:     30 * 24 * 60 * 60,
+		},
+		Signature: SignatureConfig{
+			MaxSkewSeconds: 5 * 60,
+		},
+		OIDC: OIDCConfig{
+			Scopes:              []string{"openid", "email", "profile"},
+			Organization:        "default",
+			AutoProvision:       true,
+			LoginTTLSeconds:     10 * 60,
+			JWKSCacheTTLSeconds: 60 * 60,
+		},
+		User: UserConfig{
+			IDAliasTTLSeconds: 30 * 24 * 60 * 60,
+		},
+	}
+}
+
+func Load() (Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("REPOAPI_CONFIG")
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	// Unmarshalling over the defaults keeps every field the file omits and
+	// merges map entries into the default maps.
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -7,10 +7,16 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// dsn pins the session time zone to UTC, which is how times are read back
+// (loc) and what the queries assume: DATETIME columns keep wall-clock time,
+// so CURRENT_TIMESTAMP defaults and DATE() would otherwise follow whatever
+// time zone the server runs in.
+const dsn = "user:password@tcp(DB)/api?parseTime=true&loc=UTC&time_zone=%27%2B00%3A00%27"
+
 func NewDatabase() (*sql.DB, error) {
 	count := 15
 	for count > 1 {
-		db, err := sql.Open("mysql","user:password@tcp(DB)/api")
+		db, err := sql.Open("mysql", dsn)
 		if err != nil {
 			time.Sleep(time.Second * 2)
 			count--
@@ -0,0 +1,18 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDSNUsesUTC(t *testing.T) {
+	config, err := mysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.True(t, config.ParseTime)
+	assert.Equal(t, time.UTC, config.Loc)
+	assert.Equal(t, "'+00:00'", config.Params["time_zone"])
+}
@@ -0,0 +1,89 @@
+// Package dbtest gives tests a MySQL database with the schema of
+// src/infra/migrations. Tests that need one are skipped unless
+// REPOAPI_TEST_DSN points at a server on which they may create databases,
+// such as the db service of docker-compose.yml:
+//
+//	REPOAPI_TEST_DSN='root:root@tcp(127.0.0.1:3306)/' go test ./...
+package dbtest
+
+import (
+	"database/sql"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"repo-api/src/infra/migrations"
+)
+
+const DSNVariable = "REPOAPI_TEST_DSN"
+
+// Enabled reports whether DSNVariable is set.
+func Enabled() bool {
+	return os.Getenv(DSNVariable) != ""
+}
+
+// Open recreates database name with every table of the schema and returns
+// a connection to it, closed when t ends. The session is set up as in
+// production: UTC, with DATETIME columns read as time.Time. Packages use
+// names of their own, as go test runs packages in parallel.
+func Open(t testing.TB, name string) *sql.DB {
+	t.Helper()
+	if !Enabled() {
+		t.Skipf("%s is not set", DSNVariable)
+	}
+	config, err := mysql.ParseDSN(os.Getenv(DSNVariable))
+	if err != nil {
+		t.Fatalf("%s: %v", DSNVariable, err)
+	}
+	config.ParseTime = true
+	config.Loc = time.UTC
+	if config.Params == nil {
+		config.Params = map[string]string{}
+	}
+	config.Params["time_zone"] = "'+00:00'"
+
+	config.DBName = ""
+	server := connect(t, config)
+	exec(t, server, "DROP DATABASE IF EXISTS `"+name+"`")
+	exec(t, server, "CREATE DATABASE `"+name+"` CHARACTER SET utf8mb4")
+
+	config.DBName = name
+	db := connect(t, config)
+	files, err := fs.Glob(migrations.Files, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		schema, err := fs.ReadFile(migrations.Files, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, statement := range strings.Split(string(schema), ";") {
+			if strings.TrimSpace(statement) != "" {
+				exec(t, db, statement)
+			}
+		}
+	}
+	return db
+}
+
+func connect(t testing.TB, config *mysql.Config) *sql.DB {
+	t.Helper()
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func exec(t testing.TB, db *sql.DB, statement string) {
+	t.Helper()
+	if _, err := db.Exec(statement); err != nil {
+		t.Fatalf("%s: %v", strings.TrimSpace(statement), err)
+	}
+}
@@ -0,0 +1,99 @@
+package event
+
+import (
+	"sync"
+	"time"
+
+	"repo-api/src/domain/model"
+)
+
+const subscriberBuffer = 64
+
+// Broker fans published events out to live subscribers and keeps the most
+// recent ones in a ring buffer so reconnecting clients can resume.
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	buffer      []model.Event
+	start       int
+	size        int
+	subscribers map[chan model.Event]struct{}
+}
+
+// NewBroker keeps up to capacity events for replay. IDs start from the
+// current time in microseconds, so IDs issued before a restart always look
+// older than the buffer and resuming clients are told about the gap.
+func NewBroker(capacity int) *Broker {
+	return &Broker{
+		nextID:      uint64(time.Now().UnixMicro()),
+		buffer:      make([]model.Event, capacity),
+		subscribers: make(map[chan model.Event]struct{}),
+	}
+}
+
+func (b *Broker) Publish(event model.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event.ID = b.nextID
+	b.nextID++
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	if len(b.buffer) > 0 {
+		if b.size < len(b.buffer) {
+			b.buffer[(b.start+b.size)%len(b.buffer)] = event
+			b.size++
+		} else {
+			b.buffer[b.start] = event
+			b.start = (b.start + 1) % len(b.buffer)
+		}
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// The subscriber is not keeping up; drop it so the client
+			// reconnects and resumes from the replay buffer instead.
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *Broker) Subscribe(lastEventID uint64) ([]model.Event, bool, <-chan model.Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []model.Event
+	gap := false
+	if lastEventID != 0 {
+		firstAvailable := b.nextID
+		if b.size > 0 {
+			firstAvailable = b.buffer[b.start].ID
+		}
+		gap = lastEventID+1 < firstAvailable || lastEventID >= b.nextID
+
+		for i := 0; i < b.size; i++ {
+			event := b.buffer[(b.start+i)%len(b.buffer)]
+			if gap || event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan model.Event, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return replay, gap, ch, cancel
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewAPIKeyRepository(s *Store) repository.IAPIKeyRepository {
+	return &apiKeyRepository{s}
+}
+
+type apiKeyRepository struct{ s *Store }
+
+func (a *apiKeyRepository) Insert(DB *sql.DB, key model.APIKey) error {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	key.Scopes = append([]string(nil), key.Scopes...)
+	a.s.apiKeys[key.ID] = key
+	return nil
+}
+
+func (a *apiKeyRepository) GetByID(DB *sql.DB, ID string) (model.APIKey, error) {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	key, ok := a.s.apiKeys[ID]
+	if !ok {
+		return model.APIKey{}, fmt.Errorf("api key not found")
+	}
+	return key, nil
+}
+
+func (a *apiKeyRepository) GetByHash(DB *sql.DB, Hash string) (model.APIKey, error) {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	for _, key := range a.s.apiKeys {
+		if key.Hash == Hash {
+			return key, nil
+		}
+	}
+	return model.APIKey{}, fmt.Errorf("api key not found")
+}
+
+func (a *apiKeyRepository) GetByUserID(DB *sql.DB, UserID string) ([]model.APIKey, error) {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	var keys []model.APIKey
+	for _, key := range a.s.apiKeys {
+		if key.UserID == UserID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (a *apiKeyRepository) Revoke(DB *sql.DB, ID string, RevokedAt time.Time) error {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	key, ok := a.s.apiKeys[ID]
+	if !ok || key.RevokedAt != nil {
+		return fmt.Errorf("api key not found")
+	}
+	key.RevokedAt = &RevokedAt
+	a.s.apiKeys[ID] = key
+	return nil
+}
+
+func (a *apiKeyRepository) UpdateLastUsed(DB *sql.DB, ID string, LastUsedAt time.Time) error {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	if key, ok := a.s.apiKeys[ID]; ok {
+		key.LastUsedAt = &LastUsedAt
+		a.s.apiKeys[ID] = key
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"database/sql"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewAuditRepository(s *Store) repository.IAuditRepository {
+	return &auditRepository{s}
+}
+
+type auditRepository struct{ s *Store }
+
+func (a *auditRepository) GetByOrgID(DB *sql.DB, OrgID string, Before uint64, Limit int) ([]model.AuditEntry, error) {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	entries := []model.AuditEntry{}
+	for i := len(a.s.audit) - 1; i >= 0 && len(entries) < Limit; i-- {
+		entry := a.s.audit[i]
+		if entry.OrgID == OrgID && (Before == 0 || entry.ID < Before) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
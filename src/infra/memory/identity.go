@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewIdentityRepository(s *Store) repository.IIdentityRepository {
+	return &identityRepository{s}
+}
+
+type identityRepository struct{ s *Store }
+
+func (i *identityRepository) Insert(DB *sql.DB, identity model.Identity) error {
+	i.s.mu.Lock()
+	defer i.s.mu.Unlock()
+
+	key := [2]string{identity.Issuer, identity.Subject}
+	if _, ok := i.s.identities[key]; ok {
+		return fmt.Errorf("failed to insert identity: duplicate subject %s", identity.Subject)
+	}
+	i.s.identities[key] = identity
+	return nil
+}
+
+func (i *identityRepository) GetBySubject(DB *sql.DB, Issuer, Subject string) (model.Identity, error) {
+	i.s.mu.Lock()
+	defer i.s.mu.Unlock()
+
+	identity, ok := i.s.identities[[2]string{Issuer, Subject}]
+	if !ok {
+		return model.Identity{}, fmt.Errorf("identity not found")
+	}
+	return identity, nil
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewOrganizationRepository(s *Store) repository.IOrganizationRepository {
+	return &organizationRepository{s}
+}
+
+type organizationRepository struct{ s *Store }
+
+func (o *organizationRepository) Insert(DB *sql.DB, organization model.Organization) error {
+	o.s.mu.Lock()
+	defer o.s.mu.Unlock()
+
+	if _, ok := o.s.organizations[organization.ID]; ok {
+		return fmt.Errorf("organization already exists")
+	}
+	o.s.organizations[organization.ID] = organization
+	return nil
+}
+
+func (o *organizationRepository) GetByID(DB *sql.DB, ID string) (model.Organization, error) {
+	o.s.mu.Lock()
+	defer o.s.mu.Unlock()
+
+	organization, ok := o.s.organizations[ID]
+	if !ok {
+		return model.Organization{}, fmt.Errorf("organization not found")
+	}
+	return organization, nil
+}
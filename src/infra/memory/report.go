@@ -0,0 +1,253 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewReportRepository(s *Store) repository.IReportRepository {
+	return &reportRepository{s}
+}
+
+type reportRepository struct{ s *Store }
+
+// checkAuthor is persistence's lockAuthor; the lock of the store stands in
+// for the shared lock on the author.
+func (s *Store) checkAuthor(AuthorID, OrgID string) error {
+	user, ok := s.users[AuthorID]
+	if !ok || user.OrgID != OrgID {
+		return fmt.Errorf("author does not exist")
+	}
+	if user.Status != model.UserActive {
+		return fmt.Errorf("author not active")
+	}
+	return nil
+}
+
+func (s *Store) insertReport(r model.Report) {
+	now := time.Now().UTC().Truncate(time.Second)
+	if r.Visibility == "" {
+		r.Visibility = model.VisibilityPrivate
+	}
+	r.UpdatedAt = now
+	s.reports[r.ID] = report{Report: r, createdAt: now}
+	s.recordChange(r.ID, model.ChangeUpsert)
+}
+
+// deleteReport removes the report with its shares and share links.
+func (s *Store) deleteReport(ID string) {
+	delete(s.reports, ID)
+	for key, share := range s.shares {
+		if share.ReportID == ID {
+			delete(s.shares, key)
+		}
+	}
+	for linkID, link := range s.shareLinks {
+		if link.ReportID == ID {
+			delete(s.shareLinks, linkID)
+		}
+	}
+}
+
+func (r *reportRepository) Insert(DB *sql.DB, ID, AuthorID, OrgID string, Count int, Title, Style, Language, Visibility string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkAuthor(AuthorID, OrgID); err != nil {
+		return err
+	}
+	if _, ok := r.s.reports[ID]; ok {
+		return fmt.Errorf("failed to insert report: duplicate ID %s", ID)
+	}
+	r.s.insertReport(model.Report{ID: ID, AuthorID: AuthorID, OrgID: OrgID, Count: Count, Title: Title, Style: Style, Language: Language, Visibility: Visibility})
+	return nil
+}
+
+func (r *reportRepository) InsertBatch(DB *sql.DB, reports []model.Report) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, report := range reports {
+		if err := r.s.checkAuthor(report.AuthorID, report.OrgID); err != nil {
+			return fmt.Errorf("author %s: %w", report.AuthorID, err)
+		}
+		if _, ok := r.s.reports[report.ID]; ok {
+			return fmt.Errorf("failed to insert reports: duplicate ID %s", report.ID)
+		}
+	}
+	for _, report := range reports {
+		r.s.insertReport(report)
+	}
+	return nil
+}
+
+func (r *reportRepository) Eject(DB *sql.DB, ID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.reports[ID]; !ok {
+		return fmt.Errorf("report not found")
+	}
+	r.s.recordChange(ID, model.ChangeDelete)
+	r.s.deleteReport(ID)
+	return nil
+}
+
+func (r *reportRepository) GetByID(DB *sql.DB, OrgID, ID string) (model.Report, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	report, ok := r.s.reports[ID]
+	if !ok || report.OrgID != OrgID {
+		return model.Report{}, nil
+	}
+	return report.Report, nil
+}
+
+func (r *reportRepository) IDTaken(DB *sql.DB, ID string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, ok := r.s.reports[ID]
+	return ok, nil
+}
+
+// filter returns the reports of the author that match, failing like
+// persistence when the author is not a user of the organization.
+func (r *reportRepository) filter(OrgID, AuthorID string, match func(model.Report) bool) ([]model.Report, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var reports []model.Report
+	if user, ok := r.s.users[AuthorID]; !ok || user.OrgID != OrgID {
+		return reports, fmt.Errorf("author does not exist")
+	}
+	for _, report := range r.s.reports {
+		if report.OrgID == OrgID && report.AuthorID == AuthorID && match(report.Report) {
+			reports = append(reports, report.Report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ID < reports[j].ID })
+	return reports, nil
+}
+
+func (r *reportRepository) GetByAuthorID(DB *sql.DB, OrgID, AuthorID string) ([]model.Report, error) {
+	return r.filter(OrgID, AuthorID, func(model.Report) bool { return true })
+}
+
+func (r *reportRepository) GetByTitle(DB *sql.DB, OrgID, AuthorID, Title string) ([]model.Report, error) {
+	return r.filter(OrgID, AuthorID, func(report model.Report) bool { return report.Title == Title })
+}
+
+func (r *reportRepository) GetByStyle(DB *sql.DB, OrgID, AuthorID, Style string) ([]model.Report, error) {
+	return r.filter(OrgID, AuthorID, func(report model.Report) bool { return report.Style == Style })
+}
+
+func (r *reportRepository) GetByLanguage(DB *sql.DB, OrgID, AuthorID, Language string) ([]model.Report, error) {
+	return r.filter(OrgID, AuthorID, func(report model.Report) bool { return report.Language == Language })
+}
+
+// update changes the report and records the change, succeeding like an
+// UPDATE when there is no such report.
+func (r *reportRepository) update(ID string, apply func(*model.Report)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	report, ok := r.s.reports[ID]
+	if !ok {
+		return nil
+	}
+	apply(&report.Report)
+	report.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	r.s.reports[ID] = report
+	r.s.recordChange(ID, model.ChangeUpsert)
+	return nil
+}
+
+func (r *reportRepository) UpdateCount(DB *sql.DB, ID string, Count int) error {
+	return r.update(ID, func(report *model.Report) { report.Count = Count })
+}
+
+func (r *reportRepository) UpdateTitle(DB *sql.DB, ID, Title string) error {
+	return r.update(ID, func(report *model.Report) { report.Title = Title })
+}
+
+func (r *reportRepository) UpdateStyle(DB *sql.DB, ID, Style string) error {
+	return r.update(ID, func(report *model.Report) { report.Style = Style })
+}
+
+func (r *reportRepository) UpdateLanguage(DB *sql.DB, ID, Language string) error {
+	return r.update(ID, func(report *model.Report) { report.Language = Language })
+}
+
+func (r *reportRepository) UpdateVisibility(DB *sql.DB, ID, Visibility string) error {
+	return r.update(ID, func(report *model.Report) { report.Visibility = Visibility })
+}
+
+func (r *reportRepository) GetChanges(DB *sql.DB, OrgID, AuthorID string, Since uint64, Limit int, Settle time.Duration) ([]model.ReportChange, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var changes []model.ReportChange
+	if user, ok := r.s.users[AuthorID]; !ok || user.OrgID != OrgID {
+		return changes, nil
+	}
+	settled := r.s.Now().UTC().Add(-Settle)
+	for _, c := range r.s.changes {
+		if len(changes) == Limit {
+			break
+		}
+		if c.authorID != AuthorID || c.seq <= Since {
+			continue
+		}
+		if c.changedAt.After(settled) {
+			break
+		}
+		change := model.ReportChange{Cursor: c.seq, Operation: c.operation, ReportID: c.reportID, ChangedAt: c.changedAt}
+		if report, ok := r.s.reports[c.reportID]; ok {
+			current := report.Report
+			change.Report = &current
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (r *reportRepository) GetStats(DB *sql.DB, OrgID, AuthorID string, PublicOnly bool, Since time.Time) (model.ReportStats, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stats := model.ReportStats{AuthorID: AuthorID, ByStyle: map[string]int{}, ByLanguage: map[string]int{}}
+	days := make(map[string]*model.ReportActivity)
+	for _, report := range r.s.reports {
+		if report.OrgID != OrgID || report.AuthorID != AuthorID || (PublicOnly && report.Visibility != model.VisibilityPublic) {
+			continue
+		}
+		stats.Reports++
+		stats.TotalCount += report.Count
+		stats.ByStyle[report.Style]++
+		stats.ByLanguage[report.Language]++
+		if report.createdAt.Before(Since) {
+			continue
+		}
+		date := report.createdAt.UTC().Format(time.DateOnly)
+		if days[date] == nil {
+			days[date] = &model.ReportActivity{Date: date}
+		}
+		days[date].Reports++
+		days[date].TotalCount += report.Count
+	}
+	if stats.Reports > 0 {
+		stats.AverageCount = float64(stats.TotalCount) / float64(stats.Reports)
+	}
+	for _, activity := range days {
+		stats.Activity = append(stats.Activity, *activity)
+	}
+	sort.Slice(stats.Activity, func(i, j int) bool { return stats.Activity[i].Date < stats.Activity[j].Date })
+	return stats, nil
+}
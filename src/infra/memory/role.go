@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+
+	"repo-api/src/domain/repository"
+)
+
+func NewRoleRepository(s *Store) repository.IRoleRepository {
+	return &roleRepository{s}
+}
+
+type roleRepository struct{ s *Store }
+
+func (r *roleRepository) GetRole(DB *sql.DB, UserID string) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	role, ok := r.s.roles[UserID]
+	if !ok {
+		return "", fmt.Errorf("user not found")
+	}
+	return role, nil
+}
+
+func (r *roleRepository) UpdateRole(DB *sql.DB, UserID, Role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.roles[UserID]; !ok {
+		return fmt.Errorf("user not found")
+	}
+	r.s.roles[UserID] = Role
+	return nil
+}
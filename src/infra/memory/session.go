@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewSessionRepository(s *Store) repository.ISessionRepository {
+	return &sessionRepository{s}
+}
+
+type sessionRepository struct{ s *Store }
+
+func (r *sessionRepository) GetCredential(DB *sql.DB, UserID string) (model.Credential, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	credential, ok := r.s.credentials[UserID]
+	if !ok {
+		return model.Credential{}, fmt.Errorf("credential not found")
+	}
+	return credential, nil
+}
+
+func (r *sessionRepository) UpsertCredential(DB *sql.DB, UserID, PasswordHash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.credentials[UserID] = model.Credential{UserID: UserID, PasswordHash: PasswordHash, UpdatedAt: time.Now().UTC()}
+	return nil
+}
+
+func (r *sessionRepository) UpdateLoginFailures(DB *sql.DB, UserID string, FailedAttempts int, LockedUntil *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if credential, ok := r.s.credentials[UserID]; ok {
+		credential.FailedAttempts = FailedAttempts
+		credential.LockedUntil = LockedUntil
+		r.s.credentials[UserID] = credential
+	}
+	return nil
+}
+
+func (r *sessionRepository) RecordLoginFailure(DB *sql.DB, UserID string, MaxAttempts int, Now, LockUntil time.Time) (*time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	credential, ok := r.s.credentials[UserID]
+	if !ok {
+		return nil, fmt.Errorf("credential not found")
+	}
+	if credential.LockedUntil != nil && credential.LockedUntil.After(Now) {
+		return credential.LockedUntil, nil
+	}
+	credential.FailedAttempts++
+	credential.LockedUntil = nil
+	if credential.FailedAttempts >= MaxAttempts {
+		credential.LockedUntil = &LockUntil
+		credential.FailedAttempts = 0
+	}
+	r.s.credentials[UserID] = credential
+	return credential.LockedUntil, nil
+}
+
+func (r *sessionRepository) InsertRefreshToken(DB *sql.DB, token model.RefreshToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.refreshTokens[token.ID] = token
+	return nil
+}
+
+func (r *sessionRepository) GetRefreshTokenByHash(DB *sql.DB, Hash string) (model.RefreshToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, token := range r.s.refreshTokens {
+		if token.Hash == Hash {
+			return token, nil
+		}
+	}
+	return model.RefreshToken{}, fmt.Errorf("refresh token not found")
+}
+
+func (r *sessionRepository) RotateRefreshToken(DB *sql.DB, old model.RefreshToken, next model.RefreshToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.refreshTokens[old.ID]
+	if !ok || current.RevokedAt != nil {
+		return fmt.Errorf("refresh token already used")
+	}
+	revokedAt := next.CreatedAt
+	current.RevokedAt = &revokedAt
+	current.ReplacedBy = next.ID
+	r.s.refreshTokens[old.ID] = current
+	r.s.refreshTokens[next.ID] = next
+	return nil
+}
+
+// revokeTokens revokes the unrevoked tokens that match.
+func (r *sessionRepository) revokeTokens(RevokedAt time.Time, match func(model.RefreshToken) bool) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, token := range r.s.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &RevokedAt
+			r.s.refreshTokens[id] = token
+		}
+	}
+}
+
+func (r *sessionRepository) RevokeRefreshFamily(DB *sql.DB, FamilyID string, RevokedAt time.Time) error {
+	r.revokeTokens(RevokedAt, func(token model.RefreshToken) bool { return token.FamilyID == FamilyID })
+	return nil
+}
+
+func (r *sessionRepository) RevokeUserRefreshTokens(DB *sql.DB, UserID string, RevokedAt time.Time) error {
+	r.revokeTokens(RevokedAt, func(token model.RefreshToken) bool { return token.UserID == UserID })
+	return nil
+}
+
+func (r *sessionRepository) IsFamilyActive(DB *sql.DB, FamilyID string, Now time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, token := range r.s.refreshTokens {
+		if token.FamilyID == FamilyID && token.RevokedAt == nil && token.ExpiresAt.After(Now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
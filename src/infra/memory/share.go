@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewShareRepository(s *Store) repository.IShareRepository {
+	return &shareRepository{s}
+}
+
+type shareRepository struct{ s *Store }
+
+func (r *shareRepository) Upsert(DB *sql.DB, share model.ReportShare) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key := [2]string{share.ReportID, share.UserID}
+	if existing, ok := r.s.shares[key]; ok {
+		share.CreatedAt = existing.CreatedAt
+	}
+	r.s.shares[key] = share
+	return nil
+}
+
+func (r *shareRepository) Delete(DB *sql.DB, ReportID, UserID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	key := [2]string{ReportID, UserID}
+	if _, ok := r.s.shares[key]; !ok {
+		return fmt.Errorf("share not found")
+	}
+	delete(r.s.shares, key)
+	return nil
+}
+
+func (r *shareRepository) GetLevel(DB *sql.DB, ReportID, UserID string) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.shares[[2]string{ReportID, UserID}].Level, nil
+}
+
+// sortShares orders shares by when they were granted, like persistence.
+func sortShares(shares []model.ReportShare) {
+	sort.Slice(shares, func(i, j int) bool {
+		if !shares[i].CreatedAt.Equal(shares[j].CreatedAt) {
+			return shares[i].CreatedAt.Before(shares[j].CreatedAt)
+		}
+		return shares[i].ReportID+"\x00"+shares[i].UserID < shares[j].ReportID+"\x00"+shares[j].UserID
+	})
+}
+
+func (r *shareRepository) GetByReportID(DB *sql.DB, ReportID string) ([]model.ReportShare, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	shares := []model.ReportShare{}
+	for _, share := range r.s.shares {
+		if share.ReportID == ReportID {
+			shares = append(shares, share)
+		}
+	}
+	sortShares(shares)
+	return shares, nil
+}
+
+func (r *shareRepository) GetLevelsByUserID(DB *sql.DB, UserID string) (map[string]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	levels := make(map[string]string)
+	for _, share := range r.s.shares {
+		if share.UserID == UserID {
+			levels[share.ReportID] = share.Level
+		}
+	}
+	return levels, nil
+}
+
+func (r *shareRepository) GetSharedWith(DB *sql.DB, UserID string) ([]model.SharedReport, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var shares []model.ReportShare
+	for _, share := range r.s.shares {
+		if _, ok := r.s.reports[share.ReportID]; ok && share.UserID == UserID {
+			shares = append(shares, share)
+		}
+	}
+	sortShares(shares)
+	reports := []model.SharedReport{}
+	for _, share := range shares {
+		reports = append(reports, model.SharedReport{Report: r.s.reports[share.ReportID].Report, Level: share.Level})
+	}
+	return reports, nil
+}
+
+func NewShareLinkRepository(s *Store) repository.IShareLinkRepository {
+	return &shareLinkRepository{s}
+}
+
+type shareLinkRepository struct{ s *Store }
+
+func (r *shareLinkRepository) Insert(DB *sql.DB, link model.ShareLink) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.shareLinks[link.ID] = link
+	return nil
+}
+
+func (r *shareLinkRepository) GetByID(DB *sql.DB, OrgID, ID string) (model.ShareLink, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	link, ok := r.s.shareLinks[ID]
+	if !ok || link.OrgID != OrgID {
+		return model.ShareLink{}, fmt.Errorf("link not found")
+	}
+	return link, nil
+}
+
+func (r *shareLinkRepository) GetForToken(DB *sql.DB, ID string) (model.ShareLink, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	link, ok := r.s.shareLinks[ID]
+	if !ok {
+		return model.ShareLink{}, fmt.Errorf("link not found")
+	}
+	return link, nil
+}
+
+func (r *shareLinkRepository) GetByReportID(DB *sql.DB, ReportID string) ([]model.ShareLink, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	links := []model.ShareLink{}
+	for _, link := range r.s.shareLinks {
+		if link.ReportID == ReportID {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt.After(links[j].CreatedAt) })
+	return links, nil
+}
+
+func (r *shareLinkRepository) Revoke(DB *sql.DB, ID string, RevokedAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if link, ok := r.s.shareLinks[ID]; ok && link.RevokedAt == nil {
+		link.RevokedAt = &RevokedAt
+		r.s.shareLinks[ID] = link
+	}
+	return nil
+}
+
+func (r *shareLinkRepository) MarkUsed(DB *sql.DB, ID string, UsedAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	link, ok := r.s.shareLinks[ID]
+	if !ok || link.UsedAt != nil {
+		return fmt.Errorf("link already used")
+	}
+	link.UsedAt = &UsedAt
+	r.s.shareLinks[ID] = link
+	return nil
+}
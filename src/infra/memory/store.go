@@ -0,0 +1,98 @@
+// Package memory keeps the data of the repositories in the memory of this
+// process, for running the application and its handlers without MySQL in
+// tests. It follows the persistence package closely enough for that, down to
+// the error messages, but has no transactions beyond its single lock, so
+// the same tests also run on MySQL when dbtest.DSNVariable is set.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"repo-api/src/domain/model"
+)
+
+// Store is what the repositories of one Store share, as the tables of one
+// database.
+type Store struct {
+	// Now is the database's clock, which report changes are stamped and
+	// settled by; time.Now unless set.
+	Now func() time.Time
+
+	mu            sync.Mutex
+	organizations map[string]model.Organization
+	users         map[string]model.User
+	roles         map[string]string
+	aliases       map[string]alias
+	credentials   map[string]model.Credential
+	refreshTokens map[string]model.RefreshToken
+	apiKeys       map[string]model.APIKey
+	identities    map[[2]string]model.Identity
+	reports       map[string]report
+	changes       []change
+	shares        map[[2]string]model.ReportShare
+	shareLinks    map[string]model.ShareLink
+	webhooks      map[string]model.Webhook
+	deliveries    map[string]model.WebhookDelivery
+	audit         []model.AuditEntry
+}
+
+type alias struct {
+	userID    string
+	expiresAt time.Time
+}
+
+type report struct {
+	model.Report
+	createdAt time.Time
+}
+
+type change struct {
+	seq       uint64
+	reportID  string
+	authorID  string
+	operation string
+	changedAt time.Time
+}
+
+// NewStore returns an empty store but for the default organization, which
+// the migrations create too.
+func NewStore() *Store {
+	return &Store{
+		Now:           time.Now,
+		organizations: map[string]model.Organization{"default": {ID: "default", Name: "Default"}},
+		users:         make(map[string]model.User),
+		roles:         make(map[string]string),
+		aliases:       make(map[string]alias),
+		credentials:   make(map[string]model.Credential),
+		refreshTokens: make(map[string]model.RefreshToken),
+		apiKeys:       make(map[string]model.APIKey),
+		identities:    make(map[[2]string]model.Identity),
+		reports:       make(map[string]report),
+		shares:        make(map[[2]string]model.ReportShare),
+		shareLinks:    make(map[string]model.ShareLink),
+		webhooks:      make(map[string]model.Webhook),
+		deliveries:    make(map[string]model.WebhookDelivery),
+	}
+}
+
+// recordChange appends an entry for the report to the change log, reading
+// the author from the report like persistence does.
+func (s *Store) recordChange(ID, operation string) {
+	r, ok := s.reports[ID]
+	if !ok {
+		return
+	}
+	s.changes = append(s.changes, change{
+		seq:       uint64(len(s.changes) + 1),
+		reportID:  ID,
+		authorID:  r.AuthorID,
+		operation: operation,
+		changedAt: s.Now().UTC(),
+	})
+}
+
+func (s *Store) recordAudit(entry model.AuditEntry) {
+	entry.ID = uint64(len(s.audit) + 1)
+	s.audit = append(s.audit, entry)
+}
@@ -0,0 +1,419 @@
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewUserRepository(s *Store) repository.IUserRepository {
+	return &userRepository{s}
+}
+
+type userRepository struct{ s *Store }
+
+// insertUser adds the user with the defaults of the users table.
+func (s *Store) insertUser(user model.User) {
+	if user.Status == "" {
+		user.Status = model.UserActive
+	}
+	if user.OrgID == "" {
+		user.OrgID = "default"
+	}
+	user.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	s.users[user.ID] = user
+	s.roles[user.ID] = model.RoleStudent
+}
+
+func (u *userRepository) Insert(DB *sql.DB, user model.User) error {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	if u.s.idTaken(user.ID) {
+		return fmt.Errorf("user with ID %s already exists", user.ID)
+	}
+	u.s.insertUser(user)
+	return nil
+}
+
+func (u *userRepository) InsertBatch(DB *sql.DB, users []model.User) error {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	for _, user := range users {
+		if _, ok := u.s.users[user.ID]; ok {
+			return fmt.Errorf("failed to insert users: duplicate ID %s", user.ID)
+		}
+		if u.s.idTaken(user.ID) {
+			return fmt.Errorf("failed to insert users: ID %s is an alias", user.ID)
+		}
+	}
+	for _, user := range users {
+		u.s.insertUser(model.User{ID: user.ID, Name: user.Name, OrgID: user.OrgID})
+	}
+	return nil
+}
+
+func (u *userRepository) GetByID(DB *sql.DB, OrgID, ID string) (model.User, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	user, ok := u.s.users[ID]
+	if !ok || user.OrgID != OrgID {
+		return model.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (u *userRepository) GetCaller(DB *sql.DB, ID string) (model.User, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	user, ok := u.s.users[ID]
+	if !ok {
+		return model.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (u *userRepository) IDTaken(DB *sql.DB, ID string) (bool, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	return u.s.idTaken(ID), nil
+}
+
+// idTaken is whether ID is a user's or their unexpired alias.
+func (s *Store) idTaken(ID string) bool {
+	if _, ok := s.users[ID]; ok {
+		return true
+	}
+	a, ok := s.aliases[ID]
+	return ok && a.expiresAt.After(s.Now().UTC())
+}
+
+func (u *userRepository) GetByOrgID(DB *sql.DB, OrgID string) ([]model.User, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	var users []model.User
+	for _, user := range u.s.users {
+		if user.OrgID == OrgID {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+// Search matches names regardless of case only, which is enough for tests.
+func (u *userRepository) Search(DB *sql.DB, OrgID string, Query model.UserQuery) ([]model.User, int, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, r := range u.s.reports {
+		public := r.Visibility == model.VisibilityPublic && u.s.users[r.AuthorID].Status != model.UserDeactivated
+		if r.OrgID == OrgID && (Query.ReportsVisibleTo == "" || public || r.AuthorID == Query.ReportsVisibleTo) {
+			counts[r.AuthorID]++
+		}
+	}
+
+	search := strings.ToLower(Query.Search)
+	var matched []model.User
+	for _, user := range u.s.users {
+		name := strings.ToLower(user.Name)
+		switch {
+		case user.OrgID != OrgID:
+			continue
+		case Query.Status != "" && user.Status != Query.Status:
+			continue
+		case Query.Match == model.MatchContains && !strings.Contains(name, search):
+			continue
+		case Query.Match != model.MatchContains && !strings.HasPrefix(name, search):
+			continue
+		}
+		if Query.WithReportCount {
+			count := counts[user.ID]
+			user.ReportCount = &count
+		}
+		matched = append(matched, user)
+	}
+
+	less := func(a, b model.User) bool {
+		switch Query.Sort {
+		case model.UserSortName:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case model.UserSortUpdatedAt:
+			if !a.UpdatedAt.Equal(b.UpdatedAt) {
+				return a.UpdatedAt.Before(b.UpdatedAt)
+			}
+		case model.UserSortReportCount:
+			if counts[a.ID] != counts[b.ID] {
+				return counts[a.ID] < counts[b.ID]
+			}
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if Query.Descending {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	users := []model.User{}
+	for i := Query.Offset; i < len(matched) && len(users) < Query.Limit; i++ {
+		users = append(users, matched[i])
+	}
+	return users, len(matched), nil
+}
+
+func (u *userRepository) UpdateNameByID(DB *sql.DB, ID, Name string) error {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	user, ok := u.s.users[ID]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	user.Name = Name
+	user.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	u.s.users[ID] = user
+	return nil
+}
+
+func (u *userRepository) UpdateProfile(DB *sql.DB, user model.User) error {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	existing, ok := u.s.users[user.ID]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.DisplayName = user.DisplayName
+	existing.Affiliation = user.Affiliation
+	existing.TimeZone = user.TimeZone
+	existing.DefaultStyle = user.DefaultStyle
+	existing.DefaultLanguage = user.DefaultLanguage
+	existing.DefaultCount = user.DefaultCount
+	existing.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	u.s.users[user.ID] = existing
+	return nil
+}
+
+func (u *userRepository) UpdateStatus(DB *sql.DB, Change model.UserStatusChange, Audit model.AuditEntry) (model.UserStatusChange, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	user, ok := u.s.users[Change.UserID]
+	if !ok {
+		return Change, fmt.Errorf("user not found")
+	}
+	Change.From = user.Status
+	user.Status = Change.To
+	u.s.users[Change.UserID] = user
+
+	var err error
+	if Audit.Detail, err = json.Marshal(Change); err != nil {
+		return Change, fmt.Errorf("failed to encode audit detail: %w", err)
+	}
+	u.s.recordAudit(Audit)
+	return Change, nil
+}
+
+func (u *userRepository) Delete(DB *sql.DB, ID string, Deletion model.UserDeletion, Audit model.AuditEntry) ([]model.Report, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	if _, ok := u.s.users[ID]; !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	if Deletion.Policy == model.DeleteReassign {
+		if _, ok := u.s.users[Deletion.ReassignTo]; !ok {
+			return nil, fmt.Errorf("reassign target not found")
+		}
+	}
+
+	var reports []model.Report
+	for _, r := range u.s.reports {
+		if r.AuthorID == ID {
+			reports = append(reports, r.Report)
+		}
+	}
+	if len(reports) > 0 && Deletion.Policy != model.DeleteCascade && Deletion.Policy != model.DeleteReassign {
+		return nil, fmt.Errorf("user has reports")
+	}
+	for _, r := range reports {
+		u.s.recordChange(r.ID, model.ChangeDelete)
+		switch Deletion.Policy {
+		case model.DeleteCascade:
+			u.s.deleteReport(r.ID)
+		case model.DeleteReassign:
+			delete(u.s.shares, [2]string{r.ID, Deletion.ReassignTo})
+			row := u.s.reports[r.ID]
+			row.AuthorID = Deletion.ReassignTo
+			u.s.reports[r.ID] = row
+			u.s.recordChange(r.ID, model.ChangeUpsert)
+		}
+	}
+
+	delete(u.s.credentials, ID)
+	for tokenID, token := range u.s.refreshTokens {
+		if token.UserID == ID {
+			delete(u.s.refreshTokens, tokenID)
+		}
+	}
+	for keyID, key := range u.s.apiKeys {
+		if key.UserID == ID {
+			delete(u.s.apiKeys, keyID)
+		}
+	}
+	for subject, identity := range u.s.identities {
+		if identity.UserID == ID {
+			delete(u.s.identities, subject)
+		}
+	}
+	for key, share := range u.s.shares {
+		if share.UserID == ID {
+			delete(u.s.shares, key)
+		}
+	}
+	for linkID, link := range u.s.shareLinks {
+		if link.CreatedBy == ID {
+			link.CreatedBy = u.s.reports[link.ReportID].AuthorID
+			u.s.shareLinks[linkID] = link
+		}
+	}
+	for oldID, a := range u.s.aliases {
+		if a.userID == ID {
+			delete(u.s.aliases, oldID)
+		}
+	}
+	delete(u.s.users, ID)
+	delete(u.s.roles, ID)
+
+	Deletion.Reports = len(reports)
+	var err error
+	if Audit.Detail, err = json.Marshal(Deletion); err != nil {
+		return nil, fmt.Errorf("failed to encode audit detail: %w", err)
+	}
+	u.s.recordAudit(Audit)
+	return reports, nil
+}
+
+func (u *userRepository) Rename(DB *sql.DB, Rename model.UserRename, Audit model.AuditEntry) error {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	user, ok := u.s.users[Rename.OldID]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	_, taken := u.s.users[Rename.NewID]
+	if a, ok := u.s.aliases[Rename.NewID]; ok && a.userID != Rename.OldID && a.expiresAt.After(time.Now().UTC()) {
+		taken = true
+	}
+	if taken {
+		return fmt.Errorf("user id taken")
+	}
+
+	delete(u.s.aliases, Rename.NewID)
+	delete(u.s.users, Rename.OldID)
+	user.ID = Rename.NewID
+	u.s.users[Rename.NewID] = user
+	u.s.roles[Rename.NewID] = u.s.roles[Rename.OldID]
+	delete(u.s.roles, Rename.OldID)
+	u.s.renameUser(Rename.OldID, Rename.NewID)
+	u.s.aliases[Rename.OldID] = alias{userID: Rename.NewID, expiresAt: Rename.AliasExpiresAt}
+
+	var err error
+	if Audit.Detail, err = json.Marshal(Rename); err != nil {
+		return fmt.Errorf("failed to encode audit detail: %w", err)
+	}
+	u.s.recordAudit(Audit)
+	return nil
+}
+
+// renameUser changes OldID to NewID wherever persistence's renamedColumns
+// are kept.
+func (s *Store) renameUser(OldID, NewID string) {
+	for id, r := range s.reports {
+		if r.AuthorID == OldID {
+			r.AuthorID = NewID
+			s.reports[id] = r
+		}
+	}
+	for i := range s.changes {
+		if s.changes[i].authorID == OldID {
+			s.changes[i].authorID = NewID
+		}
+	}
+	for key, share := range s.shares {
+		if share.GrantedBy == OldID {
+			share.GrantedBy = NewID
+		}
+		if share.UserID == OldID {
+			delete(s.shares, key)
+			share.UserID = NewID
+			key = [2]string{share.ReportID, NewID}
+		}
+		s.shares[key] = share
+	}
+	for id, link := range s.shareLinks {
+		if link.CreatedBy == OldID {
+			link.CreatedBy = NewID
+			s.shareLinks[id] = link
+		}
+	}
+	if credential, ok := s.credentials[OldID]; ok {
+		delete(s.credentials, OldID)
+		credential.UserID = NewID
+		s.credentials[NewID] = credential
+	}
+	for id, token := range s.refreshTokens {
+		if token.UserID == OldID {
+			token.UserID = NewID
+			s.refreshTokens[id] = token
+		}
+	}
+	for id, key := range s.apiKeys {
+		if key.UserID == OldID {
+			key.UserID = NewID
+			s.apiKeys[id] = key
+		}
+	}
+	for subject, identity := range s.identities {
+		if identity.UserID == OldID {
+			identity.UserID = NewID
+			s.identities[subject] = identity
+		}
+	}
+	for old, a := range s.aliases {
+		if a.userID == OldID {
+			a.userID = NewID
+			s.aliases[old] = a
+		}
+	}
+}
+
+func (u *userRepository) GetAlias(DB *sql.DB, ID string, now time.Time) (string, error) {
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	a, ok := u.s.aliases[ID]
+	if !ok || !a.expiresAt.After(now) {
+		return "", fmt.Errorf("alias not found")
+	}
+	return a.userID, nil
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewWebhookRepository(s *Store) repository.IWebhookRepository {
+	return &webhookRepository{s}
+}
+
+type webhookRepository struct{ s *Store }
+
+func (w *webhookRepository) Insert(DB *sql.DB, webhook model.Webhook) error {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	w.s.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (w *webhookRepository) Delete(DB *sql.DB, ID string) error {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	if _, ok := w.s.webhooks[ID]; !ok {
+		return fmt.Errorf("webhook not found")
+	}
+	delete(w.s.webhooks, ID)
+	for deliveryID, delivery := range w.s.deliveries {
+		if delivery.WebhookID == ID {
+			delete(w.s.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (w *webhookRepository) GetByID(DB *sql.DB, OrgID, ID string) (model.Webhook, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	webhook, ok := w.s.webhooks[ID]
+	if !ok || webhook.OrgID != OrgID {
+		return model.Webhook{}, fmt.Errorf("webhook not found")
+	}
+	return webhook, nil
+}
+
+func (w *webhookRepository) GetByOrgID(DB *sql.DB, OrgID string) ([]model.Webhook, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	var webhooks []model.Webhook
+	for _, webhook := range w.s.webhooks {
+		if webhook.OrgID == OrgID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt) })
+	return webhooks, nil
+}
+
+func (w *webhookRepository) InsertDelivery(DB *sql.DB, delivery model.WebhookDelivery) error {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	w.s.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (w *webhookRepository) UpdateDelivery(DB *sql.DB, delivery model.WebhookDelivery) error {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	existing, ok := w.s.deliveries[delivery.ID]
+	if !ok {
+		return nil
+	}
+	existing.Status = delivery.Status
+	existing.Attempts = delivery.Attempts
+	existing.ResponseCode = delivery.ResponseCode
+	existing.Error = delivery.Error
+	existing.NextAttemptAt = delivery.NextAttemptAt
+	existing.UpdatedAt = delivery.UpdatedAt
+	w.s.deliveries[delivery.ID] = existing
+	return nil
+}
+
+func (w *webhookRepository) ClaimDueDeliveries(DB *sql.DB, Now, Until time.Time, Limit int) ([]model.DueDelivery, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	var due []model.DueDelivery
+	for _, delivery := range w.s.deliveries {
+		if delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(Now) {
+			due = append(due, model.DueDelivery{Webhook: w.s.webhooks[delivery.WebhookID], Delivery: delivery})
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Delivery.NextAttemptAt.Before(*due[j].Delivery.NextAttemptAt) })
+	if len(due) > Limit {
+		due = due[:Limit]
+	}
+	for i := range due {
+		due[i].Delivery.NextAttemptAt = &Until
+		w.s.deliveries[due[i].Delivery.ID] = due[i].Delivery
+	}
+	return due, nil
+}
+
+func (w *webhookRepository) GetDeliveryByID(DB *sql.DB, ID string) (model.WebhookDelivery, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	delivery, ok := w.s.deliveries[ID]
+	if !ok {
+		return model.WebhookDelivery{}, fmt.Errorf("delivery not found")
+	}
+	return delivery, nil
+}
+
+func (w *webhookRepository) GetDeliveriesByWebhookID(DB *sql.DB, WebhookID string) ([]model.WebhookDelivery, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	var deliveries []model.WebhookDelivery
+	for _, delivery := range w.s.deliveries {
+		if delivery.WebhookID == WebhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	return deliveries, nil
+}
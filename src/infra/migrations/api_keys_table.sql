@@ -0,0 +1,15 @@
+CREATE TABLE `api_keys` (
+    `id` VARCHAR(255) PRIMARY KEY,
+    `user_id` VARCHAR(255) NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `prefix` VARCHAR(20) NOT NULL,
+    `key_hash` CHAR(64) NOT NULL,
+    `signing_secret` VARCHAR(255) NULL,
+    `scopes` VARCHAR(1024) NOT NULL,
+    `expires_at` DATETIME NULL,
+    `last_used_at` DATETIME NULL,
+    `revoked_at` DATETIME NULL,
+    `created_at` DATETIME NOT NULL,
+    UNIQUE INDEX `idx_api_keys_key_hash` (`key_hash`),
+    INDEX `idx_api_keys_user_id` (`user_id`, `created_at`)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE `audit_log` (
+    `id` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `org_id` VARCHAR(255) NOT NULL,
+    `actor_id` VARCHAR(255) NOT NULL,
+    `action` VARCHAR(50) NOT NULL,
+    `target_id` VARCHAR(255) NOT NULL,
+    `detail` JSON NULL,
+    `created_at` DATETIME NOT NULL,
+    INDEX `idx_audit_log_org_id` (`org_id`, `id`)
+);
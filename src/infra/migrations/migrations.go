@@ -0,0 +1,8 @@
+// Package migrations is the schema, which the database container runs on
+// its first start and tests apply to databases of their own.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var Files embed.FS
@@ -0,0 +1,7 @@
+CREATE TABLE `organizations` (
+    `id` VARCHAR(255) PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO `organizations` (`id`, `name`) VALUES ('default', 'Default');
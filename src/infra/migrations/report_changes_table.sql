@@ -0,0 +1,8 @@
+CREATE TABLE `report_changes` (
+    `seq` BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `report_id` VARCHAR(255) NOT NULL,
+    `author_id` VARCHAR(255) NOT NULL,
+    `operation` VARCHAR(10) NOT NULL,
+    `changed_at` DATETIME NOT NULL,
+    INDEX `idx_report_changes_author_id` (`author_id`, `seq`)
+);
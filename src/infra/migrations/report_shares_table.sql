@@ -0,0 +1,9 @@
+CREATE TABLE `report_shares` (
+    `report_id` VARCHAR(255) NOT NULL,
+    `user_id` VARCHAR(255) NOT NULL,
+    `level` VARCHAR(20) NOT NULL,
+    `granted_by` VARCHAR(255) NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`report_id`, `user_id`),
+    INDEX `idx_report_shares_user_id` (`user_id`)
+);
@@ -1,8 +1,13 @@
 CREATE TABLE `reports` (
     `id` VARCHAR(255) PRIMARY KEY,
     `author_id` VARCHAR(255) NOT NULL,
+    `org_id` VARCHAR(255) NOT NULL DEFAULT 'default',
     `count` INT NOT NULL,
     `title` VARCHAR(255) NOT NULL,
     `style` VARCHAR(100) NOT NULL,
-    `language` VARCHAR(100) NOT NULL
+    `language` VARCHAR(100) NOT NULL,
+    `visibility` VARCHAR(20) NOT NULL DEFAULT 'private',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX `idx_reports_org_id_author_id` (`org_id`, `author_id`)
 );
@@ -0,0 +1,21 @@
+CREATE TABLE `credentials` (
+    `user_id` VARCHAR(255) PRIMARY KEY,
+    `password_hash` VARCHAR(255) NOT NULL,
+    `failed_attempts` INT NOT NULL DEFAULT 0,
+    `locked_until` DATETIME NULL,
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE `refresh_tokens` (
+    `id` VARCHAR(255) PRIMARY KEY,
+    `user_id` VARCHAR(255) NOT NULL,
+    `family_id` VARCHAR(255) NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `expires_at` DATETIME NOT NULL,
+    `revoked_at` DATETIME NULL,
+    `replaced_by` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` DATETIME NOT NULL,
+    UNIQUE INDEX `idx_refresh_tokens_token_hash` (`token_hash`),
+    INDEX `idx_refresh_tokens_family_id` (`family_id`),
+    INDEX `idx_refresh_tokens_user_id` (`user_id`)
+);
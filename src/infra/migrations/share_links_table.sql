@@ -0,0 +1,12 @@
+CREATE TABLE `share_links` (
+    `id` VARCHAR(255) PRIMARY KEY,
+    `report_id` VARCHAR(255) NOT NULL,
+    `org_id` VARCHAR(255) NOT NULL,
+    `created_by` VARCHAR(255) NOT NULL,
+    `single_use` BOOLEAN NOT NULL,
+    `expires_at` DATETIME NOT NULL,
+    `used_at` DATETIME NULL,
+    `revoked_at` DATETIME NULL,
+    `created_at` DATETIME NOT NULL,
+    INDEX `idx_share_links_report_id` (`report_id`, `created_at`)
+);
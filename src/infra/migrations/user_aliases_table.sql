@@ -0,0 +1,6 @@
+CREATE TABLE `user_aliases` (
+    `old_id` VARCHAR(255) PRIMARY KEY,
+    `user_id` VARCHAR(255) NOT NULL,
+    `expires_at` DATETIME NOT NULL,
+    INDEX `idx_user_aliases_user_id` (`user_id`)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE `user_identities` (
+    `issuer` VARCHAR(255) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `user_id` VARCHAR(255) NOT NULL,
+    `email` VARCHAR(255) NOT NULL DEFAULT '',
+    `created_at` DATETIME NOT NULL,
+    PRIMARY KEY (`issuer`, `subject`),
+    INDEX `idx_user_identities_user_id` (`user_id`)
+);
@@ -1,5 +1,19 @@
 CREATE TABLE `users` (
   `id` VARCHAR(255) PRIMARY KEY,
-  `name` VARCHAR(255) NOT NULL
+  `name` VARCHAR(255) NOT NULL,
+  `name_key` VARCHAR(255) COLLATE utf8mb4_bin NOT NULL DEFAULT '',
+  `org_id` VARCHAR(255) NOT NULL DEFAULT 'default',
+  `role` VARCHAR(50) NOT NULL DEFAULT 'student',
+  `status` VARCHAR(20) NOT NULL DEFAULT 'active',
+  `email` VARCHAR(255) NOT NULL DEFAULT '',
+  `display_name` VARCHAR(255) NOT NULL DEFAULT '',
+  `affiliation` VARCHAR(255) NOT NULL DEFAULT '',
+  `time_zone` VARCHAR(64) NOT NULL DEFAULT '',
+  `default_style` VARCHAR(100) NOT NULL DEFAULT '',
+  `default_language` VARCHAR(100) NOT NULL DEFAULT '',
+  `default_count` INT NOT NULL DEFAULT 0,
+  `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX `idx_users_org_id` (`org_id`),
+  INDEX `idx_users_org_id_name_key` (`org_id`, `name_key`)
 );
 
@@ -0,0 +1,26 @@
+CREATE TABLE `webhooks` (
+    `id` VARCHAR(255) PRIMARY KEY,
+    `org_id` VARCHAR(255) NOT NULL DEFAULT 'default',
+    `url` VARCHAR(2048) NOT NULL,
+    `events` VARCHAR(1024) NOT NULL,
+    `secret` VARCHAR(255) NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    INDEX `idx_webhooks_org_id` (`org_id`)
+);
+
+CREATE TABLE `webhook_deliveries` (
+    `id` VARCHAR(255) PRIMARY KEY,
+    `webhook_id` VARCHAR(255) NOT NULL,
+    `event_id` BIGINT UNSIGNED NOT NULL,
+    `event_type` VARCHAR(100) NOT NULL,
+    `payload` TEXT NOT NULL,
+    `status` VARCHAR(20) NOT NULL,
+    `attempts` INT NOT NULL,
+    `response_code` INT NOT NULL,
+    `error` TEXT NOT NULL,
+    `next_attempt_at` DATETIME NULL,
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    INDEX `idx_webhook_deliveries_webhook_id` (`webhook_id`, `created_at`),
+    INDEX `idx_webhook_deliveries_next_attempt_at` (`next_attempt_at`)
+);
@@ -0,0 +1,47 @@
+package nonce
+
+import (
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// MemoryStore is a nonce store local to this process.
+type MemoryStore struct {
+	mu        sync.Mutex
+	expiries  map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		expiries: make(map[string]time.Time),
+	}
+}
+
+func (m *MemoryStore) Claim(key string, expiry, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+	if existing, ok := m.expiries[key]; ok && existing.After(now) {
+		return false, nil
+	}
+	m.expiries[key] = expiry
+	return true, nil
+}
+
+// sweep drops the nonces whose requests would be refused for their
+// timestamp anyway. It runs at most once per sweepInterval.
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, expiry := range m.expiries {
+		if !expiry.After(now) {
+			delete(m.expiries, key)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewAPIKeyPersistence() repository.IAPIKeyRepository {
+	return &apiKeyPersistence{}
+}
+
+type apiKeyPersistence struct{}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, signing_secret, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (model.APIKey, error) {
+	var key model.APIKey
+	var scopes string
+	var secret sql.NullString
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &secret, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt)
+	if err != nil {
+		return key, err
+	}
+	key.Signing = secret.Valid
+	key.Secret = secret.String
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.ExpiresAt = nullTimePtr(expiresAt)
+	key.LastUsedAt = nullTimePtr(lastUsedAt)
+	key.RevokedAt = nullTimePtr(revokedAt)
+	return key, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (a *apiKeyPersistence) Insert(DB *sql.DB, key model.APIKey) error {
+	query := "INSERT INTO api_keys (" + apiKeyColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	var secret sql.NullString
+	if key.Signing {
+		secret = sql.NullString{String: key.Secret, Valid: true}
+	}
+	_, err := DB.Exec(query, key.ID, key.UserID, key.Name, key.Prefix, key.Hash, secret, strings.Join(key.Scopes, ","), key.ExpiresAt, key.LastUsedAt, key.RevokedAt, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert api key: %w", err)
+	}
+	return nil
+}
+
+func (a *apiKeyPersistence) GetByID(DB *sql.DB, ID string) (model.APIKey, error) {
+	key, err := scanAPIKey(DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", ID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return key, fmt.Errorf("api key not found")
+		}
+		return key, fmt.Errorf("failed to get api key by ID: %w", err)
+	}
+	return key, nil
+}
+
+func (a *apiKeyPersistence) GetByHash(DB *sql.DB, Hash string) (model.APIKey, error) {
+	key, err := scanAPIKey(DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", Hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return key, fmt.Errorf("api key not found")
+		}
+		return key, fmt.Errorf("failed to get api key by hash: %w", err)
+	}
+	return key, nil
+}
+
+func (a *apiKeyPersistence) GetByUserID(DB *sql.DB, UserID string) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	rows, err := DB.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY created_at", UserID)
+	if err != nil {
+		return keys, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			log.Println("Error scanning api key:", err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (a *apiKeyPersistence) Revoke(DB *sql.DB, ID string, RevokedAt time.Time) error {
+	result, err := DB.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", RevokedAt, ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("api key not found")
+	}
+	return nil
+}
+
+func (a *apiKeyPersistence) UpdateLastUsed(DB *sql.DB, ID string, LastUsedAt time.Time) error {
+	_, err := DB.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", LastUsedAt, ID)
+	if err != nil {
+		return fmt.Errorf("failed to update api key last use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewAuditPersistence() repository.IAuditRepository {
+	return &auditPersistence{}
+}
+
+type auditPersistence struct{}
+
+// recordAudit appends an entry to the audit log within the transaction of
+// what it records, so that neither is kept without the other.
+func recordAudit(tx *sql.Tx, entry model.AuditEntry) error {
+	var detail interface{}
+	if len(entry.Detail) > 0 {
+		detail = string(entry.Detail)
+	}
+	query := "INSERT INTO audit_log (org_id, actor_id, action, target_id, detail, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	if _, err := tx.Exec(query, entry.OrgID, entry.ActorID, entry.Action, entry.TargetID, detail, entry.CreatedAt); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+func (a *auditPersistence) GetByOrgID(DB *sql.DB, OrgID string, Before uint64, Limit int) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	query := "SELECT id, org_id, actor_id, action, target_id, detail, created_at FROM audit_log WHERE org_id = ?"
+	args := []interface{}{OrgID}
+	if Before != 0 {
+		query += " AND id < ?"
+		args = append(args, Before)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	rows, err := DB.Query(query, append(args, Limit)...)
+	if err != nil {
+		return entries, fmt.Errorf("failed to get audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry model.AuditEntry
+		var detail sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.OrgID, &entry.ActorID, &entry.Action, &entry.TargetID, &detail, &entry.CreatedAt); err != nil {
+			log.Println("Error scanning audit entry:", err)
+			continue
+		}
+		if detail.Valid {
+			entry.Detail = []byte(detail.String)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,36 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewIdentityPersistence() repository.IIdentityRepository {
+	return &identityPersistence{}
+}
+
+type identityPersistence struct{}
+
+func (i *identityPersistence) Insert(DB *sql.DB, identity model.Identity) error {
+	query := "INSERT INTO user_identities (issuer, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?)"
+	if _, err := DB.Exec(query, identity.Issuer, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert identity: %w", err)
+	}
+	return nil
+}
+
+func (i *identityPersistence) GetBySubject(DB *sql.DB, Issuer, Subject string) (model.Identity, error) {
+	var identity model.Identity
+	query := "SELECT issuer, subject, user_id, email, created_at FROM user_identities WHERE issuer = ? AND subject = ?"
+	err := DB.QueryRow(query, Issuer, Subject).Scan(&identity.Issuer, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return identity, fmt.Errorf("identity not found")
+		}
+		return identity, fmt.Errorf("failed to get identity by subject: %w", err)
+	}
+	return identity, nil
+}
@@ -0,0 +1,41 @@
+package persistence
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxNameKeyLength is the length of the name_key column. NFKC can make a
+// name longer, as with ㍿, so keys are cut there.
+const maxNameKeyLength = 255
+
+// nameKey is the form of a name that searches compare: NFKC folds full-width
+// letters and half-width katakana, katakana become hiragana, letters become
+// lower case and spaces are dropped, so that "ﾔﾏﾀﾞ" finds "やまだ" and
+// "山田太郎" finds "山田 太郎".
+func nameKey(name string) string {
+	var b strings.Builder
+	length := 0
+	for _, r := range norm.NFKC.String(name) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if length == maxNameKeyLength {
+			break
+		}
+		// The katakana ァ to ヶ sit 0x60 above their hiragana.
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 0x60
+		}
+		b.WriteRune(unicode.ToLower(r))
+		length++
+	}
+	return b.String()
+}
+
+// likePattern escapes the wildcards of LIKE in s.
+func likePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,64 @@
+package persistence
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameKey(t *testing.T) {
+	cases := []struct {
+		name, key string
+	}{
+		{"やまだ", "やまだ"},
+		{"ヤマダ", "やまだ"},
+		{"ﾔﾏﾀﾞ", "やまだ"},
+		{"ｶﾞｯｺｳ", "がっこう"},
+		{"ＹＡＭＡＤＡ", "yamada"},
+		{"Yamada Taro", "yamadataro"},
+		{"山田 太郎", "山田太郎"},
+		{"山田　太郎\t", "山田太郎"},
+		{"ÉCOLE", "école"},
+		{"㍿", "株式会社"},
+		// ヷ to ヺ have no hiragana.
+		{"ヴ", "ゔ"},
+		{"ヷ", "ヷ"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.key, nameKey(c.name), c.name)
+	}
+}
+
+func TestNameKeyLength(t *testing.T) {
+	key := nameKey(strings.Repeat("ア", maxNameKeyLength+10))
+	assert.Equal(t, maxNameKeyLength, utf8.RuneCountInString(key))
+	assert.Equal(t, strings.Repeat("あ", maxNameKeyLength), key)
+
+	// The cut is after NFKC, which makes ㍿ four runes.
+	key = nameKey(strings.Repeat("㍿", 100))
+	assert.Equal(t, maxNameKeyLength, utf8.RuneCountInString(key))
+	assert.True(t, strings.HasSuffix(key, "株式会"), key)
+
+	// Spaces do not count.
+	key = nameKey(strings.Repeat("ア ", maxNameKeyLength))
+	assert.Equal(t, strings.Repeat("あ", maxNameKeyLength), key)
+}
+
+func TestLikePattern(t *testing.T) {
+	cases := []struct {
+		s, pattern string
+	}{
+		{"やまだ", "やまだ"},
+		{"100%", `100\%`},
+		{"a_b", `a\_b`},
+		{`a\b`, `a\\b`},
+		{`\%_`, `\\\%\_`},
+		{"", ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.pattern, likePattern(c.s), c.s)
+	}
+}
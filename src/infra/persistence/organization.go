@@ -0,0 +1,45 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewOrganizationPersistence() repository.IOrganizationRepository {
+	return &organizationPersistence{}
+}
+
+type organizationPersistence struct{}
+
+func (o *organizationPersistence) Insert(DB *sql.DB, organization model.Organization) error {
+	var existingID string
+	err := DB.QueryRow("SELECT id FROM organizations WHERE id = ?", organization.ID).Scan(&existingID)
+	if existingID != "" {
+		return fmt.Errorf("organization already exists")
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check organization existence: %w", err)
+	}
+
+	query := "INSERT INTO organizations (id, name, created_at) VALUES (?, ?, ?)"
+	if _, err := DB.Exec(query, organization.ID, organization.Name, organization.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert organization: %w", err)
+	}
+	return nil
+}
+
+func (o *organizationPersistence) GetByID(DB *sql.DB, ID string) (model.Organization, error) {
+	var organization model.Organization
+	query := "SELECT id, name, created_at FROM organizations WHERE id = ?"
+	err := DB.QueryRow(query, ID).Scan(&organization.ID, &organization.Name, &organization.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return organization, fmt.Errorf("organization not found")
+		}
+		return organization, fmt.Errorf("failed to get organization by ID: %w", err)
+	}
+	return organization, nil
+}
@@ -6,6 +6,8 @@ import (
     "repo-api/src/domain/repository"
     "log"
     "fmt"
+    "strings"
+    "time"
 )
 
 func NewReportPersistence() repository.IReportRepository {
@@ -14,26 +16,103 @@ func NewReportPersistence() repository.IReportRepository {
 
 type reportPersistence struct{}
 
-func (r *reportPersistence) Insert(DB *sql.DB, ID, AuthorID string, Count int, Title, Style, Language string) error {
-	var authorExists bool
-	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ?"
-	err := DB.QueryRow(authorQuery, AuthorID).Scan(&authorExists)
+// lockAuthor checks that AuthorID is an active user of OrgID, holding a
+// shared lock on the user until tx ends so that the user cannot be deleted
+// or deactivated before the reports being inserted are committed.
+func lockAuthor(tx *sql.Tx, AuthorID, OrgID string) error {
+	var authorStatus string
+	authorQuery := "SELECT status FROM users WHERE id = ? AND org_id = ? FOR SHARE"
+	err := tx.QueryRow(authorQuery, AuthorID, OrgID).Scan(&authorStatus)
+	if err == sql.ErrNoRows {
+	    return fmt.Errorf("author does not exist")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to check author existence: %w", err)
 	}
-	if !authorExists {
-	    return fmt.Errorf("author does not exist")
+	if authorStatus != model.UserActive {
+	    return fmt.Errorf("author not active")
+	}
+	return nil
+}
+
+func (r *reportPersistence) Insert(DB *sql.DB, ID, AuthorID, OrgID string, Count int, Title, Style, Language, Visibility string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	query := "INSERT INTO reports (id, author_id, count, title, style, language) VALUES (?, ?, ?, ?, ?, ?)"
-	_, err = DB.Exec(query, ID, AuthorID, Count, Title, Style, Language)
+	if err := lockAuthor(tx, AuthorID, OrgID); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO reports (id, author_id, org_id, count, title, style, language, visibility) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = tx.Exec(query, ID, AuthorID, OrgID, Count, Title, Style, Language, Visibility)
 	if err != nil {
 		return fmt.Errorf("failed to insert report: %w", err)
 	}
 
+	if err := recordChange(tx, ID, model.ChangeUpsert); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit report: %w", err)
+	}
 	return nil
 }
 
+func (r *reportPersistence) InsertBatch(DB *sql.DB, reports []model.Report) error {
+    if len(reports) == 0 {
+        return nil
+    }
+
+    placeholders := make([]string, 0, len(reports))
+    args := make([]interface{}, 0, len(reports)*8)
+    for _, report := range reports {
+        placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?)")
+        args = append(args, report.ID, report.AuthorID, report.OrgID, report.Count, report.Title, report.Style, report.Language, report.Visibility)
+    }
+
+    tx, err := DB.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    locked := make(map[string]bool)
+    for _, report := range reports {
+        if locked[report.AuthorID] {
+            continue
+        }
+        if err := lockAuthor(tx, report.AuthorID, report.OrgID); err != nil {
+            return fmt.Errorf("author %s: %w", report.AuthorID, err)
+        }
+        locked[report.AuthorID] = true
+    }
+
+    query := "INSERT INTO reports (id, author_id, org_id, count, title, style, language, visibility) VALUES " + strings.Join(placeholders, ", ")
+    if _, err := tx.Exec(query, args...); err != nil {
+        return fmt.Errorf("failed to insert reports: %w", err)
+    }
+
+    changePlaceholders := make([]string, 0, len(reports))
+    changeArgs := make([]interface{}, 0, len(reports)*3)
+    for _, report := range reports {
+        changePlaceholders = append(changePlaceholders, "(?, ?, ?, UTC_TIMESTAMP())")
+        changeArgs = append(changeArgs, report.ID, report.AuthorID, model.ChangeUpsert)
+    }
+    changeQuery := "INSERT INTO report_changes (report_id, author_id, operation, changed_at) VALUES " + strings.Join(changePlaceholders, ", ")
+    if _, err := tx.Exec(changeQuery, changeArgs...); err != nil {
+        return fmt.Errorf("failed to record report changes: %w", err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit reports: %w", err)
+    }
+    return nil
+}
+
 func (r *reportPersistence) Eject(DB *sql.DB, ID string) error { 
     var existingID string
     checkQuery := "SELECT id FROM reports WHERE id = ?"
@@ -45,18 +124,48 @@ func (r *reportPersistence) Eject(DB *sql.DB, ID string) error {
         return fmt.Errorf("failed to check report existence: %w", err)
     }
     
+    tx, err := DB.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if err := recordChange(tx, ID, model.ChangeDelete); err != nil {
+        return err
+    }
+
     query := "DELETE FROM reports WHERE id = ?"
-    _, err = DB.Exec(query, ID)
+    _, err = tx.Exec(query, ID)
     if err != nil {
         return fmt.Errorf("failed to delete report: %w", err)
     }
+
+    if _, err := tx.Exec("DELETE FROM report_shares WHERE report_id = ?", ID); err != nil {
+        return fmt.Errorf("failed to delete report shares: %w", err)
+    }
+
+    if _, err := tx.Exec("DELETE FROM share_links WHERE report_id = ?", ID); err != nil {
+        return fmt.Errorf("failed to delete share links: %w", err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit report deletion: %w", err)
+    }
     return nil
 }
 
-func (r *reportPersistence) GetByID(DB *sql.DB, ID string) (model.Report, error) {
+func (r *reportPersistence) IDTaken(DB *sql.DB, ID string) (bool, error) {
+    var taken bool
+    if err := DB.QueryRow("SELECT COUNT(*) > 0 FROM reports WHERE id = ?", ID).Scan(&taken); err != nil {
+        return false, fmt.Errorf("failed to check report existence: %w", err)
+    }
+    return taken, nil
+}
+
+func (r *reportPersistence) GetByID(DB *sql.DB, OrgID, ID string) (model.Report, error) {
     var report model.Report
-    query := "SELECT id, author_id, count, title, style, language FROM reports WHERE id = ?"
-    err := DB.QueryRow(query, ID).Scan(&report.ID, &report.AuthorID, &report.Count, &report.Title, &report.Style, &report.Language)
+    query := "SELECT id, author_id, org_id, count, title, style, language, visibility, updated_at FROM reports WHERE id = ? AND org_id = ?"
+    err := DB.QueryRow(query, ID, OrgID).Scan(&report.ID, &report.AuthorID, &report.OrgID, &report.Count, &report.Title, &report.Style, &report.Language, &report.Visibility, &report.UpdatedAt)
     if err != nil {
         if err == sql.ErrNoRows {
             return report, nil  
@@ -66,12 +175,12 @@ func (r *reportPersistence) GetByID(DB *sql.DB, ID string) (model.Report, error)
     return report, nil
 }
 
-func (r *reportPersistence) GetByAuthorID(DB *sql.DB, AuthorID string) ([]model.Report, error) {
+func (r *reportPersistence) GetByAuthorID(DB *sql.DB, OrgID, AuthorID string) ([]model.Report, error) {
     var reports []model.Report
     
 	var authorExists bool
-	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ?"
-	err := DB.QueryRow(authorQuery, AuthorID).Scan(&authorExists)
+	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ? AND org_id = ?"
+	err := DB.QueryRow(authorQuery, AuthorID, OrgID).Scan(&authorExists)
 	if err != nil {
 		return reports, fmt.Errorf("failed to check author existence: %w", err)
 	}
@@ -79,8 +188,8 @@ func (r *reportPersistence) GetByAuthorID(DB *sql.DB, AuthorID string) ([]model.
 	    return reports, fmt.Errorf("author does not exist")
 	}
 
-    query := "SELECT id, author_id, count, title, style, language FROM reports WHERE author_id = ?"
-    rows, err := DB.Query(query, AuthorID)
+    query := "SELECT id, author_id, org_id, count, title, style, language, visibility, updated_at FROM reports WHERE org_id = ? AND author_id = ?"
+    rows, err := DB.Query(query, OrgID, AuthorID)
     if err != nil {
         return reports, fmt.Errorf("failed to get reports by AuthorID: %w", err)
     }
@@ -88,7 +197,7 @@ func (r *reportPersistence) GetByAuthorID(DB *sql.DB, AuthorID string) ([]model.
 
     for rows.Next() {
         var report model.Report
-        err := rows.Scan(&report.ID, &report.AuthorID, &report.Count, &report.Title, &report.Style, &report.Language)
+        err := rows.Scan(&report.ID, &report.AuthorID, &report.OrgID, &report.Count, &report.Title, &report.Style, &report.Language, &report.Visibility, &report.UpdatedAt)
         if err != nil {
             log.Println("Error scanning report:", err)
             continue
@@ -98,12 +207,12 @@ func (r *reportPersistence) GetByAuthorID(DB *sql.DB, AuthorID string) ([]model.
     return reports, nil
 }
 
-func (r *reportPersistence) GetByTitle(DB *sql.DB, AuthorID, Title string) ([]model.Report, error) {
+func (r *reportPersistence) GetByTitle(DB *sql.DB, OrgID, AuthorID, Title string) ([]model.Report, error) {
     var reports []model.Report
     
 	var authorExists bool
-	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ?"
-	err := DB.QueryRow(authorQuery, AuthorID).Scan(&authorExists)
+	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ? AND org_id = ?"
+	err := DB.QueryRow(authorQuery, AuthorID, OrgID).Scan(&authorExists)
 	if err != nil {
 		return reports, fmt.Errorf("failed to check author existence: %w", err)
 	}
@@ -111,8 +220,8 @@ func (r *reportPersistence) GetByTitle(DB *sql.DB, AuthorID, Title string) ([]mo
 	    return reports, fmt.Errorf("author does not exist")
 	}
 
-    query := "SELECT id, author_id, count, title, style, language FROM reports WHERE author_id = ? AND title = ?"
-    rows, err := DB.Query(query, AuthorID, Title)
+    query := "SELECT id, author_id, org_id, count, title, style, language, visibility, updated_at FROM reports WHERE org_id = ? AND author_id = ? AND title = ?"
+    rows, err := DB.Query(query, OrgID, AuthorID, Title)
     if err != nil {
         return reports, fmt.Errorf("failed to get reports by Title: %w", err)
     }
@@ -120,7 +229,7 @@ func (r *reportPersistence) GetByTitle(DB *sql.DB, AuthorID, Title string) ([]mo
 
     for rows.Next() {
         var report model.Report
-        err := rows.Scan(&report.ID, &report.AuthorID, &report.Count, &report.Title, &report.Style, &report.Language)
+        err := rows.Scan(&report.ID, &report.AuthorID, &report.OrgID, &report.Count, &report.Title, &report.Style, &report.Language, &report.Visibility, &report.UpdatedAt)
         if err != nil {
             log.Println("Error scanning report:", err)
             continue
@@ -130,12 +239,12 @@ func (r *reportPersistence) GetByTitle(DB *sql.DB, AuthorID, Title string) ([]mo
     return reports, nil
 }
 
-func (r *reportPersistence) GetByStyle(DB *sql.DB, AuthorID, Style string) ([]model.Report, error) {
+func (r *reportPersistence) GetByStyle(DB *sql.DB, OrgID, AuthorID, Style string) ([]model.Report, error) {
     var reports []model.Report
     
 	var authorExists bool
-	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ?"
-	err := DB.QueryRow(authorQuery, AuthorID).Scan(&authorExists)
+	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ? AND org_id = ?"
+	err := DB.QueryRow(authorQuery, AuthorID, OrgID).Scan(&authorExists)
 	if err != nil {
 		return reports, fmt.Errorf("failed to check author existence: %w", err)
 	}
@@ -143,8 +252,8 @@ func (r *reportPersistence) GetByStyle(DB *sql.DB, AuthorID, Style string) ([]mo
 	    return reports, fmt.Errorf("author does not exist")
 	}
     
-    query := "SELECT id, author_id, count, title, style, language FROM reports WHERE author_id = ? AND style = ?"
-    rows, err := DB.Query(query, AuthorID, Style)
+    query := "SELECT id, author_id, org_id, count, title, style, language, visibility, updated_at FROM reports WHERE org_id = ? AND author_id = ? AND style = ?"
+    rows, err := DB.Query(query, OrgID, AuthorID, Style)
     if err != nil {
         return reports, fmt.Errorf("failed to get reports by Style: %w", err)
     }
@@ -152,7 +261,7 @@ func (r *reportPersistence) GetByStyle(DB *sql.DB, AuthorID, Style string) ([]mo
 
     for rows.Next() {
         var report model.Report
-        err := rows.Scan(&report.ID, &report.AuthorID, &report.Count, &report.Title, &report.Style, &report.Language)
+        err := rows.Scan(&report.ID, &report.AuthorID, &report.OrgID, &report.Count, &report.Title, &report.Style, &report.Language, &report.Visibility, &report.UpdatedAt)
         if err != nil {
             log.Println("Error scanning report:", err)
             continue
@@ -162,12 +271,12 @@ func (r *reportPersistence) GetByStyle(DB *sql.DB, AuthorID, Style string) ([]mo
     return reports, nil
 }
 
-func (r *reportPersistence) GetByLanguage(DB *sql.DB, AuthorID, Language string) ([]model.Report, error) {
+func (r *reportPersistence) GetByLanguage(DB *sql.DB, OrgID, AuthorID, Language string) ([]model.Report, error) {
     var reports []model.Report
     
 	var authorExists bool
-	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ?"
-	err := DB.QueryRow(authorQuery, AuthorID).Scan(&authorExists)
+	authorQuery := "SELECT COUNT(*) > 0 FROM users WHERE id = ? AND org_id = ?"
+	err := DB.QueryRow(authorQuery, AuthorID, OrgID).Scan(&authorExists)
 	if err != nil {
 		return reports, fmt.Errorf("failed to check author existence: %w", err)
 	}
@@ -175,8 +284,8 @@ func (r *reportPersistence) GetByLanguage(DB *sql.DB, AuthorID, Language string)
 	    return reports, fmt.Errorf("author does not exist")
 	}
 
-    query := "SELECT id, author_id, count, title, style, language FROM reports WHERE author_id = ? AND language = ?"
-    rows, err := DB.Query(query, AuthorID, Language)
+    query := "SELECT id, author_id, org_id, count, title, style, language, visibility, updated_at FROM reports WHERE org_id = ? AND author_id = ? AND language = ?"
+    rows, err := DB.Query(query, OrgID, AuthorID, Language)
     if err != nil {
         return reports, fmt.Errorf("failed to get reports by Language: %w", err)
     }
@@ -184,7 +293,7 @@ func (r *reportPersistence) GetByLanguage(DB *sql.DB, AuthorID, Language string)
 
     for rows.Next() {
         var report model.Report
-        err := rows.Scan(&report.ID, &report.AuthorID, &report.Count, &report.Title, &report.Style, &report.Language)
+        err := rows.Scan(&report.ID, &report.AuthorID, &report.OrgID, &report.Count, &report.Title, &report.Style, &report.Language, &report.Visibility, &report.UpdatedAt)
         if err != nil {
             log.Println("Error scanning report:", err)
             continue
@@ -196,37 +305,152 @@ func (r *reportPersistence) GetByLanguage(DB *sql.DB, AuthorID, Language string)
 
 func (r *reportPersistence) UpdateCount(DB *sql.DB, ID string, Count int) error {
     query := "UPDATE reports SET count = ? WHERE id = ?"
-    _, err := DB.Exec(query, Count, ID)
-    if err != nil {
-        return fmt.Errorf("failed to update report count: %w", err)
-    }
-    return nil
+    return updateReport(DB, ID, query, Count)
 }
 
 func (r *reportPersistence) UpdateTitle(DB *sql.DB, ID, Title string) error {
     query := "UPDATE reports SET title = ? WHERE id = ?"
-    _, err := DB.Exec(query, Title, ID)
-    if err != nil {
-        return fmt.Errorf("failed to update report title: %w", err)
-    }
-    return nil
+    return updateReport(DB, ID, query, Title)
 }
 
 func (r *reportPersistence) UpdateStyle(DB *sql.DB, ID, Style string) error {
     query := "UPDATE reports SET style = ? WHERE id = ?"
-    _, err := DB.Exec(query, Style, ID)
-    if err != nil {
-        return fmt.Errorf("failed to update report style: %w", err)
-    }
-    return nil
+    return updateReport(DB, ID, query, Style)
 }
 
 func (r *reportPersistence) UpdateLanguage(DB *sql.DB, ID, Language string) error {
     query := "UPDATE reports SET language = ? WHERE id = ?"
-    _, err := DB.Exec(query, Language, ID)
+    return updateReport(DB, ID, query, Language)
+}
+
+func (r *reportPersistence) UpdateVisibility(DB *sql.DB, ID, Visibility string) error {
+    query := "UPDATE reports SET visibility = ? WHERE id = ?"
+    return updateReport(DB, ID, query, Visibility)
+}
+
+// updateReport runs a single-column UPDATE and records it in the change log
+// within the same transaction.
+func updateReport(DB *sql.DB, ID, query string, value interface{}) error {
+    tx, err := DB.Begin()
     if err != nil {
-        return fmt.Errorf("failed to update report language: %w", err)
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec(query, value, ID); err != nil {
+        return fmt.Errorf("failed to update report: %w", err)
+    }
+
+    if err := recordChange(tx, ID, model.ChangeUpsert); err != nil {
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit report update: %w", err)
     }
     return nil
 }
 
+// recordChange appends an entry for the report to the change log. It reads
+// the author from the reports row, so deletions must be recorded before the
+// row is removed. changed_at is the database's time, which GetChanges
+// compares it with.
+func recordChange(tx *sql.Tx, ID, operation string) error {
+    query := "INSERT INTO report_changes (report_id, author_id, operation, changed_at) SELECT id, author_id, ?, UTC_TIMESTAMP() FROM reports WHERE id = ?"
+    if _, err := tx.Exec(query, operation, ID); err != nil {
+        return fmt.Errorf("failed to record report change: %w", err)
+    }
+    return nil
+}
+
+// GetChanges stops at the first change that has not settled, rather than
+// skipping it: a change with a lower seq may be recorded a moment later, and
+// one still uncommitted is not visible here at all. Waiting for Settle covers
+// both, as long as transactions commit within it.
+func (r *reportPersistence) GetChanges(DB *sql.DB, OrgID, AuthorID string, Since uint64, Limit int, Settle time.Duration) ([]model.ReportChange, error) {
+    var changes []model.ReportChange
+    query := `SELECT c.seq, c.report_id, c.operation, c.changed_at, r.id, r.author_id, r.count, r.title, r.style, r.language, r.visibility
+        FROM report_changes c JOIN users u ON u.id = c.author_id AND u.org_id = ? LEFT JOIN reports r ON r.id = c.report_id
+        WHERE c.author_id = ? AND c.seq > ? AND c.seq < COALESCE((
+            SELECT MIN(n.seq) FROM report_changes n
+            WHERE n.author_id = ? AND n.seq > ? AND n.changed_at > UTC_TIMESTAMP() - INTERVAL ? SECOND), ~0)
+        ORDER BY c.seq LIMIT ?`
+    settle := int64(Settle / time.Second)
+    rows, err := DB.Query(query, OrgID, AuthorID, Since, AuthorID, Since, settle, Limit)
+    if err != nil {
+        return changes, fmt.Errorf("failed to get report changes: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var change model.ReportChange
+        var id, authorID, title, style, language, visibility sql.NullString
+        var count sql.NullInt64
+        err := rows.Scan(&change.Cursor, &change.ReportID, &change.Operation, &change.ChangedAt, &id, &authorID, &count, &title, &style, &language, &visibility)
+        if err != nil {
+            return changes, fmt.Errorf("failed to scan report change: %w", err)
+        }
+        if id.Valid {
+            change.Report = &model.Report{ID: id.String, AuthorID: authorID.String, OrgID: OrgID, Count: int(count.Int64), Title: title.String, Style: style.String, Language: language.String, Visibility: visibility.String}
+        }
+        changes = append(changes, change)
+    }
+    return changes, nil
+}
+
+func (r *reportPersistence) GetStats(DB *sql.DB, OrgID, AuthorID string, PublicOnly bool, Since time.Time) (model.ReportStats, error) {
+    stats := model.ReportStats{AuthorID: AuthorID, ByStyle: map[string]int{}, ByLanguage: map[string]int{}}
+    scope := " FROM reports WHERE org_id = ? AND author_id = ?"
+    args := []interface{}{OrgID, AuthorID}
+    if PublicOnly {
+        scope += " AND visibility = ?"
+        args = append(args, model.VisibilityPublic)
+    }
+
+    err := DB.QueryRow("SELECT COUNT(*), COALESCE(SUM(count), 0)"+scope, args...).Scan(&stats.Reports, &stats.TotalCount)
+    if err != nil {
+        return stats, fmt.Errorf("failed to count reports: %w", err)
+    }
+    if stats.Reports > 0 {
+        stats.AverageCount = float64(stats.TotalCount) / float64(stats.Reports)
+    }
+
+    query := "SELECT 'style', style, COUNT(*)" + scope + " GROUP BY style UNION ALL SELECT 'language', language, COUNT(*)" + scope + " GROUP BY language"
+    rows, err := DB.Query(query, append(append([]interface{}{}, args...), args...)...)
+    if err != nil {
+        return stats, fmt.Errorf("failed to group reports: %w", err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var kind, value string
+        var reports int
+        if err := rows.Scan(&kind, &value, &reports); err != nil {
+            return stats, fmt.Errorf("failed to scan report group: %w", err)
+        }
+        if kind == "style" {
+            stats.ByStyle[value] = reports
+        } else {
+            stats.ByLanguage[value] = reports
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return stats, fmt.Errorf("failed to group reports: %w", err)
+    }
+
+    query = "SELECT DATE(created_at), COUNT(*), SUM(count)" + scope + " AND created_at >= ? GROUP BY DATE(created_at) ORDER BY DATE(created_at)"
+    rows, err = DB.Query(query, append(args, Since.UTC())...)
+    if err != nil {
+        return stats, fmt.Errorf("failed to get report activity: %w", err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var day time.Time
+        var activity model.ReportActivity
+        if err := rows.Scan(&day, &activity.Reports, &activity.TotalCount); err != nil {
+            return stats, fmt.Errorf("failed to scan report activity: %w", err)
+        }
+        activity.Date = day.Format(time.DateOnly)
+        stats.Activity = append(stats.Activity, activity)
+    }
+    return stats, rows.Err()
+}
@@ -0,0 +1,43 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+
+	"repo-api/src/domain/repository"
+)
+
+func NewRolePersistence() repository.IRoleRepository {
+	return &rolePersistence{}
+}
+
+type rolePersistence struct{}
+
+func (r *rolePersistence) GetRole(DB *sql.DB, UserID string) (string, error) {
+	var role string
+	err := DB.QueryRow("SELECT role FROM users WHERE id = ?", UserID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("user not found")
+		}
+		return "", fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+func (r *rolePersistence) UpdateRole(DB *sql.DB, UserID, Role string) error {
+	result, err := DB.Exec("UPDATE users SET role = ? WHERE id = ?", Role, UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		var exists bool
+		if err := DB.QueryRow("SELECT COUNT(*) > 0 FROM users WHERE id = ?", UserID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check user existence: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("user not found")
+		}
+	}
+	return nil
+}
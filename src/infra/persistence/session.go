@@ -0,0 +1,165 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewSessionPersistence() repository.ISessionRepository {
+	return &sessionPersistence{}
+}
+
+type sessionPersistence struct{}
+
+func (s *sessionPersistence) GetCredential(DB *sql.DB, UserID string) (model.Credential, error) {
+	var credential model.Credential
+	var lockedUntil sql.NullTime
+	query := "SELECT user_id, password_hash, failed_attempts, locked_until, updated_at FROM credentials WHERE user_id = ?"
+	err := DB.QueryRow(query, UserID).Scan(&credential.UserID, &credential.PasswordHash, &credential.FailedAttempts, &lockedUntil, &credential.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return credential, fmt.Errorf("credential not found")
+		}
+		return credential, fmt.Errorf("failed to get credential: %w", err)
+	}
+	credential.LockedUntil = nullTimePtr(lockedUntil)
+	return credential, nil
+}
+
+// UpsertCredential sets the password and clears any lockout.
+func (s *sessionPersistence) UpsertCredential(DB *sql.DB, UserID, PasswordHash string) error {
+	query := "INSERT INTO credentials (user_id, password_hash) VALUES (?, ?) ON DUPLICATE KEY UPDATE password_hash = VALUES(password_hash), failed_attempts = 0, locked_until = NULL"
+	if _, err := DB.Exec(query, UserID, PasswordHash); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+	return nil
+}
+
+func (s *sessionPersistence) UpdateLoginFailures(DB *sql.DB, UserID string, FailedAttempts int, LockedUntil *time.Time) error {
+	query := "UPDATE credentials SET failed_attempts = ?, locked_until = ? WHERE user_id = ?"
+	if _, err := DB.Exec(query, FailedAttempts, LockedUntil, UserID); err != nil {
+		return fmt.Errorf("failed to update login failures: %w", err)
+	}
+	return nil
+}
+
+func (s *sessionPersistence) RecordLoginFailure(DB *sql.DB, UserID string, MaxAttempts int, Now, LockUntil time.Time) (*time.Time, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var attempts int
+	var lockedUntil sql.NullTime
+	query := "SELECT failed_attempts, locked_until FROM credentials WHERE user_id = ? FOR UPDATE"
+	if err := tx.QueryRow(query, UserID).Scan(&attempts, &lockedUntil); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("credential not found")
+		}
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	// A parallel failure may have locked the credential since it was read.
+	if lockedUntil.Valid && lockedUntil.Time.After(Now) {
+		return &lockedUntil.Time, nil
+	}
+
+	attempts++
+	var locked *time.Time
+	if attempts >= MaxAttempts {
+		locked = &LockUntil
+		attempts = 0
+	}
+	if _, err := tx.Exec("UPDATE credentials SET failed_attempts = ?, locked_until = ? WHERE user_id = ?", attempts, locked, UserID); err != nil {
+		return nil, fmt.Errorf("failed to update login failures: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit login failure: %w", err)
+	}
+	return locked, nil
+}
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at"
+
+func (s *sessionPersistence) InsertRefreshToken(DB *sql.DB, token model.RefreshToken) error {
+	query := "INSERT INTO refresh_tokens (" + refreshTokenColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := DB.Exec(query, token.ID, token.UserID, token.FamilyID, token.Hash, token.ExpiresAt, token.RevokedAt, token.ReplacedBy, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *sessionPersistence) GetRefreshTokenByHash(DB *sql.DB, Hash string) (model.RefreshToken, error) {
+	var token model.RefreshToken
+	var revokedAt sql.NullTime
+	query := "SELECT " + refreshTokenColumns + " FROM refresh_tokens WHERE token_hash = ?"
+	err := DB.QueryRow(query, Hash).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.Hash, &token.ExpiresAt, &revokedAt, &token.ReplacedBy, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return token, fmt.Errorf("refresh token not found")
+		}
+		return token, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	token.RevokedAt = nullTimePtr(revokedAt)
+	return token, nil
+}
+
+func (s *sessionPersistence) RotateRefreshToken(DB *sql.DB, old model.RefreshToken, next model.RefreshToken) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = ?, replaced_by = ? WHERE id = ? AND revoked_at IS NULL", next.CreatedAt, next.ID, old.ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("refresh token already used")
+	}
+
+	query := "INSERT INTO refresh_tokens (" + refreshTokenColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	if _, err := tx.Exec(query, next.ID, next.UserID, next.FamilyID, next.Hash, next.ExpiresAt, next.RevokedAt, next.ReplacedBy, next.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit refresh token rotation: %w", err)
+	}
+	return nil
+}
+
+func (s *sessionPersistence) RevokeRefreshFamily(DB *sql.DB, FamilyID string, RevokedAt time.Time) error {
+	_, err := DB.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", RevokedAt, FamilyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (s *sessionPersistence) RevokeUserRefreshTokens(DB *sql.DB, UserID string, RevokedAt time.Time) error {
+	_, err := DB.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", RevokedAt, UserID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+func (s *sessionPersistence) IsFamilyActive(DB *sql.DB, FamilyID string, Now time.Time) (bool, error) {
+	var exists int
+	query := "SELECT 1 FROM refresh_tokens WHERE family_id = ? AND revoked_at IS NULL AND expires_at > ? LIMIT 1"
+	err := DB.QueryRow(query, FamilyID, Now).Scan(&exists)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return true, nil
+}
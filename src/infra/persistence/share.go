@@ -0,0 +1,118 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewSharePersistence() repository.IShareRepository {
+	return &sharePersistence{}
+}
+
+type sharePersistence struct{}
+
+// Upsert grants the share or changes the level of an existing one.
+func (s *sharePersistence) Upsert(DB *sql.DB, share model.ReportShare) error {
+	query := "INSERT INTO report_shares (report_id, user_id, level, granted_by, created_at) VALUES (?, ?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE level = VALUES(level), granted_by = VALUES(granted_by)"
+	_, err := DB.Exec(query, share.ReportID, share.UserID, share.Level, share.GrantedBy, share.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert report share: %w", err)
+	}
+	return nil
+}
+
+func (s *sharePersistence) Delete(DB *sql.DB, ReportID, UserID string) error {
+	result, err := DB.Exec("DELETE FROM report_shares WHERE report_id = ? AND user_id = ?", ReportID, UserID)
+	if err != nil {
+		return fmt.Errorf("failed to delete report share: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete report share: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("share not found")
+	}
+	return nil
+}
+
+// GetLevel returns "" when the report is not shared with the user.
+func (s *sharePersistence) GetLevel(DB *sql.DB, ReportID, UserID string) (string, error) {
+	var level string
+	err := DB.QueryRow("SELECT level FROM report_shares WHERE report_id = ? AND user_id = ?", ReportID, UserID).Scan(&level)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get report share level: %w", err)
+	}
+	return level, nil
+}
+
+func (s *sharePersistence) GetByReportID(DB *sql.DB, ReportID string) ([]model.ReportShare, error) {
+	shares := []model.ReportShare{}
+	query := "SELECT report_id, user_id, level, granted_by, created_at FROM report_shares WHERE report_id = ? ORDER BY created_at, user_id"
+	rows, err := DB.Query(query, ReportID)
+	if err != nil {
+		return shares, fmt.Errorf("failed to get report shares: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var share model.ReportShare
+		if err := rows.Scan(&share.ReportID, &share.UserID, &share.Level, &share.GrantedBy, &share.CreatedAt); err != nil {
+			log.Println("Error scanning report share:", err)
+			continue
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// GetLevelsByUserID maps the IDs of the reports shared with the user to
+// their levels.
+func (s *sharePersistence) GetLevelsByUserID(DB *sql.DB, UserID string) (map[string]string, error) {
+	levels := make(map[string]string)
+	rows, err := DB.Query("SELECT report_id, level FROM report_shares WHERE user_id = ?", UserID)
+	if err != nil {
+		return levels, fmt.Errorf("failed to get report share levels: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reportID, level string
+		if err := rows.Scan(&reportID, &level); err != nil {
+			log.Println("Error scanning report share:", err)
+			continue
+		}
+		levels[reportID] = level
+	}
+	return levels, rows.Err()
+}
+
+func (s *sharePersistence) GetSharedWith(DB *sql.DB, UserID string) ([]model.SharedReport, error) {
+	reports := []model.SharedReport{}
+	query := "SELECT r.id, r.author_id, r.org_id, r.count, r.title, r.style, r.language, r.visibility, r.updated_at, s.level " +
+		"FROM report_shares s JOIN reports r ON r.id = s.report_id WHERE s.user_id = ? ORDER BY s.created_at, r.id"
+	rows, err := DB.Query(query, UserID)
+	if err != nil {
+		return reports, fmt.Errorf("failed to get reports shared with user: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report model.SharedReport
+		err := rows.Scan(&report.ID, &report.AuthorID, &report.OrgID, &report.Count, &report.Title, &report.Style, &report.Language, &report.Visibility, &report.UpdatedAt, &report.Level)
+		if err != nil {
+			log.Println("Error scanning shared report:", err)
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
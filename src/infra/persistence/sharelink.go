@@ -0,0 +1,99 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewShareLinkPersistence() repository.IShareLinkRepository {
+	return &shareLinkPersistence{}
+}
+
+type shareLinkPersistence struct{}
+
+const shareLinkColumns = "id, report_id, org_id, created_by, single_use, expires_at, used_at, revoked_at, created_at"
+
+func scanShareLink(row rowScanner) (model.ShareLink, error) {
+	var link model.ShareLink
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(&link.ID, &link.ReportID, &link.OrgID, &link.CreatedBy, &link.SingleUse, &link.ExpiresAt, &usedAt, &revokedAt, &link.CreatedAt)
+	if err != nil {
+		return link, err
+	}
+	link.UsedAt = nullTimePtr(usedAt)
+	link.RevokedAt = nullTimePtr(revokedAt)
+	return link, nil
+}
+
+func (s *shareLinkPersistence) Insert(DB *sql.DB, link model.ShareLink) error {
+	query := "INSERT INTO share_links (" + shareLinkColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := DB.Exec(query, link.ID, link.ReportID, link.OrgID, link.CreatedBy, link.SingleUse, link.ExpiresAt, link.UsedAt, link.RevokedAt, link.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert share link: %w", err)
+	}
+	return nil
+}
+
+func (s *shareLinkPersistence) GetByID(DB *sql.DB, OrgID, ID string) (model.ShareLink, error) {
+	return getShareLink(DB.QueryRow("SELECT "+shareLinkColumns+" FROM share_links WHERE id = ? AND org_id = ?", ID, OrgID))
+}
+
+func (s *shareLinkPersistence) GetForToken(DB *sql.DB, ID string) (model.ShareLink, error) {
+	return getShareLink(DB.QueryRow("SELECT "+shareLinkColumns+" FROM share_links WHERE id = ?", ID))
+}
+
+func getShareLink(row *sql.Row) (model.ShareLink, error) {
+	link, err := scanShareLink(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return link, fmt.Errorf("link not found")
+		}
+		return link, fmt.Errorf("failed to get share link by ID: %w", err)
+	}
+	return link, nil
+}
+
+func (s *shareLinkPersistence) GetByReportID(DB *sql.DB, ReportID string) ([]model.ShareLink, error) {
+	links := []model.ShareLink{}
+	rows, err := DB.Query("SELECT "+shareLinkColumns+" FROM share_links WHERE report_id = ? ORDER BY created_at DESC", ReportID)
+	if err != nil {
+		return links, fmt.Errorf("failed to get share links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return links, fmt.Errorf("failed to scan share link: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (s *shareLinkPersistence) Revoke(DB *sql.DB, ID string, RevokedAt time.Time) error {
+	_, err := DB.Exec("UPDATE share_links SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", RevokedAt, ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	return nil
+}
+
+func (s *shareLinkPersistence) MarkUsed(DB *sql.DB, ID string, UsedAt time.Time) error {
+	result, err := DB.Exec("UPDATE share_links SET used_at = ? WHERE id = ? AND used_at IS NULL", UsedAt, ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark share link used: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to mark share link used: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("link already used")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package persistence
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"repo-api/src/domain/model"
+	"repo-api/src/infra/dbtest"
+)
+
+// newTenants is a database with organizations a and b, each with a user
+// who has a public report, a share link to it, a webhook and an audit
+// entry. Everything of b is named b-.
+func newTenants(t *testing.T) *sql.DB {
+	db := dbtest.Open(t, "repoapi_persistence")
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, org := range []string{"a", "b"} {
+		require.NoError(t, NewOrganizationPersistence().Insert(db, model.Organization{ID: org, Name: org}))
+		require.NoError(t, NewUserPersistence().Insert(db, model.User{ID: org + "-user", Name: "佐藤", OrgID: org}))
+		require.NoError(t, NewReportPersistence().Insert(db, org+"-report", org+"-user", org, 800, "title", "essay", "ja", model.VisibilityPublic))
+		require.NoError(t, NewShareLinkPersistence().Insert(db, model.ShareLink{ID: org + "-link", ReportID: org + "-report", OrgID: org, CreatedBy: org + "-user", ExpiresAt: now.Add(time.Hour), CreatedAt: now}))
+		require.NoError(t, NewWebhookPersistence().Insert(db, model.Webhook{ID: org + "-webhook", OrgID: org, URL: "https://hooks.example.com/" + org, Events: []string{model.EventReportCreated}, Secret: "secret", CreatedAt: now}))
+		change := model.UserStatusChange{UserID: org + "-user", To: model.UserActive}
+		_, err := NewUserPersistence().UpdateStatus(db, change, model.AuditEntry{OrgID: org, ActorID: org + "-user", Action: model.AuditUserStatusChanged, TargetID: org + "-user", CreatedAt: now})
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestTenantUsers(t *testing.T) {
+	db := newTenants(t)
+	users := NewUserPersistence()
+
+	_, err := users.GetByID(db, "a", "b-user")
+	assert.Equal(t, sql.ErrNoRows, err)
+	list, err := users.GetByOrgID(db, "a")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "a-user", list[0].ID)
+
+	for _, query := range []model.UserQuery{
+		{Search: "佐藤", Match: model.MatchContains, Limit: 10},
+		{WithReportCount: true, Limit: 10},
+		{WithReportCount: true, ReportsVisibleTo: "a-user", Sort: model.UserSortReportCount, Limit: 10},
+	} {
+		found, total, err := users.Search(db, "a", query)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		require.Len(t, found, 1)
+		assert.Equal(t, "a-user", found[0].ID)
+		if query.WithReportCount {
+			assert.Equal(t, 1, *found[0].ReportCount)
+		}
+	}
+}
+
+func TestTenantReports(t *testing.T) {
+	db := newTenants(t)
+	reports := NewReportPersistence()
+
+	report, err := reports.GetByID(db, "a", "b-report")
+	require.NoError(t, err)
+	assert.Empty(t, report.ID)
+	for name, get := range map[string]func() ([]model.Report, error){
+		"author":   func() ([]model.Report, error) { return reports.GetByAuthorID(db, "a", "b-user") },
+		"title":    func() ([]model.Report, error) { return reports.GetByTitle(db, "a", "b-user", "title") },
+		"style":    func() ([]model.Report, error) { return reports.GetByStyle(db, "a", "b-user", "essay") },
+		"language": func() ([]model.Report, error) { return reports.GetByLanguage(db, "a", "b-user", "ja") },
+	} {
+		_, err := get()
+		assert.EqualError(t, err, "author does not exist", name)
+	}
+
+	changes, err := reports.GetChanges(db, "a", "b-user", 0, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	stats, err := reports.GetStats(db, "a", "b-user", false, time.Now().AddDate(0, 0, -7))
+	require.NoError(t, err)
+	assert.Zero(t, stats.Reports)
+}
+
+func TestTenantShareLinks(t *testing.T) {
+	db := newTenants(t)
+	_, err := NewShareLinkPersistence().GetByID(db, "a", "b-link")
+	assert.EqualError(t, err, "link not found")
+}
+
+func TestTenantWebhooks(t *testing.T) {
+	db := newTenants(t)
+	webhooks := NewWebhookPersistence()
+
+	_, err := webhooks.GetByID(db, "a", "b-webhook")
+	assert.EqualError(t, err, "webhook not found")
+	list, err := webhooks.GetByOrgID(db, "a")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "a-webhook", list[0].ID)
+}
+
+func TestTenantAudit(t *testing.T) {
+	db := newTenants(t)
+	entries, err := NewAuditPersistence().GetByOrgID(db, "a", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a-user", entries[0].TargetID)
+}
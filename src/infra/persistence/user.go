@@ -2,9 +2,13 @@ package persistence
 
 import (
     "database/sql"
+    "encoding/json"
     "repo-api/src/domain/model"
     "repo-api/src/domain/repository"
     "fmt"
+    "log"
+    "strings"
+    "time"
 )
 
 func NewUserPersistence() repository.IUserRepository {
@@ -13,35 +17,222 @@ func NewUserPersistence() repository.IUserRepository {
 
 type userPersistence struct{}
 
-func (u *userPersistence) Insert(DB *sql.DB, ID, Name string) error {
-    var existingID string
-    checkQuery := "SELECT id FROM users WHERE id = ?"
-    err := DB.QueryRow(checkQuery, ID).Scan(&existingID)
-    if existingID != "" {
-        return fmt.Errorf("user with ID %s already exists", ID)
+// userColumns are the columns scanUser reads.
+const userColumns = "id, name, status, email, display_name, affiliation, time_zone, default_style, default_language, default_count, org_id, updated_at"
+
+// scanUser reads userColumns and then the extra columns, if any.
+func scanUser(row rowScanner, extra ...interface{}) (model.User, error) {
+    var user model.User
+    dest := []interface{}{&user.ID, &user.Name, &user.Status, &user.Email, &user.DisplayName, &user.Affiliation, &user.TimeZone, &user.DefaultStyle, &user.DefaultLanguage, &user.DefaultCount, &user.OrgID, &user.UpdatedAt}
+    err := row.Scan(append(dest, extra...)...)
+    return user, err
+}
+
+func (u *userPersistence) Insert(DB *sql.DB, user model.User) error {
+    tx, err := DB.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
     }
-    if err != nil && err != sql.ErrNoRows {
+    defer tx.Rollback()
+
+    // The locking reads keep a rename from taking the ID meanwhile.
+    var taken bool
+    if err := tx.QueryRow("SELECT COUNT(*) > 0 FROM users WHERE id = ? FOR SHARE", user.ID).Scan(&taken); err != nil {
         return fmt.Errorf("failed to check user existence: %w", err)
     }
+    if !taken {
+        query := "SELECT COUNT(*) > 0 FROM user_aliases WHERE old_id = ? AND expires_at > ? FOR SHARE"
+        if err := tx.QueryRow(query, user.ID, time.Now().UTC()).Scan(&taken); err != nil {
+            return fmt.Errorf("failed to check user aliases: %w", err)
+        }
+    }
+    if taken {
+        return fmt.Errorf("user with ID %s already exists", user.ID)
+    }
 
-    query := "INSERT INTO users (id, name) VALUES (?, ?)"
-    _, err = DB.Exec(query, ID, Name)
+    status := user.Status
+    if status == "" {
+        status = model.UserActive
+    }
+    query := "INSERT INTO users (id, name, name_key, status, email, display_name, affiliation, time_zone, default_style, default_language, default_count, org_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+    _, err = tx.Exec(query, user.ID, user.Name, nameKey(user.Name), status, user.Email, user.DisplayName, user.Affiliation, user.TimeZone, user.DefaultStyle, user.DefaultLanguage, user.DefaultCount, user.OrgID)
     if err != nil {
         return err
     }
+    return tx.Commit()
+}
+
+func (u *userPersistence) InsertBatch(DB *sql.DB, users []model.User) error {
+    if len(users) == 0 {
+        return nil
+    }
+
+    placeholders := make([]string, 0, len(users))
+    IDs := make([]interface{}, 0, len(users)+1)
+    args := make([]interface{}, 0, len(users)*4)
+    for _, user := range users {
+        placeholders = append(placeholders, "(?, ?, ?, ?)")
+        IDs = append(IDs, user.ID)
+        args = append(args, user.ID, user.Name, nameKey(user.Name), user.OrgID)
+    }
+
+    tx, err := DB.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    // Duplicate user IDs fail the insert itself; aliases have to be looked
+    // for.
+    var aliased string
+    query := "SELECT old_id FROM user_aliases WHERE old_id IN (?" + strings.Repeat(", ?", len(users)-1) + ") AND expires_at > ? LIMIT 1 FOR SHARE"
+    err = tx.QueryRow(query, append(IDs, time.Now().UTC())...).Scan(&aliased)
+    if err == nil {
+        return fmt.Errorf("failed to insert users: ID %s is an alias", aliased)
+    }
+    if err != sql.ErrNoRows {
+        return fmt.Errorf("failed to check user aliases: %w", err)
+    }
+
+    query = "INSERT INTO users (id, name, name_key, org_id) VALUES " + strings.Join(placeholders, ", ")
+    if _, err := tx.Exec(query, args...); err != nil {
+        return fmt.Errorf("failed to insert users: %w", err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit users: %w", err)
+    }
     return nil
 }
 
-func (u *userPersistence) GetByID(DB *sql.DB, ID string) (model.User, error) {
-    var user model.User
-    query := "SELECT id, name FROM users WHERE id = ?"
-    err := DB.QueryRow(query, ID).Scan(&user.ID, &user.Name)
+func (u *userPersistence) GetByID(DB *sql.DB, OrgID, ID string) (model.User, error) {
+    user, err := scanUser(DB.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ? AND org_id = ?", ID, OrgID))
     if err != nil {
         return user, err
     }
     return user, nil
 }
 
+func (u *userPersistence) GetCaller(DB *sql.DB, ID string) (model.User, error) {
+    return scanUser(DB.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", ID))
+}
+
+func (u *userPersistence) IDTaken(DB *sql.DB, ID string) (bool, error) {
+    var taken bool
+    query := "SELECT EXISTS(SELECT 1 FROM users WHERE id = ?) OR EXISTS(SELECT 1 FROM user_aliases WHERE old_id = ? AND expires_at > ?)"
+    if err := DB.QueryRow(query, ID, ID, time.Now().UTC()).Scan(&taken); err != nil {
+        return false, fmt.Errorf("failed to check user existence: %w", err)
+    }
+    return taken, nil
+}
+
+func (u *userPersistence) GetByOrgID(DB *sql.DB, OrgID string) ([]model.User, error) {
+    var users []model.User
+    query := "SELECT " + userColumns + " FROM users WHERE org_id = ? ORDER BY id"
+    rows, err := DB.Query(query, OrgID)
+    if err != nil {
+        return users, fmt.Errorf("failed to get users: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        user, err := scanUser(rows)
+        if err != nil {
+            log.Println("Error scanning user:", err)
+            continue
+        }
+        users = append(users, user)
+    }
+    return users, nil
+}
+
+// userSortColumns maps the orders of model.UserQuery to columns. Names are
+// sorted by their search key, so that kana sort together whatever their
+// width.
+var userSortColumns = map[string]string{
+    model.UserSortID:          "u.id",
+    model.UserSortName:        "u.name_key",
+    model.UserSortUpdatedAt:   "u.updated_at",
+    model.UserSortReportCount: "report_count",
+}
+
+func (u *userPersistence) Search(DB *sql.DB, OrgID string, Query model.UserQuery) ([]model.User, int, error) {
+    where := " WHERE u.org_id = ?"
+    whereArgs := []interface{}{OrgID}
+    if key := nameKey(Query.Search); key != "" {
+        pattern := likePattern(key) + "%"
+        if Query.Match == model.MatchContains {
+            pattern = "%" + pattern
+        }
+        where += " AND u.name_key LIKE ?"
+        whereArgs = append(whereArgs, pattern)
+    }
+    if Query.Status != "" {
+        where += " AND u.status = ?"
+        whereArgs = append(whereArgs, Query.Status)
+    }
+
+    var total int
+    if err := DB.QueryRow("SELECT COUNT(*) FROM users u"+where, whereArgs...).Scan(&total); err != nil {
+        return nil, 0, fmt.Errorf("failed to count users: %w", err)
+    }
+
+    column, ok := userSortColumns[Query.Sort]
+    if !ok {
+        column = userSortColumns[model.UserSortID]
+    }
+    direction := " ASC"
+    if Query.Descending {
+        direction = " DESC"
+    }
+
+    countColumn, from := "0", " FROM users u"
+    var args []interface{}
+    if Query.WithReportCount || Query.Sort == model.UserSortReportCount {
+        counts := "SELECT author_id, COUNT(*) AS report_count FROM reports WHERE org_id = ?"
+        args = append(args, OrgID)
+        if Query.ReportsVisibleTo != "" {
+            // As for reportApp.readable, public reports of deactivated
+            // authors are hidden.
+            counts += " AND ((visibility = ? AND author_id NOT IN (SELECT id FROM users WHERE org_id = ? AND status = ?)) OR author_id = ?)"
+            args = append(args, model.VisibilityPublic, OrgID, model.UserDeactivated, Query.ReportsVisibleTo)
+        }
+        countColumn = "COALESCE(r.report_count, 0)"
+        from += " LEFT JOIN (" + counts + " GROUP BY author_id) r ON r.author_id = u.id"
+    }
+    // The columns of users are unambiguous, as the report counts only add
+    // author_id and report_count.
+    query := "SELECT " + userColumns + ", " + countColumn + " AS report_count" + from
+    query += where + " ORDER BY " + column + direction
+    if column != "u.id" {
+        // Ties are broken by ID so that pages do not overlap.
+        query += ", u.id" + direction
+    }
+    query += " LIMIT ? OFFSET ?"
+    args = append(append(args, whereArgs...), Query.Limit, Query.Offset)
+
+    rows, err := DB.Query(query, args...)
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to search users: %w", err)
+    }
+    defer rows.Close()
+
+    users := []model.User{}
+    for rows.Next() {
+        var reportCount int
+        user, err := scanUser(rows, &reportCount)
+        if err != nil {
+            log.Println("Error scanning user:", err)
+            continue
+        }
+        if Query.WithReportCount {
+            user.ReportCount = &reportCount
+        }
+        users = append(users, user)
+    }
+    return users, total, nil
+}
+
 func (u *userPersistence) UpdateNameByID(DB *sql.DB, ID, Name string) error {
     var existingID string
     checkQuery := "SELECT id FROM users WHERE id = ?"
@@ -53,11 +244,277 @@ func (u *userPersistence) UpdateNameByID(DB *sql.DB, ID, Name string) error {
         return fmt.Errorf("failed to check user existence: %w", err)
     }
 
-    updateQuery := "UPDATE users SET name = ? WHERE id = ?"
-    _, err = DB.Exec(updateQuery, Name, ID)
+    updateQuery := "UPDATE users SET name = ?, name_key = ? WHERE id = ?"
+    _, err = DB.Exec(updateQuery, Name, nameKey(Name), ID)
     if err != nil {
         return fmt.Errorf("failed to update user: %w", err)
     }
 
     return nil
 }
+
+func (u *userPersistence) UpdateProfile(DB *sql.DB, user model.User) error {
+    query := "UPDATE users SET name = ?, name_key = ?, email = ?, display_name = ?, affiliation = ?, time_zone = ?, default_style = ?, default_language = ?, default_count = ? WHERE id = ?"
+    result, err := DB.Exec(query, user.Name, nameKey(user.Name), user.Email, user.DisplayName, user.Affiliation, user.TimeZone, user.DefaultStyle, user.DefaultLanguage, user.DefaultCount, user.ID)
+    if err != nil {
+        return fmt.Errorf("failed to update user profile: %w", err)
+    }
+    // MySQL counts only the rows it changed, so an unchanged profile needs
+    // the existence check.
+    if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+        var exists bool
+        if err := DB.QueryRow("SELECT COUNT(*) > 0 FROM users WHERE id = ?", user.ID).Scan(&exists); err != nil {
+            return fmt.Errorf("failed to check user existence: %w", err)
+        }
+        if !exists {
+            return fmt.Errorf("user not found")
+        }
+    }
+    return nil
+}
+
+func (u *userPersistence) UpdateStatus(DB *sql.DB, Change model.UserStatusChange, Audit model.AuditEntry) (model.UserStatusChange, error) {
+    tx, err := DB.Begin()
+    if err != nil {
+        return Change, fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if err := tx.QueryRow("SELECT status FROM users WHERE id = ? FOR UPDATE", Change.UserID).Scan(&Change.From); err != nil {
+        if err == sql.ErrNoRows {
+            return Change, fmt.Errorf("user not found")
+        }
+        return Change, fmt.Errorf("failed to check user existence: %w", err)
+    }
+    if _, err := tx.Exec("UPDATE users SET status = ? WHERE id = ?", Change.To, Change.UserID); err != nil {
+        return Change, fmt.Errorf("failed to update user status: %w", err)
+    }
+
+    if Audit.Detail, err = json.Marshal(Change); err != nil {
+        return Change, fmt.Errorf("failed to encode audit detail: %w", err)
+    }
+    if err := recordAudit(tx, Audit); err != nil {
+        return Change, err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return Change, fmt.Errorf("failed to commit user status: %w", err)
+    }
+    return Change, nil
+}
+
+func (u *userPersistence) Delete(DB *sql.DB, ID string, Deletion model.UserDeletion, Audit model.AuditEntry) ([]model.Report, error) {
+    tx, err := DB.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    // Locking the user row keeps reports from being registered for the user
+    // while it is deleted, since report inserts take a shared lock on their
+    // author (see lockAuthor), and locking the new author keeps it from being
+    // deleted before it has the reports.
+    var existingID string
+    if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", ID).Scan(&existingID); err != nil {
+        if err == sql.ErrNoRows {
+            return nil, fmt.Errorf("user not found")
+        }
+        return nil, fmt.Errorf("failed to check user existence: %w", err)
+    }
+    if Deletion.Policy == model.DeleteReassign {
+        if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", Deletion.ReassignTo).Scan(&existingID); err != nil {
+            if err == sql.ErrNoRows {
+                return nil, fmt.Errorf("reassign target not found")
+            }
+            return nil, fmt.Errorf("failed to check reassign target existence: %w", err)
+        }
+    }
+
+    reports, err := lockReportsOf(tx, ID)
+    if err != nil {
+        return nil, err
+    }
+    if len(reports) > 0 && Deletion.Policy != model.DeleteCascade && Deletion.Policy != model.DeleteReassign {
+        return nil, fmt.Errorf("user has reports")
+    }
+    for _, report := range reports {
+        if err := recordChange(tx, report.ID, model.ChangeDelete); err != nil {
+            return nil, err
+        }
+    }
+
+    switch {
+    case len(reports) == 0:
+    case Deletion.Policy == model.DeleteCascade:
+        for _, query := range []string{
+            "DELETE FROM report_shares WHERE report_id IN (SELECT id FROM reports WHERE author_id = ?)",
+            "DELETE FROM share_links WHERE report_id IN (SELECT id FROM reports WHERE author_id = ?)",
+            "DELETE FROM reports WHERE author_id = ?",
+        } {
+            if _, err := tx.Exec(query, ID); err != nil {
+                return nil, fmt.Errorf("failed to delete reports of user: %w", err)
+            }
+        }
+    case Deletion.Policy == model.DeleteReassign:
+        // Shares of the reports with the new author are of no more use.
+        if _, err := tx.Exec("DELETE FROM report_shares WHERE user_id = ? AND report_id IN (SELECT id FROM reports WHERE author_id = ?)", Deletion.ReassignTo, ID); err != nil {
+            return nil, fmt.Errorf("failed to delete report shares: %w", err)
+        }
+        if _, err := tx.Exec("UPDATE reports SET author_id = ? WHERE author_id = ?", Deletion.ReassignTo, ID); err != nil {
+            return nil, fmt.Errorf("failed to reassign reports of user: %w", err)
+        }
+        // The reports leave the change feed of the user with tombstones
+        // and enter that of the new author.
+        for _, report := range reports {
+            if err := recordChange(tx, report.ID, model.ChangeUpsert); err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    // The links the user created keep working as links of the authors of
+    // their reports, who now include the new author of reassigned ones.
+    query := "UPDATE share_links SET created_by = (SELECT author_id FROM reports WHERE reports.id = share_links.report_id) WHERE created_by = ?"
+    if _, err := tx.Exec(query, ID); err != nil {
+        return nil, fmt.Errorf("failed to hand over share links: %w", err)
+    }
+
+    // Aliases would redirect former IDs to a user that no longer exists.
+    for _, query := range []string{
+        "DELETE FROM credentials WHERE user_id = ?",
+        "DELETE FROM refresh_tokens WHERE user_id = ?",
+        "DELETE FROM api_keys WHERE user_id = ?",
+        "DELETE FROM user_identities WHERE user_id = ?",
+        "DELETE FROM report_shares WHERE user_id = ?",
+        "DELETE FROM user_aliases WHERE user_id = ?",
+        "DELETE FROM users WHERE id = ?",
+    } {
+        if _, err := tx.Exec(query, ID); err != nil {
+            return nil, fmt.Errorf("failed to delete user: %w", err)
+        }
+    }
+
+    Deletion.Reports = len(reports)
+    if Audit.Detail, err = json.Marshal(Deletion); err != nil {
+        return nil, fmt.Errorf("failed to encode audit detail: %w", err)
+    }
+    if err := recordAudit(tx, Audit); err != nil {
+        return nil, err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("failed to commit user deletion: %w", err)
+    }
+    return reports, nil
+}
+
+// lockReportsOf returns the reports of the author, locked for the rest of the
+// transaction.
+func lockReportsOf(tx *sql.Tx, AuthorID string) ([]model.Report, error) {
+    var reports []model.Report
+    rows, err := tx.Query("SELECT id, author_id, org_id, count, title, style, language, visibility, updated_at FROM reports WHERE author_id = ? FOR UPDATE", AuthorID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get reports of user: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var report model.Report
+        if err := rows.Scan(&report.ID, &report.AuthorID, &report.OrgID, &report.Count, &report.Title, &report.Style, &report.Language, &report.Visibility, &report.UpdatedAt); err != nil {
+            return nil, fmt.Errorf("failed to scan report: %w", err)
+        }
+        reports = append(reports, report)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("failed to get reports of user: %w", err)
+    }
+    return reports, nil
+}
+
+// renamedColumns are the columns that hold user IDs, except users.id. The
+// audit log keeps the IDs as they were.
+var renamedColumns = []string{
+    "reports.author_id",
+    "report_changes.author_id",
+    "report_shares.user_id",
+    "report_shares.granted_by",
+    "share_links.created_by",
+    "credentials.user_id",
+    "refresh_tokens.user_id",
+    "api_keys.user_id",
+    "user_identities.user_id",
+    "user_aliases.user_id",
+}
+
+func (u *userPersistence) Rename(DB *sql.DB, Rename model.UserRename, Audit model.AuditEntry) error {
+    tx, err := DB.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    var existingID string
+    if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", Rename.OldID).Scan(&existingID); err != nil {
+        if err == sql.ErrNoRows {
+            return fmt.Errorf("user not found")
+        }
+        return fmt.Errorf("failed to check user existence: %w", err)
+    }
+    // The locking read also keeps NewID from being registered meanwhile.
+    var taken bool
+    if err := tx.QueryRow("SELECT COUNT(*) > 0 FROM users WHERE id = ? FOR UPDATE", Rename.NewID).Scan(&taken); err != nil {
+        return fmt.Errorf("failed to check user existence: %w", err)
+    }
+    if !taken {
+        query := "SELECT COUNT(*) > 0 FROM user_aliases WHERE old_id = ? AND user_id <> ? AND expires_at > ?"
+        if err := tx.QueryRow(query, Rename.NewID, Rename.OldID, time.Now().UTC()).Scan(&taken); err != nil {
+            return fmt.Errorf("failed to check user aliases: %w", err)
+        }
+    }
+    if taken {
+        return fmt.Errorf("user id taken")
+    }
+
+    // Taking back a former ID drops its alias, which would otherwise point
+    // at itself.
+    if _, err := tx.Exec("DELETE FROM user_aliases WHERE old_id = ?", Rename.NewID); err != nil {
+        return fmt.Errorf("failed to delete user alias: %w", err)
+    }
+    if _, err := tx.Exec("UPDATE users SET id = ? WHERE id = ?", Rename.NewID, Rename.OldID); err != nil {
+        return fmt.Errorf("failed to rename user: %w", err)
+    }
+    for _, column := range renamedColumns {
+        table, name, _ := strings.Cut(column, ".")
+        if _, err := tx.Exec("UPDATE "+table+" SET "+name+" = ? WHERE "+name+" = ?", Rename.NewID, Rename.OldID); err != nil {
+            return fmt.Errorf("failed to rename user in %s: %w", column, err)
+        }
+    }
+    query := "INSERT INTO user_aliases (old_id, user_id, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), expires_at = VALUES(expires_at)"
+    if _, err := tx.Exec(query, Rename.OldID, Rename.NewID, Rename.AliasExpiresAt); err != nil {
+        return fmt.Errorf("failed to insert user alias: %w", err)
+    }
+
+    if Audit.Detail, err = json.Marshal(Rename); err != nil {
+        return fmt.Errorf("failed to encode audit detail: %w", err)
+    }
+    if err := recordAudit(tx, Audit); err != nil {
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("failed to commit user rename: %w", err)
+    }
+    return nil
+}
+
+func (u *userPersistence) GetAlias(DB *sql.DB, ID string, now time.Time) (string, error) {
+    var userID string
+    err := DB.QueryRow("SELECT user_id FROM user_aliases WHERE old_id = ? AND expires_at > ?", ID, now).Scan(&userID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return "", fmt.Errorf("alias not found")
+        }
+        return "", fmt.Errorf("failed to get user alias: %w", err)
+    }
+    return userID, nil
+}
@@ -0,0 +1,194 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/domain/repository"
+)
+
+func NewWebhookPersistence() repository.IWebhookRepository {
+	return &webhookPersistence{}
+}
+
+type webhookPersistence struct{}
+
+func (w *webhookPersistence) Insert(DB *sql.DB, webhook model.Webhook) error {
+	query := "INSERT INTO webhooks (id, org_id, url, events, secret, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := DB.Exec(query, webhook.ID, webhook.OrgID, webhook.URL, strings.Join(webhook.Events, ","), webhook.Secret, webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookPersistence) Delete(DB *sql.DB, ID string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", ID); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook deletion: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookPersistence) GetByID(DB *sql.DB, OrgID, ID string) (model.Webhook, error) {
+	var webhook model.Webhook
+	var events string
+	query := "SELECT id, org_id, url, events, secret, created_at FROM webhooks WHERE id = ? AND org_id = ?"
+	err := DB.QueryRow(query, ID, OrgID).Scan(&webhook.ID, &webhook.OrgID, &webhook.URL, &events, &webhook.Secret, &webhook.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return webhook, fmt.Errorf("webhook not found")
+		}
+		return webhook, fmt.Errorf("failed to get webhook by ID: %w", err)
+	}
+	webhook.Events = strings.Split(events, ",")
+	return webhook, nil
+}
+
+func (w *webhookPersistence) GetByOrgID(DB *sql.DB, OrgID string) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	query := "SELECT id, org_id, url, events, secret, created_at FROM webhooks WHERE org_id = ? ORDER BY created_at"
+	rows, err := DB.Query(query, OrgID)
+	if err != nil {
+		return webhooks, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var webhook model.Webhook
+		var events string
+		if err := rows.Scan(&webhook.ID, &webhook.OrgID, &webhook.URL, &events, &webhook.Secret, &webhook.CreatedAt); err != nil {
+			log.Println("Error scanning webhook:", err)
+			continue
+		}
+		webhook.Events = strings.Split(events, ",")
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+const deliveryColumns = "id, webhook_id, event_id, event_type, payload, status, attempts, response_code, error, next_attempt_at, created_at, updated_at"
+
+// scanDelivery reads deliveryColumns and then the extra columns, if any.
+func scanDelivery(row rowScanner, extra ...interface{}) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	var nextAttemptAt sql.NullTime
+	dest := []interface{}{&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.ResponseCode, &delivery.Error, &nextAttemptAt, &delivery.CreatedAt, &delivery.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return delivery, err
+	}
+	delivery.NextAttemptAt = nullTimePtr(nextAttemptAt)
+	return delivery, nil
+}
+
+func (w *webhookPersistence) InsertDelivery(DB *sql.DB, delivery model.WebhookDelivery) error {
+	query := "INSERT INTO webhook_deliveries (" + deliveryColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := DB.Exec(query, delivery.ID, delivery.WebhookID, delivery.EventID, delivery.EventType, delivery.Payload, delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.Error, delivery.NextAttemptAt, delivery.CreatedAt, delivery.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookPersistence) UpdateDelivery(DB *sql.DB, delivery model.WebhookDelivery) error {
+	query := "UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, error = ?, next_attempt_at = ?, updated_at = ? WHERE id = ?"
+	_, err := DB.Exec(query, delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.Error, delivery.NextAttemptAt, delivery.UpdatedAt, delivery.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookPersistence) ClaimDueDeliveries(DB *sql.DB, Now, Until time.Time, Limit int) ([]model.DueDelivery, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED leaves deliveries another worker is claiming to it.
+	query := "SELECT d." + strings.ReplaceAll(deliveryColumns, ", ", ", d.") + ", h.id, h.org_id, h.url, h.events, h.secret, h.created_at FROM webhook_deliveries d JOIN webhooks h ON h.id = d.webhook_id WHERE d.next_attempt_at <= ? ORDER BY d.next_attempt_at LIMIT ? FOR UPDATE OF d SKIP LOCKED"
+	rows, err := tx.Query(query, Now, Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due webhook deliveries: %w", err)
+	}
+	var due []model.DueDelivery
+	for rows.Next() {
+		var webhook model.Webhook
+		var events string
+		delivery, err := scanDelivery(rows, &webhook.ID, &webhook.OrgID, &webhook.URL, &events, &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		webhook.Events = strings.Split(events, ",")
+		delivery.NextAttemptAt = &Until
+		due = append(due, model.DueDelivery{Webhook: webhook, Delivery: delivery})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get due webhook deliveries: %w", err)
+	}
+
+	for _, d := range due {
+		if _, err := tx.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?", Until, d.Delivery.ID); err != nil {
+			return nil, fmt.Errorf("failed to claim webhook delivery: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit webhook delivery claims: %w", err)
+	}
+	return due, nil
+}
+
+func (w *webhookPersistence) GetDeliveryByID(DB *sql.DB, ID string) (model.WebhookDelivery, error) {
+	delivery, err := scanDelivery(DB.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ?", ID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return delivery, fmt.Errorf("delivery not found")
+		}
+		return delivery, fmt.Errorf("failed to get webhook delivery by ID: %w", err)
+	}
+	return delivery, nil
+}
+
+func (w *webhookPersistence) GetDeliveriesByWebhookID(DB *sql.DB, WebhookID string) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC"
+	rows, err := DB.Query(query, WebhookID)
+	if err != nil {
+		return deliveries, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			log.Println("Error scanning webhook delivery:", err)
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,115 @@
+// Package jsonstrict decodes JSON request bodies without the leniency of
+// encoding/json: unknown fields, duplicate keys and data after the value are
+// errors, so a misspelled field cannot pass as a successful no-op.
+package jsonstrict
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Decode reads all of r and decodes it into v. Errors from r itself, such as
+// *http.MaxBytesError, are returned as they are.
+func Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(data, v)
+}
+
+func Unmarshal(data []byte, v interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return fmt.Errorf("request body is empty")
+	}
+	if err := CheckDuplicateKeys(data); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return describe(err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the JSON value at offset %d", decoder.InputOffset())
+	}
+	return nil
+}
+
+// CheckDuplicateKeys reports the first key that appears twice in the same
+// object of the first JSON value in data, naming it by its path.
+func CheckDuplicateKeys(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return checkValue(decoder, "")
+}
+
+func checkValue(decoder *json.Decoder, path string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return describe(err)
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		keys := make(map[string]bool)
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return describe(err)
+			}
+			key := token.(string)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			if keys[key] {
+				return fmt.Errorf("duplicate key %q", keyPath)
+			}
+			keys[key] = true
+			if err := checkValue(decoder, keyPath); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; decoder.More(); i++ {
+			if err := checkValue(decoder, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Consume the closing delimiter.
+	if _, err := decoder.Token(); err != nil {
+		return describe(err)
+	}
+	return nil
+}
+
+// describe turns encoding/json errors into messages that point at the
+// offending field or position.
+func describe(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Errorf("expected %s, got JSON %s", typeErr.Type, typeErr.Value)
+		}
+		return fmt.Errorf("invalid value for %q: expected %s, got JSON %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		return fmt.Errorf("unexpected end of JSON input")
+	}
+	return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+}
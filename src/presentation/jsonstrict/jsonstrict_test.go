@@ -0,0 +1,71 @@
+package jsonstrict
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	ID    string `json:"id"`
+	Count int    `json:"count"`
+	Tags  []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	var req request
+	require.NoError(t, Unmarshal([]byte(` {"id":"r1","count":800,"tags":[{"name":"a"},{"name":"b"}]} `+"\n"), &req))
+	assert.Equal(t, "r1", req.ID)
+	assert.Equal(t, 800, req.Count)
+	assert.Len(t, req.Tags, 2)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	cases := []struct {
+		name, data, err string
+	}{
+		{"empty", "", "request body is empty"},
+		{"blank", " \n", "request body is empty"},
+		{"unknown field", `{"id":"r1","titel":"x"}`, `unknown field "titel"`},
+		{"unknown nested field", `{"tags":[{"nmae":"a"}]}`, `unknown field "nmae"`},
+		{"duplicate key", `{"id":"r1","id":"r2"}`, `duplicate key "id"`},
+		{"duplicate nested key", `{"tags":[{"name":"a"},{"name":"b","name":"c"}]}`, `duplicate key "tags[1].name"`},
+		{"trailing value", `{"id":"r1"}{"id":"r2"}`, "unexpected data after the JSON value"},
+		{"trailing garbage", `{"id":"r1"} x`, "unexpected data after the JSON value"},
+		{"wrong type", `{"count":"800"}`, `invalid value for "count": expected int, got JSON string`},
+		{"not an object", `[]`, "expected jsonstrict.request, got JSON array"},
+		{"truncated", `{"id":"r1"`, "unexpected end of JSON input"},
+		{"syntax", `{"id" "r1"}`, "invalid JSON at offset"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var req request
+			err := Unmarshal([]byte(c.data), &req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+}
+
+// TestDuplicateKeysInOtherObjects checks that keys are only compared within
+// one object.
+func TestDuplicateKeysInOtherObjects(t *testing.T) {
+	assert.NoError(t, CheckDuplicateKeys([]byte(`{"a":{"id":1},"b":{"id":2}}`)))
+	assert.NoError(t, CheckDuplicateKeys([]byte(`[{"id":1},{"id":2}]`)))
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("body too large") }
+
+func TestDecode(t *testing.T) {
+	var req request
+	require.NoError(t, Decode(strings.NewReader(`{"id":"r1"}`), &req))
+	assert.Equal(t, "r1", req.ID)
+	assert.EqualError(t, Decode(failingReader{}, &req), "body too large")
+}
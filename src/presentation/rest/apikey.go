@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/jsonstrict"
+)
+
+type APIKeyHandler interface {
+	HandleIssue(c *gin.Context)
+	HandleList(c *gin.Context)
+	HandleRevoke(c *gin.Context)
+}
+
+func NewAPIKeyHandler(db *sql.DB, ak application.APIKeyApp) APIKeyHandler {
+	return &apiKeyHandler{
+		database:  db,
+		apiKeyApp: ak,
+	}
+}
+
+type apiKeyHandler struct {
+	apiKeyApp application.APIKeyApp
+	database  *sql.DB
+}
+
+type apiKeyRequest struct {
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Signing issues a key for signed requests instead of a bearer token.
+	Signing bool `json:"signing"`
+}
+
+// canManageKeys reports whether principal may manage the keys of UserID:
+// everyone manages their own keys, users:admin manages everyone's.
+func canManageKeys(principal model.Principal, UserID string) bool {
+	return principal.UserID == UserID || application.HasScope(principal.Scopes, model.ScopeUsersAdmin)
+}
+
+func (a *apiKeyHandler) HandleIssue(c *gin.Context) {
+	var req apiKeyRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	principal := PrincipalFrom(c)
+	if req.UserID == "" {
+		req.UserID = principal.UserID
+	}
+	if req.Name == "" || len(req.Scopes) == 0 {
+		respondError(c, http.StatusBadRequest, "Name and Scopes are required")
+		return
+	}
+	if !canManageKeys(principal, req.UserID) {
+		respondError(c, http.StatusForbidden, "Cannot issue API keys for another user")
+		return
+	}
+	// A key can never grant more than the key that issued it.
+	for _, scope := range req.Scopes {
+		if !application.HasScope(principal.Scopes, scope) {
+			respondError(c, http.StatusForbidden, "Cannot grant the "+scope+" scope")
+			return
+		}
+	}
+
+	issue := a.apiKeyApp.Issue
+	if req.Signing {
+		issue = a.apiKeyApp.IssueSigningKey
+	}
+	key, token, err := issue(a.database, principal.OrgID, req.UserID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		log.Printf("Error issuing api key: %v", err)
+		switch err.Error() {
+		case "invalid scope":
+			respondError(c, http.StatusBadRequest, "Unknown scope")
+		case "expiry is in the past":
+			respondError(c, http.StatusBadRequest, "ExpiresAt must be in the future")
+		case "user not found":
+			respondError(c, http.StatusNotFound, "User not found")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to issue API key")
+		}
+		return
+	}
+
+	// The token is only ever returned here.
+	if key.Signing {
+		c.JSON(http.StatusOK, gin.H{"api_key": key, "secret": token})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_key": key, "token": token})
+}
+
+func (a *apiKeyHandler) HandleList(c *gin.Context) {
+	principal := PrincipalFrom(c)
+	UserID := c.Query("user_id")
+	if UserID == "" {
+		UserID = principal.UserID
+	}
+	if !canManageKeys(principal, UserID) {
+		respondError(c, http.StatusForbidden, "Cannot list API keys of another user")
+		return
+	}
+
+	keys, err := a.apiKeyApp.List(a.database, principal.OrgID, UserID)
+	if err != nil {
+		log.Printf("Error listing api keys: %v", err)
+		if err.Error() == "user not found" {
+			respondError(c, http.StatusNotFound, "User not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to get API keys")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (a *apiKeyHandler) HandleRevoke(c *gin.Context) {
+	ID := c.Query("id")
+	if ID == "" {
+		respondError(c, http.StatusBadRequest, "ID is required")
+		return
+	}
+
+	key, err := a.apiKeyApp.Get(a.database, PrincipalFrom(c).OrgID, ID)
+	if err == nil && !canManageKeys(PrincipalFrom(c), key.UserID) {
+		// Other users' keys are reported as missing rather than forbidden, so
+		// their IDs cannot be probed.
+		respondError(c, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err == nil {
+		key, err = a.apiKeyApp.Revoke(a.database, PrincipalFrom(c).OrgID, ID)
+	}
+	if err != nil {
+		log.Printf("Error revoking api key: %v", err)
+		if err.Error() == "api key not found" {
+			respondError(c, http.StatusNotFound, "API key not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to revoke API key")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_key": key})
+}
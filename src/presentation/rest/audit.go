@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditHandler interface {
+	HandleList(c *gin.Context)
+}
+
+func NewAuditHandler(db *sql.DB, aa application.AuditApp) AuditHandler {
+	return &auditHandler{
+		database: db,
+		auditApp: aa,
+	}
+}
+
+type auditHandler struct {
+	auditApp application.AuditApp
+	database *sql.DB
+}
+
+// HandleList returns the newest entries first. The ID of the last entry,
+// passed as before, fetches the next page.
+func (a *auditHandler) HandleList(c *gin.Context) {
+	var before uint64
+	if raw := c.Query("before"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "before must be the ID of an entry")
+			return
+		}
+		before = parsed
+	}
+
+	limit := defaultAuditLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxAuditLimit {
+			respondError(c, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+
+	entries, err := a.auditApp.List(a.database, PrincipalFrom(c), before, limit)
+	if err != nil {
+		log.Printf("Error retrieving audit log: %v", err)
+		if err.Error() == "forbidden" {
+			respondError(c, http.StatusForbidden, "Only administrators can read the audit log")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to retrieve audit log")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
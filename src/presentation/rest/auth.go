@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/signer"
+)
+
+const principalKey = "principal"
+
+// Authenticate requires every request to carry an API key or a session's
+// access token as a bearer token, or to be signed with a signing key, and
+// stores the principal it belongs to, with the scopes its role grants, in the
+// context, where handlers read it with PrincipalFrom.
+func Authenticate(db *sql.DB, ak application.APIKeyApp, as application.SessionApp, sa application.SignatureApp, ra application.RoleApp) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		signed := c.GetHeader(signer.HeaderSignature) != ""
+		if !signed && (!found || token == "") {
+			c.Header("WWW-Authenticate", `Bearer realm="repoapi"`)
+			respondError(c, http.StatusUnauthorized, "Authentication required")
+			c.Abort()
+			return
+		}
+
+		var principal model.Principal
+		var err error
+		if signed {
+			// The body is part of the signature, so it is read here and put
+			// back for the handler.
+			body, readErr := io.ReadAll(c.Request.Body)
+			if readErr != nil {
+				respondBodyError(c, readErr)
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			principal, err = sa.Authenticate(db, application.SignedRequest{
+				KeyID:     c.GetHeader(signer.HeaderKeyID),
+				Timestamp: c.GetHeader(signer.HeaderTimestamp),
+				Nonce:     c.GetHeader(signer.HeaderNonce),
+				Signature: c.GetHeader(signer.HeaderSignature),
+				Method:    c.Request.Method,
+				Path:      c.Request.URL.EscapedPath(),
+				RawQuery:  c.Request.URL.RawQuery,
+				Body:      body,
+			})
+		} else if strings.HasPrefix(token, "rk_") {
+			principal, err = ak.Authenticate(db, token)
+		} else {
+			principal, err = as.Authenticate(db, token)
+		}
+		if err == nil {
+			principal, err = ra.Authorize(db, principal)
+		}
+		if err != nil {
+			switch err.Error() {
+			case "invalid api key", "invalid token":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "Invalid API key or access token")
+			case "api key revoked":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "API key has been revoked")
+			case "api key expired":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "API key has expired")
+			case "token expired":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "Access token has expired")
+			case "user not found":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "The user of this token no longer exists")
+			case "user deactivated":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "The user of this token has been deactivated")
+			case "session revoked":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "Session has been logged out")
+			case "invalid signature":
+				respondError(c, http.StatusUnauthorized, "Invalid request signature")
+			case "timestamp out of range":
+				respondError(c, http.StatusUnauthorized, "Request timestamp is too far from the server's clock")
+			case "nonce already used":
+				respondError(c, http.StatusUnauthorized, "Request nonce has already been used")
+			default:
+				log.Printf("Error authenticating request: %v", err)
+				respondError(c, http.StatusInternalServerError, "Failed to authenticate")
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// RequireScope refuses requests whose principal lacks scope with 403.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFrom(c)
+		if !application.HasScope(principal.Scopes, scope) {
+			if principal.Suspended {
+				respondError(c, http.StatusForbidden, "The user of this token is suspended")
+				c.Abort()
+				return
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="insufficient_scope", scope="`+scope+`"`)
+			respondError(c, http.StatusForbidden, "Missing the "+scope+" scope")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RejectSuspended refuses suspended callers on routes that change something
+// without requiring a write scope. Logging out and revoking keys stay open
+// to them.
+func RejectSuspended(c *gin.Context) {
+	if PrincipalFrom(c).Suspended {
+		respondError(c, http.StatusForbidden, "The user of this token is suspended")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// PrincipalFrom returns the caller stored by Authenticate.
+func PrincipalFrom(c *gin.Context) model.Principal {
+	if value, found := c.Get(principalKey); found {
+		if principal, ok := value.(model.Principal); ok {
+			return principal
+		}
+	}
+	return model.Principal{}
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/infra/config"
+)
+
+// BodyLimit caps request bodies per route. Bodies that announce a larger
+// Content-Length are refused up front; others are cut off while being read,
+// and the handler reports that through respondBodyError.
+func BodyLimit(cfg config.BodyLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := cfg.Limit(routeName(c))
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			respondError(c, http.StatusRequestEntityTooLarge, "Request body must not exceed "+strconv.FormatInt(limit, 10)+" bytes")
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// respondBodyError answers a request whose body could not be decoded: 413 if
+// it was cut off by BodyLimit, otherwise 400 with the decoder's explanation.
+func respondBodyError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondError(c, http.StatusRequestEntityTooLarge, "Request body must not exceed "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+		return
+	}
+	respondError(c, http.StatusBadRequest, invalidBodyMessage(c)+": "+err.Error())
+}
@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+type EventHandler interface {
+	HandleStream(c *gin.Context)
+}
+
+func NewEventHandler(es application.EventSubscriber) EventHandler {
+	return &eventHandler{
+		eventSubscriber: es,
+	}
+}
+
+type eventHandler struct {
+	eventSubscriber application.EventSubscriber
+}
+
+// HandleStream serves report and user events as Server-Sent Events. Clients
+// resume with the Last-Event-ID header (or the last_event_id query parameter)
+// and may restrict the stream to one author with author_id. Events about
+// reports the caller cannot read are left out.
+func (e *eventHandler) HandleStream(c *gin.Context) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		parsed, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Last-Event-ID must be an event ID")
+			return
+		}
+		lastID = parsed
+	}
+	authorID := c.Query("author_id")
+	principal := PrincipalFrom(c)
+	visible := func(event model.Event) bool {
+		return (authorID == "" || event.AuthorID == authorID) && application.CanSeeEvent(principal, event)
+	}
+
+	replay, gap, events, cancel := e.eventSubscriber.Subscribe(lastID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	if gap {
+		writeEvent(c.Writer, model.Event{Type: model.EventStreamReset, Time: time.Now()})
+	}
+	for _, event := range replay {
+		if visible(event) {
+			writeEvent(c.Writer, event)
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !visible(event) {
+				continue
+			}
+			writeEvent(c.Writer, event)
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, event model.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding event %d: %v", event.ID, err)
+		return
+	}
+	if event.ID != 0 {
+		fmt.Fprintf(w, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
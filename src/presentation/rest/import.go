@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+)
+
+type ImportHandler interface {
+	HandleImportUsers(c *gin.Context)
+	HandleImportReports(c *gin.Context)
+}
+
+func NewImportHandler(db *sql.DB, ai application.ImportApp) ImportHandler {
+	return &importHandler{
+		database:  db,
+		importApp: ai,
+	}
+}
+
+type importHandler struct {
+	importApp application.ImportApp
+	database  *sql.DB
+}
+
+type importFunc func(DB *sql.DB, Caller model.Principal, r io.Reader, format string, dryRun bool) (model.ImportResult, error)
+
+func (i *importHandler) HandleImportUsers(c *gin.Context) {
+	i.handleImport(c, i.importApp.ImportUsers)
+}
+
+func (i *importHandler) HandleImportReports(c *gin.Context) {
+	i.handleImport(c, i.importApp.ImportReports)
+}
+
+// handleImport accepts the file either as a multipart "file" field or as the
+// raw request body. The format comes from the "format" query parameter and
+// falls back to the file name or Content-Type.
+func (i *importHandler) handleImport(c *gin.Context, run importFunc) {
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "dry_run must be a boolean")
+			return
+		}
+		dryRun = parsed
+	}
+
+	var body io.Reader = c.Request.Body
+	filename := ""
+	contentType := c.ContentType()
+	if contentType == "multipart/form-data" {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			if isBodyTooLarge(err) {
+				respondBodyError(c, err)
+			} else {
+				respondError(c, http.StatusBadRequest, "file is required")
+			}
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to read file")
+			return
+		}
+		defer file.Close()
+		body = file
+		filename = fileHeader.Filename
+		contentType = fileHeader.Header.Get("Content-Type")
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = application.DetectImportFormat(filename, contentType)
+	}
+	if format != application.ImportFormatCSV && format != application.ImportFormatNDJSON {
+		respondError(c, http.StatusBadRequest, "format must be csv or ndjson")
+		return
+	}
+
+	result, err := run(i.database, PrincipalFrom(c), body, format, dryRun)
+	if err != nil {
+		log.Printf("Error importing: %v", err)
+		if isBodyTooLarge(err) {
+			respondBodyError(c, err)
+		} else if err.Error() == "forbidden" {
+			respondError(c, http.StatusForbidden, "Only administrators can import users")
+		} else if err.Error() == "unsupported import format" {
+			respondError(c, http.StatusBadRequest, "format must be csv or ndjson")
+		} else if strings.HasPrefix(err.Error(), "invalid csv header") || strings.HasPrefix(err.Error(), "failed to read") {
+			respondError(c, http.StatusBadRequest, err.Error())
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to import")
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if len(result.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"result": result})
+}
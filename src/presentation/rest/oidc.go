@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+)
+
+// oidcLoginCookie carries the login state from HandleLogin to HandleCallback.
+const oidcLoginCookie = "repoapi_oidc_login"
+
+type OIDCHandler interface {
+	HandleLogin(c *gin.Context)
+	HandleCallback(c *gin.Context)
+}
+
+// NewOIDCHandler takes whether the login cookie may only be sent over HTTPS,
+// which it should be whenever the API is served over HTTPS.
+func NewOIDCHandler(db *sql.DB, oa application.OIDCApp, secureCookie bool) OIDCHandler {
+	return &oidcHandler{
+		database:     db,
+		oidcApp:      oa,
+		secureCookie: secureCookie,
+	}
+}
+
+type oidcHandler struct {
+	oidcApp      application.OIDCApp
+	database     *sql.DB
+	secureCookie bool
+}
+
+func (o *oidcHandler) setLoginCookie(c *gin.Context, value string, maxAge int) {
+	// Lax, because the provider sends the browser back with a top-level
+	// navigation from its own site.
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcLoginCookie, value, maxAge, "/oidc", "", o.secureCookie, true)
+}
+
+// HandleLogin sends the browser to the identity provider.
+func (o *oidcHandler) HandleLogin(c *gin.Context) {
+	login, err := o.oidcApp.Begin()
+	if err != nil {
+		log.Printf("Error starting login: %v", err)
+		respondError(c, http.StatusBadGateway, "Identity provider is unavailable")
+		return
+	}
+	o.setLoginCookie(c, login.State, 0)
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, login.URL)
+}
+
+// HandleCallback is where the identity provider sends the browser back. It
+// answers with the same tokens as POST /login.
+func (o *oidcHandler) HandleCallback(c *gin.Context) {
+	loginState, _ := c.Cookie(oidcLoginCookie)
+	o.setLoginCookie(c, "", -1)
+
+	if c.Query("error") != "" {
+		respondError(c, http.StatusUnauthorized, "Identity provider refused the login: "+c.Query("error"))
+		return
+	}
+	if c.Query("code") == "" || c.Query("state") == "" {
+		respondError(c, http.StatusBadRequest, "code and state are required")
+		return
+	}
+
+	tokens, err := o.oidcApp.Callback(o.database, c.Query("code"), c.Query("state"), loginState)
+	if err != nil {
+		log.Printf("Error logging in with identity provider: %v", err)
+		switch err.Error() {
+		case "invalid login state":
+			respondError(c, http.StatusBadRequest, "Login has expired or was started elsewhere; start it again")
+		case "invalid code":
+			respondError(c, http.StatusUnauthorized, "Authorization code is invalid or has been used")
+		case "invalid id token", "id token expired":
+			respondError(c, http.StatusUnauthorized, "Identity provider returned an invalid ID token")
+		case "user not provisioned":
+			respondError(c, http.StatusForbidden, "No user exists for this account")
+		case "user not found":
+			respondError(c, http.StatusForbidden, "The user of this account no longer exists")
+		case "user id taken":
+			respondError(c, http.StatusConflict, "Another user already has the ID of this account")
+		default:
+			respondError(c, http.StatusBadGateway, "Failed to log in with the identity provider")
+		}
+		return
+	}
+	respondTokens(c, tokens)
+}
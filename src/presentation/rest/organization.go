@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+)
+
+type OrganizationHandler interface {
+	HandleGet(c *gin.Context)
+}
+
+func NewOrganizationHandler(db *sql.DB, ao application.OrganizationApp) OrganizationHandler {
+	return &organizationHandler{
+		database:        db,
+		organizationApp: ao,
+	}
+}
+
+type organizationHandler struct {
+	organizationApp application.OrganizationApp
+	database        *sql.DB
+}
+
+// HandleGet returns the caller's organization; other organizations cannot
+// be looked up.
+func (o *organizationHandler) HandleGet(c *gin.Context) {
+	organization, err := o.organizationApp.Get(o.database, PrincipalFrom(c).OrgID)
+	if err != nil {
+		log.Printf("Error retrieving organization: %v", err)
+		if err.Error() == "organization not found" {
+			respondError(c, http.StatusNotFound, "Organization not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to get organization")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organization": organization})
+}
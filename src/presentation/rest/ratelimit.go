@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/infra/config"
+)
+
+// RateLimitSubject names the client a request is charged to.
+type RateLimitSubject func(c *gin.Context) string
+
+// ByClientIP charges requests to the address they come from, for routes
+// reachable without authentication. Forwarded addresses are only believed
+// from the proxies the router trusts.
+func ByClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser charges requests to the user Authenticate resolved, so it has to
+// run after Authenticate. Unverified credentials never name a bucket.
+func ByUser(c *gin.Context) string {
+	principal := PrincipalFrom(c)
+	return "user:" + principal.OrgID + "/" + principal.UserID
+}
+
+// RateLimit applies a token bucket per client and per route. Clients are
+// identified by subject. If the store fails the request is let through, so a
+// broken shared store cannot take the API down.
+func RateLimit(store application.RateLimitStore, cfg config.RateLimitConfig, subject RateLimitSubject) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		route := routeName(c)
+		rule := cfg.Rule(route)
+		if rule.Limit <= 0 || rule.WindowSeconds <= 0 {
+			c.Next()
+			return
+		}
+
+		key := subject(c) + "|" + route
+		decision, err := store.Take(key, rule.Limit, time.Duration(rule.WindowSeconds)*time.Second, time.Now())
+		if err != nil {
+			log.Printf("Error checking rate limit for %s: %v", route, err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(decision.Reset.Seconds())))
+		c.Header("RateLimit-Policy", strconv.Itoa(rule.Limit)+";w="+strconv.Itoa(rule.WindowSeconds))
+
+		if !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(decision.RetryAfter.Seconds())))
+			respondError(c, http.StatusTooManyRequests, "Too many requests")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// routeName identifies the matched route the way the config keys it, such as
+// "GET /report".
+func routeName(c *gin.Context) string {
+	if c.FullPath() == "" {
+		return c.Request.Method + " " + c.Request.URL.Path
+	}
+	return c.Request.Method + " " + c.FullPath()
+}
@@ -0,0 +1,209 @@
+package rest
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/protobuf/proto"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/jsonstrict"
+	"repo-api/src/presentation/pb"
+)
+
+// Handlers speak JSON by default and Protocol Buffers when the client asks for
+// application/x-protobuf. Request bodies follow Content-Type; responses follow
+// Accept, falling back to the request's Content-Type when Accept is missing or
+// a wildcard. JSON bodies are decoded strictly, see jsonstrict.
+
+func wantsProtobuf(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	if accept == "" || accept == "*/*" {
+		return c.ContentType() == binding.MIMEPROTOBUF
+	}
+	return c.NegotiateFormat(binding.MIMEJSON, binding.MIMEPROTOBUF) == binding.MIMEPROTOBUF
+}
+
+// bindReportRegister and the other binds read protobuf bodies as the message
+// of the resource, of which they take only the fields of the request.
+func bindReportRegister(c *gin.Context, req *ReportRegisterRequest) error {
+	if c.ContentType() != binding.MIMEPROTOBUF {
+		return jsonstrict.Decode(c.Request.Body, req)
+	}
+	var message pb.Report
+	if err := c.ShouldBindWith(&message, binding.ProtoBuf); err != nil {
+		return err
+	}
+	report := reportFromProto(&message)
+	*req = ReportRegisterRequest{AuthorID: report.AuthorID, Count: report.Count, Title: report.Title, Style: report.Style, Language: report.Language, Visibility: report.Visibility}
+	return nil
+}
+
+func bindReportUpdate(c *gin.Context, req *ReportUpdateRequest) error {
+	if c.ContentType() != binding.MIMEPROTOBUF {
+		return jsonstrict.Decode(c.Request.Body, req)
+	}
+	var message pb.Report
+	if err := c.ShouldBindWith(&message, binding.ProtoBuf); err != nil {
+		return err
+	}
+	report := reportFromProto(&message)
+	*req = ReportUpdateRequest{ID: report.ID, Count: report.Count, Title: report.Title, Style: report.Style, Language: report.Language, Visibility: report.Visibility}
+	return nil
+}
+
+func bindUserRegister(c *gin.Context, req *UserRegisterRequest) error {
+	if c.ContentType() != binding.MIMEPROTOBUF {
+		return jsonstrict.Decode(c.Request.Body, req)
+	}
+	var message pb.User
+	if err := c.ShouldBindWith(&message, binding.ProtoBuf); err != nil {
+		return err
+	}
+	user := userFromProto(&message)
+	*req = UserRegisterRequest{
+		ID:              user.ID,
+		Name:            user.Name,
+		Status:          user.Status,
+		Email:           user.Email,
+		DisplayName:     user.DisplayName,
+		Affiliation:     user.Affiliation,
+		TimeZone:        user.TimeZone,
+		DefaultStyle:    user.DefaultStyle,
+		DefaultLanguage: user.DefaultLanguage,
+		DefaultCount:    user.DefaultCount,
+	}
+	return nil
+}
+
+func bindUserUpdate(c *gin.Context, req *UserUpdateRequest) error {
+	if c.ContentType() != binding.MIMEPROTOBUF {
+		return jsonstrict.Decode(c.Request.Body, req)
+	}
+	var message pb.User
+	if err := c.ShouldBindWith(&message, binding.ProtoBuf); err != nil {
+		return err
+	}
+	req.ID, req.UserProfile = userProfileFromProto(&message)
+	return nil
+}
+
+func invalidBodyMessage(c *gin.Context) string {
+	if c.ContentType() == binding.MIMEPROTOBUF {
+		return "Invalid protobuf format"
+	}
+	return "Invalid JSON format"
+}
+
+func respondError(c *gin.Context, status int, message string) {
+	if wantsProtobuf(c) {
+		c.ProtoBuf(status, &pb.Error{Error: message})
+		return
+	}
+	c.JSON(status, gin.H{"error": message})
+}
+
+func respondMessage(c *gin.Context, status int, message string) {
+	if wantsProtobuf(c) {
+		c.ProtoBuf(status, &pb.Status{Message: message})
+		return
+	}
+	c.JSON(status, gin.H{"message": message})
+}
+
+func respondReports(c *gin.Context, status int, reports []model.Report) {
+	if wantsProtobuf(c) {
+		list := &pb.ReportList{Reports: make([]*pb.Report, 0, len(reports))}
+		for _, report := range reports {
+			list.Reports = append(list.Reports, reportToProto(report))
+		}
+		c.ProtoBuf(status, list)
+		return
+	}
+	c.JSON(status, gin.H{"reports": reports})
+}
+
+func respondUser(c *gin.Context, status int, user model.User) {
+	if wantsProtobuf(c) {
+		c.ProtoBuf(status, &pb.UserResponse{User: userToProto(user)})
+		return
+	}
+	c.JSON(status, gin.H{"user": user})
+}
+
+func reportToProto(report model.Report) *pb.Report {
+	return &pb.Report{
+		Id:         report.ID,
+		AuthorId:   report.AuthorID,
+		Count:      int32(report.Count),
+		Title:      report.Title,
+		Style:      report.Style,
+		Language:   report.Language,
+		Visibility: report.Visibility,
+	}
+}
+
+func reportFromProto(message *pb.Report) model.Report {
+	return model.Report{
+		ID:         message.GetId(),
+		AuthorID:   message.GetAuthorId(),
+		Count:      int(message.GetCount()),
+		Title:      message.GetTitle(),
+		Style:      message.GetStyle(),
+		Language:   message.GetLanguage(),
+		Visibility: message.GetVisibility(),
+	}
+}
+
+func userToProto(user model.User) *pb.User {
+	message := &pb.User{
+		Id:              user.ID,
+		Name:            proto.String(user.Name),
+		Status:          proto.String(user.Status),
+		Email:           proto.String(user.Email),
+		DisplayName:     proto.String(user.DisplayName),
+		Affiliation:     proto.String(user.Affiliation),
+		TimeZone:        proto.String(user.TimeZone),
+		DefaultStyle:    proto.String(user.DefaultStyle),
+		DefaultLanguage: proto.String(user.DefaultLanguage),
+		DefaultCount:    proto.Int32(int32(user.DefaultCount)),
+	}
+	if user.ReportCount != nil {
+		message.ReportCount = proto.Int32(int32(*user.ReportCount))
+	}
+	return message
+}
+
+// userFromProto reads a user to register. The report count is not the
+// client's to set and is left out.
+func userFromProto(message *pb.User) model.User {
+	return model.User{
+		ID:              message.GetId(),
+		Name:            message.GetName(),
+		Status:          message.GetStatus(),
+		Email:           message.GetEmail(),
+		DisplayName:     message.GetDisplayName(),
+		Affiliation:     message.GetAffiliation(),
+		TimeZone:        message.GetTimeZone(),
+		DefaultStyle:    message.GetDefaultStyle(),
+		DefaultLanguage: message.GetDefaultLanguage(),
+		DefaultCount:    int(message.GetDefaultCount()),
+	}
+}
+
+// userProfileFromProto reads an update of the profile of user ID; fields
+// left out of message are left unchanged.
+func userProfileFromProto(message *pb.User) (string, model.UserProfile) {
+	profile := model.UserProfile{
+		Name:            message.Name,
+		Email:           message.Email,
+		DisplayName:     message.DisplayName,
+		Affiliation:     message.Affiliation,
+		TimeZone:        message.TimeZone,
+		DefaultStyle:    message.DefaultStyle,
+		DefaultLanguage: message.DefaultLanguage,
+	}
+	if message.DefaultCount != nil {
+		count := int(message.GetDefaultCount())
+		profile.DefaultCount = &count
+	}
+	return message.GetId(), profile
+}
@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/pb"
+)
+
+// protoJSON is message in JSON under the field names of the .proto, which
+// are those of the JSON representation.
+func protoJSON(t *testing.T, message proto.Message) map[string]interface{} {
+	t.Helper()
+	data, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(message)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	return fields
+}
+
+// modelJSON is v as handlers send it in JSON.
+func modelJSON(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	return fields
+}
+
+// roundTrip encodes message in the wire format and decodes it again.
+func roundTrip[M proto.Message](t *testing.T, message M) M {
+	t.Helper()
+	data, err := proto.Marshal(message)
+	require.NoError(t, err)
+	decoded := message.ProtoReflect().New().Interface().(M)
+	require.NoError(t, proto.Unmarshal(data, decoded))
+	return decoded
+}
+
+var testReports = []model.Report{
+	{ID: "r1", AuthorID: "sato", Count: 800, Title: "クリーンアーキテクチャについて", Style: "essay", Language: "ja", Visibility: model.VisibilityPublic},
+	{ID: "r2", AuthorID: "sato", Count: 0, Title: "", Style: "definite", Language: "en", Visibility: model.VisibilityPrivate},
+	{},
+}
+
+func TestReportProto(t *testing.T) {
+	for _, report := range testReports {
+		t.Run(report.ID, func(t *testing.T) {
+			message := reportToProto(report)
+			assert.Equal(t, modelJSON(t, report), protoJSON(t, message))
+			assert.Equal(t, report, reportFromProto(roundTrip(t, message)))
+		})
+	}
+
+	// Fields that are not part of the representation are left out both ways.
+	report := testReports[0]
+	report.OrgID = "default"
+	report.UpdatedAt = time.Now()
+	assert.Equal(t, testReports[0], reportFromProto(roundTrip(t, reportToProto(report))))
+}
+
+// filled sets every field of the struct v points to that is part of the
+// JSON representation, so that tests cover fields added later without being
+// told about them.
+func filled(t *testing.T, v interface{}) {
+	t.Helper()
+	value := reflect.ValueOf(v).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+		switch f := value.Field(i); f.Kind() {
+		case reflect.String:
+			f.SetString(field.Name + " value")
+		case reflect.Int:
+			f.SetInt(int64(10 + i))
+		case reflect.Pointer:
+			if f.Type().Elem().Kind() != reflect.Int {
+				t.Fatalf("no test value for %s", field.Name)
+			}
+			n := 10 + i
+			f.Set(reflect.ValueOf(&n))
+		default:
+			t.Fatalf("no test value for %s", field.Name)
+		}
+	}
+}
+
+func TestUserProto(t *testing.T) {
+	var user model.User
+	filled(t, &user)
+	message := userToProto(user)
+	assert.Equal(t, modelJSON(t, user), protoJSON(t, message))
+
+	// Users read from requests leave out what is not the client's to set.
+	want := user
+	want.ReportCount = nil
+	assert.Equal(t, want, userFromProto(roundTrip(t, message)))
+
+	// Every profile field can be updated, and only those sent are.
+	ID, profile := userProfileFromProto(roundTrip(t, message))
+	assert.Equal(t, user.ID, ID)
+	fields := reflect.ValueOf(profile)
+	for i := 0; i < fields.NumField(); i++ {
+		assert.False(t, fields.Field(i).IsNil(), "%s missing from the message", fields.Type().Field(i).Name)
+	}
+	for name, value := range modelJSON(t, profile) {
+		assert.Equal(t, modelJSON(t, user)[name], value, name)
+	}
+	_, profile = userProfileFromProto(roundTrip(t, &pb.User{Id: user.ID, Email: message.Email}))
+	assert.Equal(t, model.UserProfile{Email: &user.Email}, profile)
+}
+
+func TestEnvelopeProto(t *testing.T) {
+	list := &pb.ReportList{}
+	for _, report := range testReports {
+		list.Reports = append(list.Reports, reportToProto(report))
+	}
+	assert.Equal(t, modelJSON(t, gin.H{"reports": testReports}), protoJSON(t, list))
+	decoded := roundTrip(t, list)
+	require.Len(t, decoded.GetReports(), len(testReports))
+	for i, message := range decoded.GetReports() {
+		assert.Equal(t, testReports[i], reportFromProto(message))
+	}
+
+	user := model.User{ID: "sato", Name: "佐藤"}
+	response := &pb.UserResponse{User: userToProto(user)}
+	assert.Equal(t, modelJSON(t, gin.H{"user": user}), protoJSON(t, response))
+	assert.Equal(t, user, userFromProto(roundTrip(t, response).GetUser()))
+
+	status := &pb.Status{Message: "Report ejected successfully"}
+	assert.Equal(t, modelJSON(t, gin.H{"message": status.Message}), protoJSON(t, status))
+	assert.Equal(t, status.Message, roundTrip(t, status).GetMessage())
+
+	failure := &pb.Error{Error: "Report not found"}
+	assert.Equal(t, modelJSON(t, gin.H{"error": failure.Error}), protoJSON(t, failure))
+	assert.Equal(t, failure.Error, roundTrip(t, failure).GetError())
+}
@@ -8,6 +8,14 @@ import (
     "repo-api/src/domain/model"
     "log"
     "github.com/google/uuid"
+    "strconv"
+)
+
+const (
+    defaultChangesLimit = 100
+    maxChangesLimit = 1000
+    defaultStatsDays = 30
+    maxStatsDays = 366
 )
 
 type ReportHandler interface {
@@ -15,6 +23,8 @@ type ReportHandler interface {
     HandleEject(c *gin.Context)
     HandleGet(c *gin.Context)
     HandleUpdate(c *gin.Context)
+    HandleGetChanges(c *gin.Context)
+    HandleUserStats(c *gin.Context)
 }
 
 func NewReportHandler(db *sql.DB, ar application.ReportApp) ReportHandler {
@@ -29,46 +39,91 @@ type reportHandler struct {
     database *sql.DB
 }
 
+// ReportRegisterRequest is the body of POST /report and the params of
+// ReportApp.Register over JSON-RPC. The ID is assigned by the server.
+type ReportRegisterRequest struct {
+    AuthorID string `json:"author_id"`
+    Count int `json:"count"`
+    Title string `json:"title"`
+    Style string `json:"style"`
+    Language string `json:"language"`
+    Visibility string `json:"visibility"`
+}
+
+// Report is the report to register, without an ID.
+func (r ReportRegisterRequest) Report() model.Report {
+    return model.Report{AuthorID: r.AuthorID, Count: r.Count, Title: r.Title, Style: r.Style, Language: r.Language, Visibility: r.Visibility}
+}
+
+// ReportUpdateRequest is the body of PUT /report and the params of
+// ReportApp.Update over JSON-RPC. Reports change authors only when theirs
+// is deleted with policy=reassign.
+type ReportUpdateRequest struct {
+    ID string `json:"id"`
+    Count int `json:"count"`
+    Title string `json:"title"`
+    Style string `json:"style"`
+    Language string `json:"language"`
+    Visibility string `json:"visibility"`
+}
+
 func (r *reportHandler) HandleRegisterReport(c *gin.Context) {
-    var report model.Report
-    if err := c.BindJSON(&report); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+    var req ReportRegisterRequest
+    if err := bindReportRegister(c, &req); err != nil {
+        respondBodyError(c, err)
         return
     }
+    report := req.Report()
 
-    if report.AuthorID == "" || report.Count == 0 || report.Title == "" || report.Style == "" || report.Language == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "AuthorID, Count, Title, Style, and Language are required"})
+    // Count, Style and Language default to the author's profile.
+    if report.AuthorID == "" || report.Title == "" {
+        respondError(c, http.StatusBadRequest, "AuthorID and Title are required")
         return
     }
 
     report.ID = uuid.New().String()
     
-    if err := r.reportApp.Register(r.database, report); err != nil {
+    if _, err := r.reportApp.Register(r.database, PrincipalFrom(c), report); err != nil {
         log.Printf("Error retrieving user: %v", err)
         if err.Error() == "author does not exist" {
-            c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+            respondError(c, http.StatusNotFound, "Author not found")
+        } else if err.Error() == "forbidden" {
+            respondError(c, http.StatusForbidden, "Cannot register reports for another author")
+        } else if err.Error() == "invalid visibility" {
+            respondError(c, http.StatusBadRequest, "Visibility must be private or public")
+        } else if err.Error() == "author not active" {
+            respondError(c, http.StatusConflict, "Author is suspended or deactivated")
+        } else if err.Error() == "missing fields" {
+            respondError(c, http.StatusBadRequest, "Count, Style, and Language are required unless the author has defaults for them")
         } else {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register report"})
+            respondError(c, http.StatusInternalServerError, "Failed to register report")
         }
         return
     }
     
-    c.JSON(http.StatusOK, gin.H{"message": "Report registered successfully"})
+    respondMessage(c, http.StatusOK, "Report registered successfully")
 }
 
 func (r *reportHandler) HandleEject(c *gin.Context) {
     ID := c.Query("id")
     if ID == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "ID is required"})
+        respondError(c, http.StatusBadRequest, "ID is required")
         return
     }
 
-    if err := r.reportApp.Eject(r.database, ID); err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to eject report"})
+    if err := r.reportApp.Eject(r.database, PrincipalFrom(c), ID); err != nil {
+        log.Printf("Error ejecting report: %v", err)
+        if err.Error() == "report not found" {
+            respondError(c, http.StatusNotFound, "Report not found")
+        } else if err.Error() == "forbidden" {
+            respondError(c, http.StatusForbidden, "Only the author can eject this report")
+        } else {
+            respondError(c, http.StatusInternalServerError, "Failed to eject report")
+        }
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Report ejected successfully"})
+    respondMessage(c, http.StatusOK, "Report ejected successfully")
 }
 
 func (r *reportHandler) HandleGet(c *gin.Context) {
@@ -77,18 +132,24 @@ func (r *reportHandler) HandleGet(c *gin.Context) {
 
     if ID := c.Query("id"); ID != "" {
         var report []model.Report
-        report, err = r.reportApp.Get(r.database, ID, "", "", "", "")
-        if err == sql.ErrNoRows {
-            c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+        report, err = r.reportApp.Get(r.database, PrincipalFrom(c), ID, "", "", "", "")
+        if err != nil && err.Error() == "report not found" {
+            respondError(c, http.StatusNotFound, "Report not found")
+            return
+        } else if err != nil && err.Error() == "forbidden" {
+            respondError(c, http.StatusForbidden, "This report is private")
             return
         } else if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve report"})
+            respondError(c, http.StatusInternalServerError, "Failed to retrieve report")
             return
         }
         reports = append(reports, report...)
+        if len(reports) == 1 {
+            setLastModified(c, reports[0].UpdatedAt)
+        }
     } else {
         if AuthorID := c.Query("author_id"); AuthorID == "" {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "AuthorID is required"})
+            respondError(c, http.StatusBadRequest, "AuthorID is required")
             return
         }
         authorID := c.Query("author_id")
@@ -96,34 +157,113 @@ func (r *reportHandler) HandleGet(c *gin.Context) {
         style := c.Query("style")
         language := c.Query("language")
 
-        reports, err = r.reportApp.Get(r.database, "", authorID, title, style, language)
+        reports, err = r.reportApp.Get(r.database, PrincipalFrom(c), "", authorID, title, style, language)
         if err != nil {
             if err.Error() == "author does not exist" {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+                respondError(c, http.StatusNotFound, "Author not found")
                 return
             } else if err.Error() == "report not found" {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+                respondError(c, http.StatusNotFound, "Report not found")
                 return
             }
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reports"})
+            respondError(c, http.StatusInternalServerError, "Failed to retrieve reports")
             return
         }
     }
-    
-    c.JSON(http.StatusOK, gin.H{"reports": reports})
+
+    // Lists go without Last-Modified: reports leaving a list, by being
+    // ejected or made private, change it without a newer updated_at to go
+    // by. Their ETag covers that.
+    respondReports(c, http.StatusOK, reports)
 }
 
 func (r *reportHandler) HandleUpdate(c *gin.Context) {
-    var report model.Report
-    if err := c.BindJSON(&report); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+    var req ReportUpdateRequest
+    if err := bindReportUpdate(c, &req); err != nil {
+        respondBodyError(c, err)
+        return
+    }
+
+    if err := r.reportApp.Update(r.database, PrincipalFrom(c), req.ID, req.Count, req.Title, req.Style, req.Language, req.Visibility); err != nil {
+        log.Printf("Error updating report: %v", err)
+        if err.Error() == "report not found" {
+            respondError(c, http.StatusNotFound, "Report not found")
+        } else if err.Error() == "forbidden" {
+            respondError(c, http.StatusForbidden, "Only the author and editors can update this report")
+        } else if err.Error() == "invalid visibility" {
+            respondError(c, http.StatusBadRequest, "Visibility must be private or public")
+        } else {
+            respondError(c, http.StatusInternalServerError, "Failed to update report")
+        }
+        return
+    }
+
+    respondMessage(c, http.StatusOK, "Report updated successfully")
+}
+
+func (r *reportHandler) HandleGetChanges(c *gin.Context) {
+    authorID := c.Query("author_id")
+    if authorID == "" {
+        respondError(c, http.StatusBadRequest, "AuthorID is required")
         return
     }
 
-    if err := r.reportApp.Update(r.database, report.ID, report.Count, report.Title, report.Style, report.Language); err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+    var since uint64
+    if raw := c.Query("since"); raw != "" {
+        parsed, err := strconv.ParseUint(raw, 10, 64)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, "since must be a cursor returned by this endpoint")
+            return
+        }
+        since = parsed
+    }
+
+    limit := defaultChangesLimit
+    if raw := c.Query("limit"); raw != "" {
+        parsed, err := strconv.Atoi(raw)
+        if err != nil || parsed < 1 || parsed > maxChangesLimit {
+            respondError(c, http.StatusBadRequest, "limit must be between 1 and 1000")
+            return
+        }
+        limit = parsed
+    }
+
+    feed, err := r.reportApp.Changes(r.database, PrincipalFrom(c), authorID, since, limit)
+    if err != nil {
+        log.Printf("Error retrieving changes: %v", err)
+        if err.Error() == "forbidden" {
+            respondError(c, http.StatusForbidden, "Cannot read the changes of another author")
+        } else {
+            respondError(c, http.StatusInternalServerError, "Failed to retrieve changes")
+        }
+        return
+    }
+
+    c.JSON(http.StatusOK, feed)
+}
+
+// HandleUserStats serves GET /users/:id/stats.
+func (r *reportHandler) HandleUserStats(c *gin.Context) {
+    days := defaultStatsDays
+    if raw := c.Query("days"); raw != "" {
+        parsed, err := strconv.Atoi(raw)
+        if err != nil || parsed < 1 || parsed > maxStatsDays {
+            respondError(c, http.StatusBadRequest, "days must be between 1 and 366")
+            return
+        }
+        days = parsed
+    }
+
+    stats, err := r.reportApp.Stats(r.database, PrincipalFrom(c), c.Param("id"), days)
+    if err != nil {
+        log.Printf("Error retrieving user stats: %v", err)
+        if err.Error() == "user not found" {
+            respondError(c, http.StatusNotFound, "User not found")
+        } else {
+            respondError(c, http.StatusInternalServerError, "Failed to retrieve user stats")
+        }
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Report updated successfully"})
+    c.JSON(http.StatusOK, stats)
 }
@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/presentation/jsonstrict"
+)
+
+type RoleHandler interface {
+	HandleGet(c *gin.Context)
+	HandleAssign(c *gin.Context)
+	HandleGetPermissions(c *gin.Context)
+}
+
+func NewRoleHandler(db *sql.DB, ar application.RoleApp) RoleHandler {
+	return &roleHandler{
+		database: db,
+		roleApp:  ar,
+	}
+}
+
+type roleHandler struct {
+	roleApp  application.RoleApp
+	database *sql.DB
+}
+
+type roleRequest struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+func (r *roleHandler) HandleGet(c *gin.Context) {
+	principal := PrincipalFrom(c)
+	UserID := c.Query("user_id")
+	if UserID == "" {
+		UserID = principal.UserID
+	}
+
+	role, err := r.roleApp.Get(r.database, principal, UserID)
+	if err != nil {
+		log.Printf("Error retrieving role: %v", err)
+		switch err.Error() {
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Cannot read the role of another user")
+		case "user not found":
+			respondError(c, http.StatusNotFound, "User not found")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to get role")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+func (r *roleHandler) HandleAssign(c *gin.Context) {
+	var req roleRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+	if req.UserID == "" || req.Role == "" {
+		respondError(c, http.StatusBadRequest, "UserID and Role are required")
+		return
+	}
+
+	role, err := r.roleApp.Assign(r.database, PrincipalFrom(c), req.UserID, req.Role)
+	if err != nil {
+		log.Printf("Error assigning role: %v", err)
+		switch err.Error() {
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Only administrators can assign roles")
+		case "invalid role":
+			respondError(c, http.StatusBadRequest, "Unknown role")
+		case "cannot change own role":
+			respondError(c, http.StatusConflict, "Administrators cannot change their own role")
+		case "user not found":
+			respondError(c, http.StatusNotFound, "User not found")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to assign role")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+// HandleGetPermissions answers what the caller may do with the token it
+// used, which for an API key can be less than its role allows.
+func (r *roleHandler) HandleGetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"permissions": r.roleApp.Permissions(PrincipalFrom(c))})
+}
@@ -0,0 +1,167 @@
+package rest
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/jsonstrict"
+)
+
+type SessionHandler interface {
+	HandleLogin(c *gin.Context)
+	HandleRefresh(c *gin.Context)
+	HandleLogout(c *gin.Context)
+	HandleChangePassword(c *gin.Context)
+}
+
+func NewSessionHandler(db *sql.DB, as application.SessionApp) SessionHandler {
+	return &sessionHandler{
+		database:   db,
+		sessionApp: as,
+	}
+}
+
+type sessionHandler struct {
+	sessionApp application.SessionApp
+	database   *sql.DB
+}
+
+type loginRequest struct {
+	ID       string `json:"id"`
+	Password string `json:"password"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type passwordRequest struct {
+	UserID          string `json:"user_id"`
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (s *sessionHandler) HandleLogin(c *gin.Context) {
+	var req loginRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+	if req.ID == "" || req.Password == "" {
+		respondError(c, http.StatusBadRequest, "ID and Password are required")
+		return
+	}
+
+	tokens, err := s.sessionApp.Login(s.database, req.ID, req.Password)
+	if err != nil {
+		var locked *application.LoginLockedError
+		switch {
+		case errors.As(err, &locked):
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+			respondError(c, http.StatusTooManyRequests, "Too many failed login attempts")
+		case err.Error() == "invalid credentials":
+			respondError(c, http.StatusUnauthorized, "Invalid ID or password")
+		default:
+			log.Printf("Error logging in: %v", err)
+			respondError(c, http.StatusInternalServerError, "Failed to log in")
+		}
+		return
+	}
+	respondTokens(c, tokens)
+}
+
+func (s *sessionHandler) HandleRefresh(c *gin.Context) {
+	var req refreshRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		respondError(c, http.StatusBadRequest, "RefreshToken is required")
+		return
+	}
+
+	tokens, err := s.sessionApp.Refresh(s.database, req.RefreshToken)
+	if err != nil {
+		if err.Error() == "invalid refresh token" {
+			respondError(c, http.StatusUnauthorized, "Invalid refresh token")
+		} else {
+			log.Printf("Error refreshing session: %v", err)
+			respondError(c, http.StatusInternalServerError, "Failed to refresh session")
+		}
+		return
+	}
+	respondTokens(c, tokens)
+}
+
+func (s *sessionHandler) HandleLogout(c *gin.Context) {
+	principal := PrincipalFrom(c)
+	if principal.SessionID == "" {
+		respondError(c, http.StatusBadRequest, "API keys cannot log out; revoke the key instead")
+		return
+	}
+
+	if err := s.sessionApp.Logout(s.database, principal.SessionID); err != nil {
+		log.Printf("Error logging out: %v", err)
+		respondError(c, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+	respondMessage(c, http.StatusOK, "Logged out successfully")
+}
+
+// HandleChangePassword lets users change their own password, proving it
+// with the current one, and users:admin set anyone's.
+func (s *sessionHandler) HandleChangePassword(c *gin.Context) {
+	var req passwordRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	principal := PrincipalFrom(c)
+	if req.UserID == "" {
+		req.UserID = principal.UserID
+	}
+	if req.NewPassword == "" {
+		respondError(c, http.StatusBadRequest, "NewPassword is required")
+		return
+	}
+
+	var err error
+	if req.UserID == principal.UserID {
+		err = s.sessionApp.ChangePassword(s.database, principal.OrgID, req.UserID, req.CurrentPassword, req.NewPassword)
+	} else if application.HasScope(principal.Scopes, model.ScopeUsersAdmin) {
+		err = s.sessionApp.SetPassword(s.database, principal.OrgID, req.UserID, req.NewPassword)
+	} else {
+		respondError(c, http.StatusForbidden, "Cannot change the password of another user")
+		return
+	}
+	if err != nil {
+		log.Printf("Error changing password: %v", err)
+		switch err.Error() {
+		case "invalid credentials":
+			respondError(c, http.StatusForbidden, "Current password is incorrect")
+		case "invalid password":
+			respondError(c, http.StatusBadRequest, "Password must be 8 to 72 bytes long")
+		case "user not found":
+			respondError(c, http.StatusNotFound, "User not found")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to change password")
+		}
+		return
+	}
+	respondMessage(c, http.StatusOK, "Password changed successfully")
+}
+
+// respondTokens follows RFC 6749 in keeping tokens out of caches.
+func respondTokens(c *gin.Context, tokens model.TokenPair) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
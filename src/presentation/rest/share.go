@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/presentation/jsonstrict"
+)
+
+type ShareHandler interface {
+	HandleGrant(c *gin.Context)
+	HandleRevoke(c *gin.Context)
+	HandleList(c *gin.Context)
+	HandleSharedWithMe(c *gin.Context)
+}
+
+func NewShareHandler(db *sql.DB, as application.ShareApp) ShareHandler {
+	return &shareHandler{
+		database: db,
+		shareApp: as,
+	}
+}
+
+type shareHandler struct {
+	shareApp application.ShareApp
+	database *sql.DB
+}
+
+type shareRequest struct {
+	ReportID string `json:"report_id"`
+	UserID   string `json:"user_id"`
+	Level    string `json:"level"`
+}
+
+func (s *shareHandler) HandleGrant(c *gin.Context) {
+	var req shareRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+	if req.ReportID == "" || req.UserID == "" || req.Level == "" {
+		respondError(c, http.StatusBadRequest, "ReportID, UserID, and Level are required")
+		return
+	}
+
+	share, err := s.shareApp.Grant(s.database, PrincipalFrom(c), req.ReportID, req.UserID, req.Level)
+	if err != nil {
+		log.Printf("Error sharing report: %v", err)
+		switch err.Error() {
+		case "invalid share level":
+			respondError(c, http.StatusBadRequest, "Level must be read, comment, or edit")
+		case "cannot share with the author":
+			respondError(c, http.StatusBadRequest, "Cannot share a report with its author")
+		case "report not found":
+			respondError(c, http.StatusNotFound, "Report not found")
+		case "user not found":
+			respondError(c, http.StatusNotFound, "User not found")
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Only the author can share this report")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to share report")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"share": share})
+}
+
+func (s *shareHandler) HandleRevoke(c *gin.Context) {
+	reportID := c.Query("report_id")
+	userID := c.Query("user_id")
+	if reportID == "" || userID == "" {
+		respondError(c, http.StatusBadRequest, "ReportID and UserID are required")
+		return
+	}
+
+	if err := s.shareApp.Revoke(s.database, PrincipalFrom(c), reportID, userID); err != nil {
+		log.Printf("Error revoking report share: %v", err)
+		switch err.Error() {
+		case "report not found":
+			respondError(c, http.StatusNotFound, "Report not found")
+		case "share not found":
+			respondError(c, http.StatusNotFound, "Share not found")
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Only the author can revoke shares of this report")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to revoke share")
+		}
+		return
+	}
+	respondMessage(c, http.StatusOK, "Share revoked successfully")
+}
+
+func (s *shareHandler) HandleList(c *gin.Context) {
+	reportID := c.Query("report_id")
+	if reportID == "" {
+		respondError(c, http.StatusBadRequest, "ReportID is required")
+		return
+	}
+
+	shares, err := s.shareApp.List(s.database, PrincipalFrom(c), reportID)
+	if err != nil {
+		log.Printf("Error listing report shares: %v", err)
+		switch err.Error() {
+		case "report not found":
+			respondError(c, http.StatusNotFound, "Report not found")
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Only the author can list shares of this report")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to get shares")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+func (s *shareHandler) HandleSharedWithMe(c *gin.Context) {
+	reports, err := s.shareApp.SharedWith(s.database, PrincipalFrom(c))
+	if err != nil {
+		log.Printf("Error listing shared reports: %v", err)
+		respondError(c, http.StatusInternalServerError, "Failed to get shared reports")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
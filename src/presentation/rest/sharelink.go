@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/jsonstrict"
+)
+
+// publicReportPath is where share links point; the token follows it.
+const publicReportPath = "/public/report/"
+
+type ShareLinkHandler interface {
+	HandleCreate(c *gin.Context)
+	HandleList(c *gin.Context)
+	HandleRevoke(c *gin.Context)
+	HandleOpen(c *gin.Context)
+}
+
+func NewShareLinkHandler(db *sql.DB, as application.ShareLinkApp) ShareLinkHandler {
+	return &shareLinkHandler{
+		database:     db,
+		shareLinkApp: as,
+	}
+}
+
+type shareLinkHandler struct {
+	shareLinkApp application.ShareLinkApp
+	database     *sql.DB
+}
+
+type shareLinkRequest struct {
+	ReportID         string `json:"report_id"`
+	ExpiresInSeconds int    `json:"expires_in_seconds"`
+	SingleUse        bool   `json:"single_use"`
+}
+
+func (s *shareLinkHandler) HandleCreate(c *gin.Context) {
+	var req shareLinkRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+	if req.ReportID == "" {
+		respondError(c, http.StatusBadRequest, "ReportID is required")
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInSeconds) * time.Second
+	link, token, err := s.shareLinkApp.Create(s.database, PrincipalFrom(c), req.ReportID, ttl, req.SingleUse)
+	if err != nil {
+		log.Printf("Error creating share link: %v", err)
+		switch err.Error() {
+		case "invalid expiry":
+			respondError(c, http.StatusBadRequest, "expires_in_seconds is out of range")
+		case "report not found":
+			respondError(c, http.StatusNotFound, "Report not found")
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Only the author can create links to this report")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to create share link")
+		}
+		return
+	}
+
+	// The token is only ever returned here.
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"link": link, "token": token, "path": publicReportPath + token})
+}
+
+func (s *shareLinkHandler) HandleList(c *gin.Context) {
+	reportID := c.Query("report_id")
+	if reportID == "" {
+		respondError(c, http.StatusBadRequest, "ReportID is required")
+		return
+	}
+
+	links, err := s.shareLinkApp.List(s.database, PrincipalFrom(c), reportID)
+	if err != nil {
+		log.Printf("Error listing share links: %v", err)
+		switch err.Error() {
+		case "report not found":
+			respondError(c, http.StatusNotFound, "Report not found")
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Only the author can list links to this report")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to get share links")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"links": links})
+}
+
+func (s *shareLinkHandler) HandleRevoke(c *gin.Context) {
+	ID := c.Query("id")
+	if ID == "" {
+		respondError(c, http.StatusBadRequest, "ID is required")
+		return
+	}
+
+	link, err := s.shareLinkApp.Revoke(s.database, PrincipalFrom(c), ID)
+	if err != nil {
+		log.Printf("Error revoking share link: %v", err)
+		switch err.Error() {
+		case "link not found":
+			respondError(c, http.StatusNotFound, "Share link not found")
+		case "forbidden":
+			respondError(c, http.StatusForbidden, "Only the author can revoke links to this report")
+		default:
+			respondError(c, http.StatusInternalServerError, "Failed to revoke share link")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"link": link})
+}
+
+// HandleOpen serves the report behind a share link without authentication.
+// Links that were valid once answer 410 Gone; anything else 404.
+func (s *shareLinkHandler) HandleOpen(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	report, err := s.shareLinkApp.Open(s.database, c.Param("token"))
+	if err != nil {
+		switch err.Error() {
+		case "invalid link", "report not found":
+			respondError(c, http.StatusNotFound, "Link not found")
+		case "link expired":
+			respondError(c, http.StatusGone, "Link has expired")
+		case "link revoked":
+			respondError(c, http.StatusGone, "Link has been revoked")
+		case "link already used":
+			respondError(c, http.StatusGone, "Link has already been used")
+		default:
+			log.Printf("Error opening share link: %v", err)
+			respondError(c, http.StatusInternalServerError, "Failed to open link")
+		}
+		return
+	}
+	respondReports(c, http.StatusOK, []model.Report{report})
+}
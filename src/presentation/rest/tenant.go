@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+)
+
+// OrganizationHeader optionally names the organization a client expects to
+// act in. Requests whose token belongs to another organization are refused
+// instead of silently acting in the token's one.
+const OrganizationHeader = "X-Organization-ID"
+
+// Tenant confines the principal stored by Authenticate to the organization of
+// its user. It must run after Authenticate and before any handler.
+func Tenant(db *sql.DB, oa application.OrganizationApp) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := oa.Resolve(db, PrincipalFrom(c), c.GetHeader(OrganizationHeader))
+		if err != nil {
+			switch err.Error() {
+			case "organization mismatch":
+				respondError(c, http.StatusForbidden, "The token does not belong to organization "+c.GetHeader(OrganizationHeader))
+			case "user not found":
+				c.Header("WWW-Authenticate", `Bearer realm="repoapi", error="invalid_token"`)
+				respondError(c, http.StatusUnauthorized, "The user of this token no longer exists")
+			default:
+				log.Printf("Error resolving organization: %v", err)
+				respondError(c, http.StatusInternalServerError, "Failed to resolve organization")
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
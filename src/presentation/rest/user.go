@@ -6,14 +6,26 @@ import (
     "github.com/gin-gonic/gin"
     "repo-api/src/application"
     "repo-api/src/domain/model"
+    "repo-api/src/presentation/jsonstrict"
     "log"
     "errors"
+    "strconv"
+    "strings"
+)
+
+const (
+  defaultUsersLimit = 50
+  maxUsersLimit = 1000
 )
 
 type UserHandler interface {
   HandleRegisterUser(c *gin.Context)
   HandleGet(c *gin.Context)
   HandleUpdate(c *gin.Context)
+  HandleList(c *gin.Context)
+  HandleDelete(c *gin.Context)
+  HandleRename(c *gin.Context)
+  HandleSetStatus(c *gin.Context)
 }
 
 func NewUserHandler(db *sql.DB, au application.UserApp) UserHandler {
@@ -28,69 +40,337 @@ type userHandler struct {
   database *sql.DB
 }
 
+// UserRegisterRequest is the body of POST /user and the params of
+// UserApp.Register over JSON-RPC.
+type UserRegisterRequest struct {
+  ID string `json:"id"`
+  Name string `json:"name"`
+  Status string `json:"status"`
+  Email string `json:"email"`
+  DisplayName string `json:"display_name"`
+  Affiliation string `json:"affiliation"`
+  TimeZone string `json:"time_zone"`
+  DefaultStyle string `json:"default_style"`
+  DefaultLanguage string `json:"default_language"`
+  DefaultCount int `json:"default_count"`
+}
+
+// User is the user to register.
+func (r UserRegisterRequest) User() model.User {
+  return model.User{
+    ID: r.ID,
+    Name: r.Name,
+    Status: r.Status,
+    Email: r.Email,
+    DisplayName: r.DisplayName,
+    Affiliation: r.Affiliation,
+    TimeZone: r.TimeZone,
+    DefaultStyle: r.DefaultStyle,
+    DefaultLanguage: r.DefaultLanguage,
+    DefaultCount: r.DefaultCount,
+  }
+}
+
 func (u userHandler) HandleRegisterUser(c *gin.Context) {
-  var user model.User
-  if err := c.BindJSON(&user); err != nil {
-    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+  var req UserRegisterRequest
+  if err := bindUserRegister(c, &req); err != nil {
+    respondBodyError(c, err)
     return
   }
+  user := req.User()
 
   if user.ID == "" || user.Name == "" {
-     c.JSON(http.StatusBadRequest, gin.H{"error": "ID and Name are required"})
+     respondError(c, http.StatusBadRequest, "ID and Name are required")
      return
    }
 
-  if err := u.userApp.Register(u.database, user.ID, user.Name); err != nil {
+  if err := u.userApp.Register(u.database, PrincipalFrom(c), user); err != nil {
     log.Printf("Error registering user: %v", err)
-    c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+    if respondProfileError(c, err) {
+      return
+    }
+    if err.Error() == "forbidden" {
+      respondError(c, http.StatusForbidden, "Only administrators can register users")
+    } else if err.Error() == "user id unavailable" {
+      respondError(c, http.StatusConflict, "This user ID is not available")
+    } else {
+      respondError(c, http.StatusInternalServerError, "Failed to register user")
+    }
     return
   }
 
-  c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+  respondMessage(c, http.StatusOK, "User registered successfully")
 }
 
 func (u userHandler) HandleGet(c *gin.Context) {
   ID := c.Query("id")
   if ID == "" {
-    c.JSON(http.StatusBadRequest, gin.H{"error": "ID is required"})
+    respondError(c, http.StatusBadRequest, "ID is required")
     return
   }
-  user, err := u.userApp.Get(u.database, ID)
+  user, err := u.userApp.Get(u.database, PrincipalFrom(c), ID)
   if err != nil {
     log.Printf("Error retrieving user: %v", err)
     if errors.Is(err, sql.ErrNoRows) {
-        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+        // A renamed user is found under their old ID for a while. The
+        // redirect is temporary, since the old ID may be given to someone
+        // else once the alias expires.
+        if NewID, err := u.userApp.MovedTo(u.database, PrincipalFrom(c), ID); err == nil {
+            query := c.Request.URL.Query()
+            query.Set("id", NewID)
+            c.Redirect(http.StatusTemporaryRedirect, c.Request.URL.Path+"?"+query.Encode())
+            return
+        }
+        respondError(c, http.StatusNotFound, "User not found")
     } else {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+        respondError(c, http.StatusInternalServerError, "Failed to get user")
     }
     return
   }
 
-  c.JSON(http.StatusOK, gin.H{"user": user})
+  setLastModified(c, user.UpdatedAt)
+  respondUser(c, http.StatusOK, user)
 }
 
+// profileErrors are the messages for the validation errors of users. The
+// JSON-RPC handlers answer with them too, through ProfileError.
+var profileErrors = map[string]string{
+  "invalid email": "Email must be a valid email address",
+  "invalid time zone": "TimeZone must be an IANA time zone such as Asia/Tokyo",
+  "invalid default count": "DefaultCount must not be negative",
+  "name required": "Name must not be empty",
+  "invalid status": "Status must be active, suspended or deactivated",
+}
+
+// ProfileError returns the message for err if it is a validation error of a
+// user, which is the client's fault.
+func ProfileError(err error) (string, bool) {
+  message, ok := profileErrors[err.Error()]
+  return message, ok
+}
+
+// respondProfileError answers the validation errors of users, reporting
+// whether err was one.
+func respondProfileError(c *gin.Context, err error) bool {
+  message, ok := ProfileError(err)
+  if ok {
+    respondError(c, http.StatusBadRequest, message)
+  }
+  return ok
+}
+
+// UserUpdateRequest is the body of PUT /user and the params of
+// UserApp.Update over JSON-RPC.
+type UserUpdateRequest struct {
+  ID string `json:"id"`
+  model.UserProfile
+}
+
+// HandleUpdate changes only the fields present in the request, so clients
+// that send just id and name keep the rest of the profile.
 func (u userHandler) HandleUpdate(c *gin.Context) {
-  var user model.User
-  if err := c.BindJSON(&user); err != nil {
-    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+  var req UserUpdateRequest
+  if err := bindUserUpdate(c, &req); err != nil {
+    respondBodyError(c, err)
     return
   }
-  
-  if user.ID == "" || user.Name == "" {
-     c.JSON(http.StatusBadRequest, gin.H{"error": "ID and Name are required"})
+
+  if req.ID == "" {
+     respondError(c, http.StatusBadRequest, "ID is required")
      return
    }
 
-  err := u.userApp.Update(u.database, user.ID, user.Name); 
+  _, err := u.userApp.UpdateProfile(u.database, PrincipalFrom(c), req.ID, req.UserProfile)
   if err != nil {
     log.Printf("Error updating user: %v", err)
+    if respondProfileError(c, err) {
+      return
+    }
     if err.Error() == "user not found" {
-      c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+      respondError(c, http.StatusNotFound, "User not found")
+    } else if err.Error() == "forbidden" {
+      respondError(c, http.StatusForbidden, "Cannot update another user")
+    } else {
+      respondError(c, http.StatusInternalServerError, "Failed to update user")
+    }
+    return
+  }
+
+  respondMessage(c, http.StatusOK, "User updated successfully")
+}
+
+// HandleList takes the sort order as a column, descending when prefixed with
+// "-", as in sort=-report_count.
+func (u userHandler) HandleList(c *gin.Context) {
+  query := model.UserQuery{
+    Search: c.Query("q"),
+    Match: c.Query("match"),
+    Status: c.Query("status"),
+    Sort: strings.TrimPrefix(c.Query("sort"), "-"),
+    Descending: strings.HasPrefix(c.Query("sort"), "-"),
+    Limit: defaultUsersLimit,
+  }
+  if raw := c.Query("offset"); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 0 {
+      respondError(c, http.StatusBadRequest, "offset must be a non-negative integer")
+      return
+    }
+    query.Offset = parsed
+  }
+  if raw := c.Query("limit"); raw != "" {
+    parsed, err := strconv.Atoi(raw)
+    if err != nil || parsed < 1 || parsed > maxUsersLimit {
+      respondError(c, http.StatusBadRequest, "limit must be between 1 and 1000")
+      return
+    }
+    query.Limit = parsed
+  }
+  if raw := c.Query("with_report_count"); raw != "" {
+    parsed, err := strconv.ParseBool(raw)
+    if err != nil {
+      respondError(c, http.StatusBadRequest, "with_report_count must be a boolean")
+      return
+    }
+    query.WithReportCount = parsed
+  }
+
+  page, err := u.userApp.Search(u.database, PrincipalFrom(c), query)
+  if err != nil {
+    log.Printf("Error listing users: %v", err)
+    if err.Error() == "invalid match" {
+      respondError(c, http.StatusBadRequest, "match must be prefix or contains")
+    } else if err.Error() == "invalid sort" {
+      respondError(c, http.StatusBadRequest, "sort must be id, name, updated_at or report_count")
+    } else if err.Error() == "invalid status" {
+      respondError(c, http.StatusBadRequest, "status must be active, suspended or deactivated")
     } else {
-      c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+      respondError(c, http.StatusInternalServerError, "Failed to list users")
+    }
+    return
+  }
+
+  c.JSON(http.StatusOK, page)
+}
+
+// HandleDelete requires the policy for the user's reports, so that they are
+// never deleted by accident.
+func (u userHandler) HandleDelete(c *gin.Context) {
+  ID := c.Query("id")
+  if ID == "" {
+    respondError(c, http.StatusBadRequest, "ID is required")
+    return
+  }
+  deletion := model.UserDeletion{Policy: c.Query("policy"), ReassignTo: c.Query("reassign_to")}
+  if deletion.Policy == "" {
+    respondError(c, http.StatusBadRequest, "policy is required")
+    return
+  }
+  if deletion.Policy == model.DeleteReassign && deletion.ReassignTo == "" {
+    respondError(c, http.StatusBadRequest, "reassign_to is required with the reassign policy")
+    return
+  }
+
+  deletion, err := u.userApp.Delete(u.database, PrincipalFrom(c), ID, deletion)
+  if err != nil {
+    log.Printf("Error deleting user: %v", err)
+    switch err.Error() {
+    case "forbidden":
+      respondError(c, http.StatusForbidden, "Only administrators can delete users")
+    case "invalid policy":
+      respondError(c, http.StatusBadRequest, "policy must be block, cascade or reassign")
+    case "cannot delete self":
+      respondError(c, http.StatusConflict, "Cannot delete yourself")
+    case "user not found":
+      respondError(c, http.StatusNotFound, "User not found")
+    case "reassign target not found":
+      respondError(c, http.StatusNotFound, "The user to reassign the reports to was not found")
+    case "invalid reassign target":
+      respondError(c, http.StatusBadRequest, "Reports can only be reassigned to another active user")
+    case "user has reports":
+      respondError(c, http.StatusConflict, "User has reports; choose the cascade or reassign policy")
+    default:
+      respondError(c, http.StatusInternalServerError, "Failed to delete user")
+    }
+    return
+  }
+
+  c.JSON(http.StatusOK, gin.H{"deletion": deletion})
+}
+
+type renameRequest struct {
+  ID string `json:"id"`
+  NewID string `json:"new_id"`
+}
+
+func (u userHandler) HandleRename(c *gin.Context) {
+  var req renameRequest
+  if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+    respondBodyError(c, err)
+    return
+  }
+  if req.ID == "" || req.NewID == "" {
+    respondError(c, http.StatusBadRequest, "ID and NewID are required")
+    return
+  }
+
+  rename, err := u.userApp.Rename(u.database, PrincipalFrom(c), req.ID, req.NewID)
+  if err != nil {
+    log.Printf("Error renaming user: %v", err)
+    switch err.Error() {
+    case "forbidden":
+      respondError(c, http.StatusForbidden, "Only administrators can change user IDs")
+    case "invalid user id":
+      respondError(c, http.StatusBadRequest, "NewID must differ from ID")
+    case "user not found":
+      respondError(c, http.StatusNotFound, "User not found")
+    case "user id taken":
+      respondError(c, http.StatusConflict, "Another user has or recently had this ID")
+    default:
+      respondError(c, http.StatusInternalServerError, "Failed to change user ID")
+    }
+    return
+  }
+
+  c.JSON(http.StatusOK, gin.H{"rename": rename})
+}
+
+type statusRequest struct {
+  ID string `json:"id"`
+  Status string `json:"status"`
+  Reason string `json:"reason"`
+}
+
+func (u userHandler) HandleSetStatus(c *gin.Context) {
+  var req statusRequest
+  if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+    respondBodyError(c, err)
+    return
+  }
+  if req.ID == "" || req.Status == "" {
+    respondError(c, http.StatusBadRequest, "ID and Status are required")
+    return
+  }
+
+  change, err := u.userApp.SetStatus(u.database, PrincipalFrom(c), req.ID, req.Status, req.Reason)
+  if err != nil {
+    log.Printf("Error changing user status: %v", err)
+    switch err.Error() {
+    case "forbidden":
+      respondError(c, http.StatusForbidden, "Only administrators can change user status")
+    case "invalid status":
+      respondError(c, http.StatusBadRequest, "Status must be active, suspended or deactivated")
+    case "user not found":
+      respondError(c, http.StatusNotFound, "User not found")
+    case "cannot change own status":
+      respondError(c, http.StatusConflict, "Cannot change your own status")
+    case "invalid status transition":
+      respondError(c, http.StatusConflict, "Deactivated users must be reactivated before they can be suspended")
+    default:
+      respondError(c, http.StatusInternalServerError, "Failed to change user status")
     }
     return
   }
 
-  c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+  c.JSON(http.StatusOK, gin.H{"status_change": change})
 }
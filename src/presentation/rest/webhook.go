@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/presentation/jsonstrict"
+)
+
+type WebhookHandler interface {
+	HandleRegisterWebhook(c *gin.Context)
+	HandleGet(c *gin.Context)
+	HandleDelete(c *gin.Context)
+	HandleGetDeliveries(c *gin.Context)
+	HandleRedeliver(c *gin.Context)
+}
+
+func NewWebhookHandler(db *sql.DB, aw application.WebhookApp) WebhookHandler {
+	return &webhookHandler{
+		database:   db,
+		webhookApp: aw,
+	}
+}
+
+type webhookHandler struct {
+	webhookApp application.WebhookApp
+	database   *sql.DB
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+func (w *webhookHandler) HandleRegisterWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := jsonstrict.Decode(c.Request.Body, &req); err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	if req.URL == "" || len(req.Events) == 0 {
+		respondError(c, http.StatusBadRequest, "URL and Events are required")
+		return
+	}
+
+	webhook, err := w.webhookApp.Register(w.database, PrincipalFrom(c).OrgID, req.URL, req.Events, req.Secret)
+	if err != nil {
+		log.Printf("Error registering webhook: %v", err)
+		if err.Error() == "invalid webhook url" {
+			respondError(c, http.StatusBadRequest, "URL must be an absolute http or https URL")
+		} else if err.Error() == "webhook url not allowed" {
+			respondError(c, http.StatusBadRequest, "URL must not point at a loopback or private address")
+		} else if err.Error() == "invalid event type" {
+			respondError(c, http.StatusBadRequest, "Unknown event type")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to register webhook")
+		}
+		return
+	}
+
+	// The secret is only ever returned here.
+	c.JSON(http.StatusOK, gin.H{"webhook": webhook})
+}
+
+func (w *webhookHandler) HandleGet(c *gin.Context) {
+	ID := c.Query("id")
+	if ID == "" {
+		webhooks, err := w.webhookApp.List(w.database, PrincipalFrom(c).OrgID)
+		if err != nil {
+			log.Printf("Error listing webhooks: %v", err)
+			respondError(c, http.StatusInternalServerError, "Failed to get webhooks")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+		return
+	}
+
+	webhook, err := w.webhookApp.Get(w.database, PrincipalFrom(c).OrgID, ID)
+	if err != nil {
+		log.Printf("Error retrieving webhook: %v", err)
+		if err.Error() == "webhook not found" {
+			respondError(c, http.StatusNotFound, "Webhook not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to get webhook")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhook": webhook})
+}
+
+func (w *webhookHandler) HandleDelete(c *gin.Context) {
+	ID := c.Query("id")
+	if ID == "" {
+		respondError(c, http.StatusBadRequest, "ID is required")
+		return
+	}
+
+	if err := w.webhookApp.Delete(w.database, PrincipalFrom(c).OrgID, ID); err != nil {
+		log.Printf("Error deleting webhook: %v", err)
+		if err.Error() == "webhook not found" {
+			respondError(c, http.StatusNotFound, "Webhook not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to delete webhook")
+		}
+		return
+	}
+	respondMessage(c, http.StatusOK, "Webhook deleted successfully")
+}
+
+func (w *webhookHandler) HandleGetDeliveries(c *gin.Context) {
+	webhookID := c.Query("webhook_id")
+	if webhookID == "" {
+		respondError(c, http.StatusBadRequest, "WebhookID is required")
+		return
+	}
+
+	deliveries, err := w.webhookApp.Deliveries(w.database, PrincipalFrom(c).OrgID, webhookID)
+	if err != nil {
+		log.Printf("Error retrieving webhook deliveries: %v", err)
+		if err.Error() == "webhook not found" {
+			respondError(c, http.StatusNotFound, "Webhook not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to get deliveries")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func (w *webhookHandler) HandleRedeliver(c *gin.Context) {
+	deliveryID := c.Query("delivery_id")
+	if deliveryID == "" {
+		respondError(c, http.StatusBadRequest, "DeliveryID is required")
+		return
+	}
+
+	delivery, err := w.webhookApp.Redeliver(w.database, PrincipalFrom(c).OrgID, deliveryID)
+	if err != nil {
+		log.Printf("Error redelivering webhook: %v", err)
+		if err.Error() == "delivery not found" {
+			respondError(c, http.StatusNotFound, "Delivery not found")
+		} else if err.Error() == "webhook not found" {
+			respondError(c, http.StatusNotFound, "Webhook not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "Failed to redeliver")
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"delivery": delivery})
+}
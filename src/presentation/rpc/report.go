@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/rest"
+)
+
+type reportIDParams struct {
+	ID string `json:"id"`
+}
+
+type reportQueryParams struct {
+	ID       string `json:"id"`
+	AuthorID string `json:"author_id"`
+	Title    string `json:"title"`
+	Style    string `json:"style"`
+	Language string `json:"language"`
+}
+
+type messageResult struct {
+	Message string `json:"message"`
+}
+
+func (h *rpcHandler) reportRegister(caller model.Principal, params json.RawMessage) (interface{}, *rpcError) {
+	var req rest.ReportRegisterRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	report := req.Report()
+
+	if report.AuthorID == "" || report.Title == "" {
+		return nil, invalidParams("AuthorID and Title are required")
+	}
+
+	report.ID = uuid.New().String()
+
+	report, err := h.reportApp.Register(h.database, caller, report)
+	if err != nil {
+		log.Printf("Error registering report: %v", err)
+		if err.Error() == "author does not exist" {
+			return nil, notFound("Author not found")
+		} else if err.Error() == "forbidden" {
+			return nil, forbidden("Cannot register reports for another author")
+		} else if err.Error() == "invalid visibility" {
+			return nil, invalidParams("Visibility must be private or public")
+		} else if err.Error() == "author not active" {
+			return nil, conflict("Author is suspended or deactivated")
+		} else if err.Error() == "missing fields" {
+			return nil, invalidParams("Count, Style, and Language are required unless the author has defaults for them")
+		}
+		return nil, internalError("Failed to register report")
+	}
+	return report, nil
+}
+
+func (h *rpcHandler) reportEject(caller model.Principal, params json.RawMessage) (interface{}, *rpcError) {
+	var p reportIDParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ID == "" {
+		return nil, invalidParams("ID is required")
+	}
+
+	if err := h.reportApp.Eject(h.database, caller, p.ID); err != nil {
+		log.Printf("Error ejecting report: %v", err)
+		if err.Error() == "report not found" {
+			return nil, notFound("Report not found")
+		} else if err.Error() == "forbidden" {
+			return nil, forbidden("Only the author can eject this report")
+		}
+		return nil, internalError("Failed to eject report")
+	}
+	return messageResult{Message: "Report ejected successfully"}, nil
+}
+
+func (h *rpcHandler) reportGet(caller model.Principal, params json.RawMessage) (interface{}, *rpcError) {
+	var p reportQueryParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if p.ID != "" {
+		reports, err := h.reportApp.Get(h.database, caller, p.ID, "", "", "", "")
+		if err != nil {
+			log.Printf("Error retrieving report: %v", err)
+			if err.Error() == "report not found" {
+				return nil, notFound("Report not found")
+			} else if err.Error() == "forbidden" {
+				return nil, forbidden("This report is private")
+			}
+			return nil, internalError("Failed to retrieve report")
+		}
+		return reports, nil
+	}
+
+	if p.AuthorID == "" {
+		return nil, invalidParams("AuthorID is required")
+	}
+	reports, err := h.reportApp.Get(h.database, caller, "", p.AuthorID, p.Title, p.Style, p.Language)
+	if err != nil {
+		log.Printf("Error retrieving reports: %v", err)
+		if err.Error() == "author does not exist" {
+			return nil, notFound("Author not found")
+		} else if err.Error() == "report not found" {
+			return nil, notFound("Report not found")
+		}
+		return nil, internalError("Failed to retrieve reports")
+	}
+	return reports, nil
+}
+
+func (h *rpcHandler) reportUpdate(caller model.Principal, params json.RawMessage) (interface{}, *rpcError) {
+	var req rest.ReportUpdateRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.ID == "" {
+		return nil, invalidParams("ID is required")
+	}
+
+	if err := h.reportApp.Update(h.database, caller, req.ID, req.Count, req.Title, req.Style, req.Language, req.Visibility); err != nil {
+		log.Printf("Error updating report: %v", err)
+		if err.Error() == "report not found" {
+			return nil, notFound("Report not found")
+		} else if err.Error() == "forbidden" {
+			return nil, forbidden("Only the author and editors can update this report")
+		} else if err.Error() == "invalid visibility" {
+			return nil, invalidParams("Visibility must be private or public")
+		}
+		return nil, internalError("Failed to update report")
+	}
+	return messageResult{Message: "Report updated successfully"}, nil
+}
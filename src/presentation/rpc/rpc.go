@@ -0,0 +1,230 @@
+package rpc
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/jsonstrict"
+	"repo-api/src/presentation/rest"
+)
+
+// Standard JSON-RPC 2.0 error codes, plus server errors in the reserved
+// -32000 to -32099 range for outcomes the REST handlers report as 404.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeForbidden      = -32003
+	codeNotFound       = -32004
+	codeConflict       = -32009
+)
+
+type RPCHandler interface {
+	HandleRPC(c *gin.Context)
+}
+
+func NewRPCHandler(db *sql.DB, au application.UserApp, ar application.ReportApp) RPCHandler {
+	h := &rpcHandler{
+		database:  db,
+		userApp:   au,
+		reportApp: ar,
+	}
+	h.methods = map[string]method{
+		"ReportApp.Register": h.reportRegister,
+		"ReportApp.Eject":    h.reportEject,
+		"ReportApp.Get":      h.reportGet,
+		"ReportApp.Update":   h.reportUpdate,
+		"UserApp.Register":   h.userRegister,
+		"UserApp.Get":        h.userGet,
+		"UserApp.Update":     h.userUpdate,
+	}
+	h.scopes = map[string]string{
+		"ReportApp.Register": model.ScopeReportsWrite,
+		"ReportApp.Eject":    model.ScopeReportsWrite,
+		"ReportApp.Get":      model.ScopeReportsRead,
+		"ReportApp.Update":   model.ScopeReportsWrite,
+		"UserApp.Register":   model.ScopeUsersAdmin,
+		"UserApp.Get":        model.ScopeUsersRead,
+		"UserApp.Update":     model.ScopeUsersWrite,
+	}
+	return h
+}
+
+type rpcHandler struct {
+	userApp   application.UserApp
+	reportApp application.ReportApp
+	database  *sql.DB
+	methods   map[string]method
+	// scopes names the API key scope each method requires, matching the
+	// equivalent REST route.
+	scopes map[string]string
+}
+
+type method func(caller model.Principal, params json.RawMessage) (interface{}, *rpcError)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// statusData carries the HTTP status the equivalent REST call would have
+// returned, so clients can share error handling between both APIs.
+type statusData struct {
+	Status int `json:"status"`
+}
+
+func invalidParams(message string) *rpcError {
+	return &rpcError{Code: codeInvalidParams, Message: message, Data: statusData{Status: http.StatusBadRequest}}
+}
+
+func notFound(message string) *rpcError {
+	return &rpcError{Code: codeNotFound, Message: message, Data: statusData{Status: http.StatusNotFound}}
+}
+
+func forbidden(message string) *rpcError {
+	return &rpcError{Code: codeForbidden, Message: message, Data: statusData{Status: http.StatusForbidden}}
+}
+
+func conflict(message string) *rpcError {
+	return &rpcError{Code: codeConflict, Message: message, Data: statusData{Status: http.StatusConflict}}
+}
+
+func internalError(message string) *rpcError {
+	return &rpcError{Code: codeInternalError, Message: message, Data: statusData{Status: http.StatusInternalServerError}}
+}
+
+// HandleRPC serves single and batch calls. Notifications (requests without an
+// id) are executed but produce no response; a request made only of
+// notifications is answered with 204 No Content.
+func (h *rpcHandler) HandleRPC(c *gin.Context) {
+	principal := rest.PrincipalFrom(c)
+	body, err := io.ReadAll(c.Request.Body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, response{JSONRPC: "2.0", Error: &rpcError{Code: codeInvalidRequest, Message: "Request body too large"}, ID: json.RawMessage("null")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, response{JSONRPC: "2.0", Error: &rpcError{Code: codeParseError, Message: "Parse error"}, ID: json.RawMessage("null")})
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			c.JSON(http.StatusOK, response{JSONRPC: "2.0", Error: &rpcError{Code: codeParseError, Message: "Parse error"}, ID: json.RawMessage("null")})
+			return
+		}
+		if len(batch) == 0 {
+			c.JSON(http.StatusOK, response{JSONRPC: "2.0", Error: &rpcError{Code: codeInvalidRequest, Message: "Invalid Request"}, ID: json.RawMessage("null")})
+			return
+		}
+
+		responses := make([]response, 0, len(batch))
+		for _, raw := range batch {
+			if resp, ok := h.call(principal, raw); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+
+	if !json.Valid(body) {
+		c.JSON(http.StatusOK, response{JSONRPC: "2.0", Error: &rpcError{Code: codeParseError, Message: "Parse error"}, ID: json.RawMessage("null")})
+		return
+	}
+	resp, ok := h.call(principal, body)
+	if !ok {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// call runs a single request. The boolean is false for notifications, whose
+// outcome must not be reported back to the client.
+func (h *rpcHandler) call(principal model.Principal, raw json.RawMessage) (response, bool) {
+	var req request
+	if err := jsonstrict.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" || !validID(req.ID) {
+		return response{JSONRPC: "2.0", Error: &rpcError{Code: codeInvalidRequest, Message: "Invalid Request"}, ID: json.RawMessage("null")}, true
+	}
+	notification := len(req.ID) == 0
+
+	m, found := h.methods[req.Method]
+	if !found {
+		return response{JSONRPC: "2.0", Error: &rpcError{Code: codeMethodNotFound, Message: "Method not found"}, ID: req.ID}, !notification
+	}
+	if scope := h.scopes[req.Method]; !application.HasScope(principal.Scopes, scope) {
+		if principal.Suspended {
+			return response{JSONRPC: "2.0", Error: forbidden("The user of this token is suspended"), ID: req.ID}, !notification
+		}
+		return response{JSONRPC: "2.0", Error: forbidden("Missing the " + scope + " scope"), ID: req.ID}, !notification
+	}
+
+	params := bytes.TrimSpace(req.Params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		params = json.RawMessage("{}")
+	}
+	if params[0] != '{' {
+		return response{JSONRPC: "2.0", Error: invalidParams("params must be an object"), ID: req.ID}, !notification
+	}
+
+	result, rpcErr := m(principal, params)
+	if rpcErr != nil {
+		return response{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}, !notification
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return response{JSONRPC: "2.0", Result: result, ID: req.ID}, !notification
+}
+
+func validID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+// decodeParams rejects unknown and duplicate members so that a misspelled
+// parameter is reported instead of silently ignored.
+func decodeParams(params json.RawMessage, v interface{}) *rpcError {
+	if err := jsonstrict.Unmarshal(params, v); err != nil {
+		return invalidParams("Invalid params: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/rest"
+)
+
+type userIDParams struct {
+	ID string `json:"id"`
+}
+
+func (h *rpcHandler) userRegister(caller model.Principal, params json.RawMessage) (interface{}, *rpcError) {
+	var req rest.UserRegisterRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	user := req.User()
+	if user.ID == "" || user.Name == "" {
+		return nil, invalidParams("ID and Name are required")
+	}
+
+	if err := h.userApp.Register(h.database, caller, user); err != nil {
+		log.Printf("Error registering user: %v", err)
+		if message, ok := rest.ProfileError(err); ok {
+			return nil, invalidParams(message)
+		}
+		if err.Error() == "forbidden" {
+			return nil, forbidden("Only administrators can register users")
+		}
+		if err.Error() == "user id unavailable" {
+			return nil, conflict("This user ID is not available")
+		}
+		return nil, internalError("Failed to register user")
+	}
+	return user, nil
+}
+
+func (h *rpcHandler) userGet(caller model.Principal, params json.RawMessage) (interface{}, *rpcError) {
+	var p userIDParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ID == "" {
+		return nil, invalidParams("ID is required")
+	}
+
+	user, err := h.userApp.Get(h.database, caller, p.ID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notFound("User not found")
+		}
+		return nil, internalError("Failed to get user")
+	}
+	return user, nil
+}
+
+// userUpdate changes only the fields present in params, as PUT /user does.
+func (h *rpcHandler) userUpdate(caller model.Principal, params json.RawMessage) (interface{}, *rpcError) {
+	var req rest.UserUpdateRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.ID == "" {
+		return nil, invalidParams("ID is required")
+	}
+
+	if _, err := h.userApp.UpdateProfile(h.database, caller, req.ID, req.UserProfile); err != nil {
+		log.Printf("Error updating user: %v", err)
+		if message, ok := rest.ProfileError(err); ok {
+			return nil, invalidParams(message)
+		}
+		if err.Error() == "user not found" {
+			return nil, notFound("User not found")
+		} else if err.Error() == "forbidden" {
+			return nil, forbidden("Cannot update another user")
+		}
+		return nil, internalError("Failed to update user")
+	}
+	return messageResult{Message: "User updated successfully"}, nil
+}
@@ -0,0 +1,133 @@
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"repo-api/src/domain/model"
+)
+
+// comparison is one `attribute operator value` term of a filter.
+type comparison struct {
+	attribute string
+	operator  string
+	value     string
+}
+
+// filter is a conjunction of comparisons, the part of the SCIM filter
+// language provisioning clients use in practice. An empty filter matches
+// every user.
+type filter []comparison
+
+// parseFilter parses terms joined by "and". Attribute names and operators
+// are case-insensitive, values are quoted strings or true and false.
+func parseFilter(expression string) (filter, error) {
+	var f filter
+	rest := strings.TrimSpace(expression)
+	for rest != "" {
+		var term comparison
+		var found bool
+		term.attribute, rest, found = strings.Cut(rest, " ")
+		if !found {
+			return nil, fmt.Errorf("expected an operator after %q", term.attribute)
+		}
+		term.attribute = strings.ToLower(term.attribute)
+		switch term.attribute {
+		case "id", "username", "displayname", "name.formatted", "active":
+		default:
+			return nil, fmt.Errorf("cannot filter by %q", term.attribute)
+		}
+
+		term.operator, rest, _ = strings.Cut(strings.TrimLeft(rest, " "), " ")
+		term.operator = strings.ToLower(term.operator)
+		switch term.operator {
+		case "eq", "ne", "co", "sw", "ew":
+		default:
+			return nil, fmt.Errorf("unsupported operator %q", term.operator)
+		}
+
+		rest = strings.TrimLeft(rest, " ")
+		quoted := strings.HasPrefix(rest, `"`)
+		if quoted {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				// Skip what is escaped, which may be a backslash.
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			value, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", rest[:end+1])
+			}
+			term.value, rest = value, rest[end+1:]
+		} else {
+			term.value, rest, _ = strings.Cut(rest, " ")
+			term.value = strings.ToLower(term.value)
+			if term.value != "true" && term.value != "false" {
+				return nil, fmt.Errorf("invalid value %q", term.value)
+			}
+		}
+		if (term.attribute == "active") == quoted {
+			return nil, fmt.Errorf("invalid value for %s", term.attribute)
+		}
+		if term.attribute == "active" && term.operator != "eq" && term.operator != "ne" {
+			return nil, fmt.Errorf("active can only be compared with eq and ne")
+		}
+		f = append(f, term)
+
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			break
+		}
+		conjunction, next, _ := strings.Cut(rest, " ")
+		if strings.ToLower(conjunction) != "and" {
+			return nil, fmt.Errorf("only \"and\" can join terms")
+		}
+		rest = strings.TrimLeft(next, " ")
+		if rest == "" {
+			return nil, fmt.Errorf("expected a term after \"and\"")
+		}
+	}
+	return f, nil
+}
+
+// matches compares strings case-insensitively, as SCIM does for userName
+// and the name attributes.
+func (f filter) matches(user model.User) bool {
+	for _, term := range f {
+		var actual string
+		switch term.attribute {
+		case "id", "username":
+			actual = user.ID
+		case "displayname", "name.formatted":
+			actual = user.Name
+		case "active":
+			actual = strconv.FormatBool(user.Status != model.UserDeactivated)
+		}
+		actual, value := strings.ToLower(actual), strings.ToLower(term.value)
+
+		var ok bool
+		switch term.operator {
+		case "eq":
+			ok = actual == value
+		case "ne":
+			ok = actual != value
+		case "co":
+			ok = strings.Contains(actual, value)
+		case "sw":
+			ok = strings.HasPrefix(actual, value)
+		case "ew":
+			ok = strings.HasSuffix(actual, value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
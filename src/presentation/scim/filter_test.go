@@ -0,0 +1,42 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilterStrings(t *testing.T) {
+	for _, c := range []struct {
+		expression string
+		want       string
+	}{
+		{`userName eq "sato"`, `sato`},
+		{`userName eq "a\\"`, `a\`},
+		{`userName eq "a\\\\"`, `a\\`},
+		{`userName eq "a\"b"`, `a"b`},
+		{`userName eq "a\\" and active eq true`, `a\`},
+		{`userName eq "a b"`, `a b`},
+		{`userName eq ""`, ``},
+	} {
+		t.Run(c.expression, func(t *testing.T) {
+			f, err := parseFilter(c.expression)
+			require.NoError(t, err)
+			require.NotEmpty(t, f)
+			assert.Equal(t, c.want, f[0].value)
+		})
+	}
+
+	for _, expression := range []string{
+		`userName eq "a\"`,
+		`userName eq "a\\\"`,
+		`userName eq "a`,
+		`userName eq "\`,
+	} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := parseFilter(expression)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package scim
+
+import (
+	"strings"
+	"time"
+
+	"repo-api/src/domain/model"
+)
+
+const (
+	schemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// userResource is a SCIM User. Both id and userName are the user's ID, and
+// displayName and name.formatted are both its name; attributes that
+// model.User has no place for are accepted and ignored. Only deactivated
+// users are inactive: suspension is left to administrators of this API, so
+// that identity management systems do not lift it.
+type userResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Meta        *meta    `json:"meta,omitempty"`
+}
+
+type name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type listResponse struct {
+	Schemas      []string       `json:"schemas"`
+	TotalResults int            `json:"totalResults"`
+	StartIndex   int            `json:"startIndex"`
+	ItemsPerPage int            `json:"itemsPerPage"`
+	Resources    []userResource `json:"Resources"`
+}
+
+// toResource maps a user to SCIM. base is the URL of the Users endpoint.
+func toResource(user model.User, base string) userResource {
+	active := user.Status != model.UserDeactivated
+	resource := userResource{
+		Schemas:     []string{schemaUser},
+		ID:          user.ID,
+		UserName:    user.ID,
+		Name:        &name{Formatted: user.Name},
+		DisplayName: user.Name,
+		Active:      &active,
+		Meta: &meta{
+			ResourceType: "User",
+			Location:     base + "/" + user.ID,
+		},
+	}
+	if !user.UpdatedAt.IsZero() {
+		resource.Meta.LastModified = user.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return resource
+}
+
+// userName picks the name of a user from the attributes a client sent:
+// displayName, else name.formatted, else the family name followed by the
+// given name, as Japanese names are written.
+func (r userResource) userName() string {
+	if r.DisplayName != "" {
+		return r.DisplayName
+	}
+	if r.Name == nil {
+		return ""
+	}
+	if r.Name.Formatted != "" {
+		return r.Name.Formatted
+	}
+	return strings.TrimSpace(r.Name.FamilyName + " " + r.Name.GivenName)
+}
+
+func (r userResource) status() string {
+	if r.Active != nil && !*r.Active {
+		return model.UserDeactivated
+	}
+	return model.UserActive
+}
@@ -0,0 +1,385 @@
+// Package scim serves the SCIM 2.0 Users endpoint (RFC 7643, RFC 7644), so
+// that identity management systems can provision users.
+package scim
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"repo-api/src/application"
+	"repo-api/src/domain/model"
+	"repo-api/src/presentation/rest"
+)
+
+const (
+	contentType     = "application/scim+json"
+	defaultCount    = 100
+	maxCount        = 1000
+	usersPathSuffix = "/Users"
+)
+
+type SCIMHandler interface {
+	HandleCreate(c *gin.Context)
+	HandleGet(c *gin.Context)
+	HandleList(c *gin.Context)
+	HandleReplace(c *gin.Context)
+	HandlePatch(c *gin.Context)
+	HandleDelete(c *gin.Context)
+}
+
+func NewSCIMHandler(db *sql.DB, au application.UserApp) SCIMHandler {
+	return &scimHandler{
+		database: db,
+		userApp:  au,
+	}
+}
+
+type scimHandler struct {
+	userApp  application.UserApp
+	database *sql.DB
+}
+
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func respond(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", contentType)
+	c.Status(status)
+	if err := json.NewEncoder(c.Writer).Encode(body); err != nil {
+		log.Printf("Error writing SCIM response: %v", err)
+	}
+}
+
+// respondError answers in the SCIM error format. scimType is one of the
+// detail error keywords of RFC 7644, or empty.
+func respondError(c *gin.Context, status int, scimType, detail string) {
+	respond(c, status, errorResponse{
+		Schemas:  []string{schemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+// respondAppError maps the errors of UserApp.
+func respondAppError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || err.Error() == "user not found":
+		respondError(c, http.StatusNotFound, "", "User not found")
+	case err.Error() == "forbidden":
+		respondError(c, http.StatusForbidden, "", "Only administrators can provision users")
+	case err.Error() == "user id unavailable":
+		respondError(c, http.StatusConflict, "uniqueness", "This userName is not available")
+	case err.Error() == "user has reports":
+		respondError(c, http.StatusConflict, "", "User has reports; deactivate the user instead")
+	case err.Error() == "cannot delete self", err.Error() == "cannot change own status":
+		respondError(c, http.StatusBadRequest, "mutability", "Cannot delete or deactivate the calling user")
+	default:
+		log.Printf("Error %s: %v", action, err)
+		respondError(c, http.StatusInternalServerError, "", "Failed "+action)
+	}
+}
+
+// usersURL is the absolute URL of the Users endpoint, which resource
+// locations are relative to.
+func usersURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	path := c.Request.URL.Path
+	if i := strings.Index(path, usersPathSuffix); i >= 0 {
+		path = path[:i+len(usersPathSuffix)]
+	}
+	return scheme + "://" + c.Request.Host + path
+}
+
+// decode reads a SCIM request body. Unlike the rest of the API, it allows
+// unknown attributes, since clients send whatever their schema has.
+func decode(c *gin.Context, v interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(c, http.StatusRequestEntityTooLarge, "", "Request body must not exceed "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+			return false
+		}
+		respondError(c, http.StatusBadRequest, "invalidSyntax", "Invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func (s *scimHandler) respondUser(c *gin.Context, status int, ID string) {
+	user, err := s.userApp.Get(s.database, rest.PrincipalFrom(c), ID)
+	if err != nil {
+		respondAppError(c, err, "to get user")
+		return
+	}
+	resource := toResource(user, usersURL(c))
+	if status == http.StatusCreated {
+		c.Header("Location", resource.Meta.Location)
+	}
+	respond(c, status, resource)
+}
+
+func (s *scimHandler) HandleCreate(c *gin.Context) {
+	var resource userResource
+	if !decode(c, &resource) {
+		return
+	}
+	if resource.UserName == "" {
+		respondError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	name := resource.userName()
+	if name == "" {
+		name = resource.UserName
+	}
+
+	user := model.User{ID: resource.UserName, Name: name, Status: resource.status()}
+	if err := s.userApp.Register(s.database, rest.PrincipalFrom(c), user); err != nil {
+		respondAppError(c, err, "to create user")
+		return
+	}
+	s.respondUser(c, http.StatusCreated, resource.UserName)
+}
+
+func (s *scimHandler) HandleGet(c *gin.Context) {
+	s.respondUser(c, http.StatusOK, c.Param("id"))
+}
+
+// HandleList filters in memory, since organizations have at most a few
+// thousand users.
+func (s *scimHandler) HandleList(c *gin.Context) {
+	f, err := parseFilter(c.Query("filter"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := 1, defaultCount
+	if value := c.Query("startIndex"); value != "" {
+		if startIndex, err = strconv.Atoi(value); err != nil {
+			respondError(c, http.StatusBadRequest, "invalidValue", "startIndex must be an integer")
+			return
+		}
+		// RFC 7644 treats values below 1 as 1.
+		startIndex = max(startIndex, 1)
+	}
+	if value := c.Query("count"); value != "" {
+		if count, err = strconv.Atoi(value); err != nil {
+			respondError(c, http.StatusBadRequest, "invalidValue", "count must be an integer")
+			return
+		}
+		count = min(max(count, 0), maxCount)
+	}
+
+	users, err := s.userApp.List(s.database, rest.PrincipalFrom(c))
+	if err != nil {
+		respondAppError(c, err, "to list users")
+		return
+	}
+	var matched []model.User
+	for _, user := range users {
+		if f.matches(user) {
+			matched = append(matched, user)
+		}
+	}
+
+	base := usersURL(c)
+	resources := []userResource{}
+	for i := startIndex - 1; i < len(matched) && len(resources) < count; i++ {
+		resources = append(resources, toResource(matched[i], base))
+	}
+	respond(c, http.StatusOK, listResponse{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: len(matched),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// apply changes the name and status of user ID to the given ones, where
+// they differ. Activating a suspended user leaves them suspended.
+func (s *scimHandler) apply(c *gin.Context, ID, name, status string) bool {
+	principal := rest.PrincipalFrom(c)
+	user, err := s.userApp.Get(s.database, principal, ID)
+	if err != nil {
+		respondAppError(c, err, "to get user")
+		return false
+	}
+	if name != user.Name {
+		if err := s.userApp.Update(s.database, principal, ID, name); err != nil {
+			respondAppError(c, err, "to update user")
+			return false
+		}
+	}
+	if status != user.Status && !(status == model.UserActive && user.Status == model.UserSuspended) {
+		if _, err := s.userApp.SetStatus(s.database, principal, ID, status, ""); err != nil {
+			respondAppError(c, err, "to update user status")
+			return false
+		}
+	}
+	return true
+}
+
+// HandleReplace keeps the current status if the client omits active.
+func (s *scimHandler) HandleReplace(c *gin.Context) {
+	var resource userResource
+	if !decode(c, &resource) {
+		return
+	}
+	ID := c.Param("id")
+	if resource.UserName != "" && resource.UserName != ID {
+		respondError(c, http.StatusBadRequest, "mutability", "userName cannot be changed")
+		return
+	}
+	name := resource.userName()
+	if name == "" {
+		respondError(c, http.StatusBadRequest, "invalidValue", "displayName or name is required")
+		return
+	}
+
+	status := resource.status()
+	if resource.Active == nil {
+		user, err := s.userApp.Get(s.database, rest.PrincipalFrom(c), ID)
+		if err != nil {
+			respondAppError(c, err, "to get user")
+			return
+		}
+		status = user.Status
+	}
+	if s.apply(c, ID, name, status) {
+		s.respondUser(c, http.StatusOK, ID)
+	}
+}
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// parseActive accepts booleans and, as some clients send them, the strings
+// "True" and "False".
+func parseActive(value json.RawMessage) (bool, bool) {
+	var active bool
+	if json.Unmarshal(value, &active) == nil {
+		return active, true
+	}
+	var s string
+	if json.Unmarshal(value, &s) != nil {
+		return false, false
+	}
+	active, err := strconv.ParseBool(strings.ToLower(s))
+	return active, err == nil
+}
+
+// HandlePatch supports add and replace of active, displayName and
+// name.formatted, with or without a path.
+func (s *scimHandler) HandlePatch(c *gin.Context) {
+	var req patchRequest
+	if !decode(c, &req) {
+		return
+	}
+	if len(req.Operations) == 0 {
+		respondError(c, http.StatusBadRequest, "invalidValue", "Operations is required")
+		return
+	}
+
+	ID := c.Param("id")
+	user, err := s.userApp.Get(s.database, rest.PrincipalFrom(c), ID)
+	if err != nil {
+		respondAppError(c, err, "to get user")
+		return
+	}
+	fullName, status := user.Name, user.Status
+
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op == "remove" {
+			respondError(c, http.StatusBadRequest, "mutability", "Attributes of users cannot be removed")
+			return
+		}
+		if op != "add" && op != "replace" {
+			respondError(c, http.StatusBadRequest, "invalidSyntax", "Unsupported op "+operation.Op)
+			return
+		}
+
+		values := map[string]json.RawMessage{}
+		if operation.Path == "" {
+			if json.Unmarshal(operation.Value, &values) != nil {
+				respondError(c, http.StatusBadRequest, "invalidValue", "value must be an object when path is omitted")
+				return
+			}
+		} else {
+			values[operation.Path] = operation.Value
+		}
+
+		for path, value := range values {
+			switch strings.ToLower(path) {
+			case "active":
+				active, ok := parseActive(value)
+				if !ok {
+					respondError(c, http.StatusBadRequest, "invalidValue", "active must be a boolean")
+					return
+				}
+				status = model.UserDeactivated
+				if active {
+					status = model.UserActive
+				}
+			case "displayname", "name.formatted":
+				if json.Unmarshal(value, &fullName) != nil || fullName == "" {
+					respondError(c, http.StatusBadRequest, "invalidValue", path+" must be a non-empty string")
+					return
+				}
+			case "name":
+				var n name
+				if json.Unmarshal(value, &n) != nil || (userResource{Name: &n}).userName() == "" {
+					respondError(c, http.StatusBadRequest, "invalidValue", "name must have formatted or familyName and givenName")
+					return
+				}
+				fullName = userResource{Name: &n}.userName()
+			case "username":
+				var userName string
+				if json.Unmarshal(value, &userName) != nil || userName != ID {
+					respondError(c, http.StatusBadRequest, "mutability", "userName cannot be changed")
+					return
+				}
+			default:
+				respondError(c, http.StatusBadRequest, "invalidPath", "Unsupported path "+path)
+				return
+			}
+		}
+	}
+
+	if s.apply(c, ID, fullName, status) {
+		s.respondUser(c, http.StatusOK, ID)
+	}
+}
+
+// HandleDelete never removes reports; users who have any are to be
+// deactivated instead, or deleted with DELETE /user.
+func (s *scimHandler) HandleDelete(c *gin.Context) {
+	deletion := model.UserDeletion{Policy: model.DeleteBlock}
+	if _, err := s.userApp.Delete(s.database, rest.PrincipalFrom(c), c.Param("id"), deletion); err != nil {
+		respondAppError(c, err, "to delete user")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}